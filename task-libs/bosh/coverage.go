@@ -0,0 +1,156 @@
+package bosh
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Coverage gap kinds reported in CoverageGap.Kind, in the order they are
+// reported.
+const (
+	GapMissing          = "missing"
+	GapVersionMismatch  = "version-mismatch"
+	GapStemcellMismatch = "stemcell-mismatch"
+	GapStale            = "stale"
+)
+
+var gapOrder = map[string]int{
+	GapMissing:          0,
+	GapVersionMismatch:  1,
+	GapStemcellMismatch: 2,
+	GapStale:            3,
+}
+
+// CoverageGap is a release of a manifest that a compiled releases ops file
+// does not cover, or an entry of the ops file that does not belong to the
+// manifest.
+type CoverageGap struct {
+	Kind    string `json:"kind"`
+	Release string `json:"release"`
+	Message string `json:"message"`
+}
+
+func (g CoverageGap) String() string {
+	return fmt.Sprintf("%s: %s: %s", g.Kind, g.Release, g.Message)
+}
+
+// ParseReleaseOps returns the releases set by the /releases/name=<name> and
+// /releases/- ops of an ops file, such as operations/use-compiled-releases.yml.
+// Ops on a single field of a release are ignored.
+func ParseReleaseOps(opsFile []byte) ([]Release, error) {
+	var ops []struct {
+		Type  string
+		Path  string
+		Value yaml.Node
+	}
+	err := yaml.Unmarshal(opsFile, &ops)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []Release
+	for _, op := range ops {
+		name, ok := releaseOpName(op.Path)
+		if op.Type != "replace" || !ok || op.Value.Kind != yaml.MappingNode {
+			continue
+		}
+
+		var release Release
+		err := op.Value.Decode(&release)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse op %s: %w", op.Path, err)
+		}
+		if release.Name == "" {
+			release.Name = name
+		}
+
+		releases = append(releases, release)
+	}
+
+	return releases, nil
+}
+
+// releaseOpName returns the release name in an ops file path that sets a
+// whole release. It is empty for /releases/-.
+func releaseOpName(path string) (string, bool) {
+	if path == "/releases/-" {
+		return "", true
+	}
+
+	name, ok := strings.CutPrefix(path, "/releases/name=")
+	if !ok || strings.Contains(name, "/") {
+		return "", false
+	}
+
+	return strings.TrimSuffix(name, "?"), true
+}
+
+// CompiledReleaseCoverage checks that compiled has an entry for every release
+// of the manifest, at the manifest version and compiled against one of the
+// manifest stemcells, and no entry for releases the manifest does not have.
+// Gaps are returned by kind, then in manifest order.
+func CompiledReleaseCoverage(manifest Manifest, compiled []Release) []CoverageGap {
+	var gaps []CoverageGap
+	add := func(kind, release, format string, args ...interface{}) {
+		gaps = append(gaps, CoverageGap{Kind: kind, Release: release, Message: fmt.Sprintf(format, args...)})
+	}
+
+	entries := map[string]Release{}
+	for _, release := range compiled {
+		entries[release.Name] = release
+	}
+
+	inManifest := map[string]bool{}
+	for _, release := range manifest.Releases {
+		inManifest[release.Name] = true
+
+		entry, ok := entries[release.Name]
+		if !ok {
+			add(GapMissing, release.Name, "no compiled release for version %s", release.Version)
+			continue
+		}
+
+		if entry.Version != release.Version {
+			add(GapVersionMismatch, release.Name, "compiled release is version %s, manifest has %s", entry.Version, release.Version)
+		}
+
+		if !isCompiledForAny(entry, manifest.Stemcells) {
+			add(GapStemcellMismatch, release.Name, "compiled against %s, manifest uses %s",
+				stemcellNames(entry.CompiledStemcells()), stemcellNames(manifest.Stemcells))
+		}
+	}
+
+	for _, release := range compiled {
+		if !inManifest[release.Name] {
+			add(GapStale, release.Name, "release is not in the manifest")
+		}
+	}
+
+	sort.SliceStable(gaps, func(i, j int) bool { return gapOrder[gaps[i].Kind] < gapOrder[gaps[j].Kind] })
+
+	return gaps
+}
+
+func isCompiledForAny(release Release, stemcells []Stemcell) bool {
+	for _, stemcell := range stemcells {
+		if release.IsCompiledFor(stemcell) {
+			return true
+		}
+	}
+	return false
+}
+
+func stemcellNames(stemcells []Stemcell) string {
+	if len(stemcells) == 0 {
+		return "no stemcell"
+	}
+
+	var names []string
+	for _, stemcell := range stemcells {
+		names = append(names, fmt.Sprintf("%s/%s", stemcell.OS, stemcell.Version))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,119 @@
+package bosh_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+)
+
+var _ = Describe("Coverage", func() {
+	Describe("ParseReleaseOps", func() {
+		It("returns the releases of the ops file", func() {
+			releases, err := ParseReleaseOps([]byte(`## GENERATED FILE. DO NOT EDIT
+---
+- type: replace
+  path: /releases/name=capi
+  value:
+    name: capi
+    sha1: abc
+    stemcell:
+      os: ubuntu-jammy
+      version: "1.10"
+    url: https://example.com/capi.tgz
+    version: 1.2.3
+- type: replace
+  path: /releases/name=uaa?
+  value:
+    version: 4.5.6
+- type: replace
+  path: /releases/-
+  value:
+    name: diego
+    version: 2.0.0
+- type: replace
+  path: /releases/name=capi/version
+  value: 1.2.4
+- type: replace
+  path: /instance_groups/name=api/instances
+  value: 3
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(releases).To(Equal([]Release{
+				{
+					Name:     "capi",
+					SHA1:     "abc",
+					Stemcell: Stemcell{OS: "ubuntu-jammy", Version: "1.10"},
+					URL:      "https://example.com/capi.tgz",
+					Version:  "1.2.3",
+				},
+				{Name: "uaa", Version: "4.5.6"},
+				{Name: "diego", Version: "2.0.0"},
+			}))
+		})
+
+		It("returns an error when the ops file is not a list", func() {
+			_, err := ParseReleaseOps([]byte("not: a list"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("CompiledReleaseCoverage", func() {
+		var (
+			manifest Manifest
+			compiled []Release
+		)
+
+		BeforeEach(func() {
+			manifest = Manifest{
+				Releases: []Release{
+					{Name: "capi", Version: "1.2.3"},
+					{Name: "uaa", Version: "4.5.6"},
+				},
+				Stemcells: []Stemcell{{Alias: "default", OS: "ubuntu-jammy", Version: "1.10"}},
+			}
+
+			compiled = []Release{
+				{Name: "capi", Version: "1.2.3", Stemcell: Stemcell{OS: "ubuntu-jammy", Version: "1.10"}},
+				{Name: "uaa", Version: "4.5.6", ExportedFrom: []Stemcell{
+					{OS: "ubuntu-jammy", Version: "1.9"},
+					{OS: "ubuntu-jammy", Version: "1.10"},
+				}},
+			}
+		})
+
+		It("returns no gaps when every release is covered", func() {
+			Expect(CompiledReleaseCoverage(manifest, compiled)).To(BeEmpty())
+		})
+
+		It("reports every kind of gap", func() {
+			manifest.Releases = append(manifest.Releases, Release{Name: "diego", Version: "2.0.0"})
+			compiled[0].Version = "1.2.2"
+			compiled[1].ExportedFrom = []Stemcell{{OS: "ubuntu-jammy", Version: "1.9"}}
+			compiled = append(compiled, Release{Name: "garden", Version: "1.0.0", Stemcell: Stemcell{OS: "ubuntu-jammy", Version: "1.10"}})
+
+			Expect(CompiledReleaseCoverage(manifest, compiled)).To(Equal([]CoverageGap{
+				{Kind: GapMissing, Release: "diego", Message: "no compiled release for version 2.0.0"},
+				{Kind: GapVersionMismatch, Release: "capi", Message: "compiled release is version 1.2.2, manifest has 1.2.3"},
+				{Kind: GapStemcellMismatch, Release: "uaa", Message: "compiled against ubuntu-jammy/1.9, manifest uses ubuntu-jammy/1.10"},
+				{Kind: GapStale, Release: "garden", Message: "release is not in the manifest"},
+			}))
+		})
+
+		It("reports source releases in the ops file as not compiled", func() {
+			compiled[0].Stemcell = Stemcell{}
+
+			Expect(CompiledReleaseCoverage(manifest, compiled)).To(Equal([]CoverageGap{
+				{Kind: GapStemcellMismatch, Release: "capi", Message: "compiled against no stemcell, manifest uses ubuntu-jammy/1.10"},
+			}))
+		})
+
+		It("accepts releases compiled against any of the manifest stemcells", func() {
+			manifest.Stemcells = append(manifest.Stemcells, Stemcell{Alias: "noble", OS: "ubuntu-noble", Version: "1.5"})
+			compiled[0].Stemcell = Stemcell{OS: "ubuntu-noble", Version: "1.5"}
+
+			Expect(CompiledReleaseCoverage(manifest, compiled)).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,138 @@
+package bosh
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/taskevents"
+)
+
+// compilationUpdate returns the update block used when DeployOptions does not
+// provide one. It keeps compilation deployments as quick as possible. Each
+// manifest gets its own copy.
+func compilationUpdate() block {
+	return block{
+		"canaries":          1,
+		"max_in_flight":     1,
+		"canary_watch_time": 1,
+		"update_watch_time": 1,
+	}
+}
+
+// DeployOptions configures how a manifest is deployed.
+type DeployOptions struct {
+	// Update replaces the manifest update block. An update block for quick
+	// compilation deployments is used when it is nil.
+	Update map[string]interface{}
+
+	OpsFiles []string
+	Vars     map[string]string
+
+	Recreate  bool
+	Fix       bool
+	SkipDrain bool
+	DryRun    bool
+
+	// Timeout bounds the deploy. Zero means no timeout.
+	Timeout time.Duration
+
+	// Progress reports the events of the deploy task while it runs, and its
+	// per-stage timing summary when it finishes.
+	Progress *taskevents.Reporter
+}
+
+func (o DeployOptions) args() []string {
+	var args []string
+	for _, opsFile := range o.OpsFiles {
+		args = append(args, "-o", opsFile)
+	}
+
+	var names []string
+	for name := range o.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-v", fmt.Sprintf("%s=%s", name, o.Vars[name]))
+	}
+
+	if o.Recreate {
+		args = append(args, "--recreate")
+	}
+	if o.Fix {
+		args = append(args, "--fix")
+	}
+	if o.SkipDrain {
+		args = append(args, "--skip-drain")
+	}
+	if o.DryRun {
+		args = append(args, "--dry-run")
+	}
+
+	return args
+}
+
+// DeployResult is the parsed output of a bosh deploy.
+type DeployResult struct {
+	TaskID           int
+	Duration         time.Duration
+	InstancesChanged []string
+}
+
+var (
+	deployTaskPattern     = regexp.MustCompile(`Task (\d+)`)
+	deployDurationPattern = regexp.MustCompile(`Task \d+ Duration\s+(\d+):(\d{2}):(\d{2})`)
+	deployInstancePattern = regexp.MustCompile(`\| (?:Updating|Creating|Recreating) instance [\w\-]+: ([\w\-]+/[\w\-]+)`)
+)
+
+func parseDeployOutput(r io.Reader) (DeployResult, error) {
+	var result DeployResult
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return result, err
+	}
+
+	if len(strings.TrimSpace(string(content))) == 0 {
+		return result, nil
+	}
+
+	var output struct {
+		Blocks []string
+		Lines  []string
+	}
+
+	err = json.Unmarshal(content, &output)
+	if err != nil {
+		return result, fmt.Errorf("failed to parse bosh deploy output: %w", err)
+	}
+
+	text := strings.Join(append(output.Blocks, output.Lines...), "\n")
+
+	if match := deployTaskPattern.FindStringSubmatch(text); match != nil {
+		result.TaskID, _ = strconv.Atoi(match[1])
+	}
+
+	if match := deployDurationPattern.FindStringSubmatch(text); match != nil {
+		hours, _ := strconv.Atoi(match[1])
+		minutes, _ := strconv.Atoi(match[2])
+		seconds, _ := strconv.Atoi(match[3])
+		result.Duration = time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+	}
+
+	seen := map[string]bool{}
+	for _, match := range deployInstancePattern.FindAllStringSubmatch(text, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			result.InstancesChanged = append(result.InstancesChanged, match[1])
+		}
+	}
+
+	return result, nil
+}
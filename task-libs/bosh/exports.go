@@ -0,0 +1,84 @@
+package bosh
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ExportsFile is the manifest that export-all-compiled-release-tarballs
+// writes next to the tarballs it exports. It lists every release of the run,
+// including the ones skipped because they had already been exported.
+const ExportsFile = "exports.json"
+
+// ExportedRelease is a compiled release tarball listed in an exports
+// manifest.
+type ExportedRelease struct {
+	Release         string  `json:"release"`
+	Version         string  `json:"version"`
+	StemcellOS      string  `json:"stemcell_os"`
+	StemcellVersion string  `json:"stemcell_version"`
+	Tarball         string  `json:"tarball"`
+	SHA256          string  `json:"sha256"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// CompiledRelease returns the release as it appears in a compiled releases
+// ops file, with the tarball uploaded under urlPrefix.
+func (e ExportedRelease) CompiledRelease(urlPrefix string) Release {
+	return Release{
+		Name:     e.Release,
+		SHA1:     "sha256:" + e.SHA256,
+		Stemcell: Stemcell{OS: e.StemcellOS, Version: e.StemcellVersion},
+		URL:      fmt.Sprintf("%s/%s", urlPrefix, e.Tarball),
+		Version:  e.Version,
+	}
+}
+
+// ReadExports reads an exports manifest.
+func ReadExports(path string) ([]ExportedRelease, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var exports []ExportedRelease
+	err = json.Unmarshal(content, &exports)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse exports manifest %s: %w", path, err)
+	}
+
+	for _, export := range exports {
+		if export.Release == "" || export.Version == "" || export.StemcellOS == "" || export.StemcellVersion == "" || export.Tarball == "" || export.SHA256 == "" {
+			return nil, fmt.Errorf("exports manifest %s has an incomplete entry for %q", path, export.Tarball)
+		}
+	}
+
+	return exports, nil
+}
+
+// WriteExports writes an exports manifest sorted by release and stemcell.
+func WriteExports(path string, exports []ExportedRelease) error {
+	sorted := append([]ExportedRelease{}, exports...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Release != b.Release {
+			return a.Release < b.Release
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		if a.StemcellOS != b.StemcellOS {
+			return a.StemcellOS < b.StemcellOS
+		}
+		return a.StemcellVersion < b.StemcellVersion
+	})
+
+	content, err := json.MarshalIndent(sorted, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
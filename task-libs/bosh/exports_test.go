@@ -0,0 +1,76 @@
+package bosh_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+)
+
+var _ = Describe("Exports", func() {
+	var (
+		dir  string
+		path string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "exports-")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, ExportsFile)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("writes exports sorted by release and stemcell and reads them back", func() {
+		noble := ExportedRelease{Release: "capi", Version: "1.2.3", StemcellOS: "ubuntu-noble", StemcellVersion: "1.5", Tarball: "capi-1.2.3-ubuntu-noble-1.5-20260101-120000-000000001.tgz", SHA256: "def", DurationSeconds: 2}
+		jammy := ExportedRelease{Release: "capi", Version: "1.2.3", StemcellOS: "ubuntu-jammy", StemcellVersion: "1.10", Tarball: "capi-1.2.3-ubuntu-jammy-1.10-20260101-120000-000000001.tgz", SHA256: "abc", DurationSeconds: 1.25}
+		bpm := ExportedRelease{Release: "bpm", Version: "1.0.0", StemcellOS: "ubuntu-jammy", StemcellVersion: "1.10", Tarball: "bpm-1.0.0-ubuntu-jammy-1.10-20260101-120000-000000001.tgz", SHA256: "123"}
+
+		Expect(WriteExports(path, []ExportedRelease{noble, jammy, bpm})).To(Succeed())
+
+		content, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(MatchJSON(`[
+			{"release": "bpm", "version": "1.0.0", "stemcell_os": "ubuntu-jammy", "stemcell_version": "1.10", "tarball": "bpm-1.0.0-ubuntu-jammy-1.10-20260101-120000-000000001.tgz", "sha256": "123", "duration_seconds": 0},
+			{"release": "capi", "version": "1.2.3", "stemcell_os": "ubuntu-jammy", "stemcell_version": "1.10", "tarball": "capi-1.2.3-ubuntu-jammy-1.10-20260101-120000-000000001.tgz", "sha256": "abc", "duration_seconds": 1.25},
+			{"release": "capi", "version": "1.2.3", "stemcell_os": "ubuntu-noble", "stemcell_version": "1.5", "tarball": "capi-1.2.3-ubuntu-noble-1.5-20260101-120000-000000001.tgz", "sha256": "def", "duration_seconds": 2}
+		]`))
+
+		exports, err := ReadExports(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exports).To(Equal([]ExportedRelease{bpm, jammy, noble}))
+	})
+
+	It("writes an empty list when there are no exports", func() {
+		Expect(WriteExports(path, nil)).To(Succeed())
+
+		content, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(MatchJSON(`[]`))
+	})
+
+	It("returns an error for an incomplete entry", func() {
+		Expect(os.WriteFile(path, []byte(`[{"release": "capi", "tarball": "capi.tgz"}]`), 0644)).To(Succeed())
+
+		_, err := ReadExports(path)
+		Expect(err).To(MatchError(ContainSubstring(`has an incomplete entry for "capi.tgz"`)))
+	})
+
+	It("returns the release for a compiled releases ops file", func() {
+		export := ExportedRelease{Release: "capi", Version: "1.2.3", StemcellOS: "ubuntu-jammy", StemcellVersion: "1.10", Tarball: "capi.tgz", SHA256: "abc"}
+
+		Expect(export.CompiledRelease("https://example.com/bucket")).To(Equal(Release{
+			Name:     "capi",
+			SHA1:     "sha256:abc",
+			Stemcell: Stemcell{OS: "ubuntu-jammy", Version: "1.10"},
+			URL:      "https://example.com/bucket/capi.tgz",
+			Version:  "1.2.3",
+		}))
+	})
+})
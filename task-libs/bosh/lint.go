@@ -0,0 +1,166 @@
+package bosh
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Lint rules reported in Finding.Rule.
+const (
+	RuleUndeclaredRelease      = "undeclared-release"
+	RuleUnknownStemcellAlias   = "unknown-stemcell-alias"
+	RuleMissingStemcell        = "missing-stemcell"
+	RuleDuplicateRelease       = "duplicate-release"
+	RuleDuplicateInstanceGroup = "duplicate-instance-group"
+	RuleDuplicateJob           = "duplicate-job"
+	RuleDuplicateVariable      = "duplicate-variable"
+	RuleIncompleteRelease      = "incomplete-release"
+)
+
+// Finding is a problem found by Lint. Path is an ops file path to the
+// offending element and Line its line in the manifest.
+type Finding struct {
+	Rule    string `json:"rule"`
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%d: %s: %s (%s)", f.Line, f.Rule, f.Message, f.Path)
+}
+
+// Lint checks a manifest for mistakes that otherwise only show up when it is
+// deployed. Findings are returned in manifest order.
+func Lint(manifestContent []byte) ([]Finding, error) {
+	var doc yaml.Node
+	err := yaml.Unmarshal(manifestContent, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("manifest is not a map")
+	}
+	root := doc.Content[0]
+
+	l := new(linter)
+
+	releases := l.uniqueNames(mappingValue(root, "releases"), "/releases", RuleDuplicateRelease, "release")
+	for _, release := range sequenceItems(mappingValue(root, "releases")) {
+		name := scalarValue(release, "name")
+		for _, key := range []string{"version", "url", "sha1"} {
+			if scalarValue(release, key) == "" {
+				l.add(RuleIncompleteRelease, fmt.Sprintf("/releases/name=%s", name), release.Line,
+					"release %q does not declare %s", name, key)
+			}
+		}
+	}
+
+	aliases := map[string]bool{}
+	for _, stemcell := range sequenceItems(mappingValue(root, "stemcells")) {
+		aliases[scalarValue(stemcell, "alias")] = true
+	}
+
+	l.uniqueNames(mappingValue(root, "instance_groups"), "/instance_groups", RuleDuplicateInstanceGroup, "instance group")
+	for _, ig := range sequenceItems(mappingValue(root, "instance_groups")) {
+		path := fmt.Sprintf("/instance_groups/name=%s", scalarValue(ig, "name"))
+
+		switch stemcell := mappingValue(ig, "stemcell"); {
+		case stemcell == nil:
+			l.add(RuleMissingStemcell, path, ig.Line,
+				"instance group %q does not declare a stemcell", scalarValue(ig, "name"))
+		case !aliases[stemcell.Value]:
+			l.add(RuleUnknownStemcellAlias, path+"/stemcell", stemcell.Line,
+				"stemcell alias %q is not declared under stemcells", stemcell.Value)
+		}
+
+		l.checkJobs(ig, path, releases)
+	}
+
+	for _, addon := range sequenceItems(mappingValue(root, "addons")) {
+		l.checkJobs(addon, fmt.Sprintf("/addons/name=%s", scalarValue(addon, "name")), releases)
+	}
+
+	l.uniqueNames(mappingValue(root, "variables"), "/variables", RuleDuplicateVariable, "variable")
+
+	sort.SliceStable(l.findings, func(i, j int) bool { return l.findings[i].Line < l.findings[j].Line })
+
+	return l.findings, nil
+}
+
+type linter struct {
+	findings []Finding
+}
+
+func (l *linter) add(rule, path string, line int, format string, args ...interface{}) {
+	l.findings = append(l.findings, Finding{
+		Rule:    rule,
+		Path:    path,
+		Line:    line,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *linter) checkJobs(parent *yaml.Node, path string, releases map[string]bool) {
+	jobs := mappingValue(parent, "jobs")
+	l.uniqueNames(jobs, path+"/jobs", RuleDuplicateJob, "job")
+
+	for _, job := range sequenceItems(jobs) {
+		name := scalarValue(job, "name")
+		release := mappingValue(job, "release")
+		if release == nil {
+			continue
+		}
+
+		if !releases[release.Value] {
+			l.add(RuleUndeclaredRelease, fmt.Sprintf("%s/jobs/name=%s/release", path, name), release.Line,
+				"job %q uses release %q, which is not declared under releases", name, release.Value)
+		}
+	}
+}
+
+// uniqueNames reports every repeated name in a list and returns the set of
+// names it contains.
+func (l *linter) uniqueNames(list *yaml.Node, path, rule, kind string) map[string]bool {
+	names := map[string]bool{}
+	for _, item := range sequenceItems(list) {
+		name := scalarValue(item, "name")
+		if names[name] {
+			l.add(rule, fmt.Sprintf("%s/name=%s", path, name), item.Line, "%s %q is declared more than once", kind, name)
+		}
+		names[name] = true
+	}
+
+	return names
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func scalarValue(node *yaml.Node, key string) string {
+	value := mappingValue(node, key)
+	if value == nil || value.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return value.Value
+}
+
+func sequenceItems(node *yaml.Node) []*yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return node.Content
+}
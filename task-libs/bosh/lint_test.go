@@ -0,0 +1,112 @@
+package bosh_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+)
+
+const lintManifest = `---
+name: cf
+releases:
+- name: capi
+  version: 1.2.3
+  url: https://example.com/capi.tgz
+  sha1: abc
+- name: nats
+  version: 0.1.0
+- name: capi
+  version: 1.2.3
+  url: https://example.com/capi.tgz
+  sha1: abc
+stemcells:
+- alias: default
+  os: ubuntu-jammy
+  version: "1.10"
+addons:
+- name: dns
+  jobs:
+  - name: bosh-dns
+    release: bosh-dns
+instance_groups:
+- name: api
+  stemcell: default
+  jobs:
+  - name: cloud_controller_ng
+    release: capi
+  - name: cloud_controller_ng
+    release: capi
+- name: nats
+  stemcell: windows
+  jobs:
+  - name: nats
+    release: nats
+- name: api
+  stemcell: default
+  jobs: []
+variables:
+- name: nats_password
+  type: password
+- name: nats_password
+  type: password
+`
+
+var _ = Describe("Lint", func() {
+	It("finds nothing wrong with a valid manifest", func() {
+		findings, err := Lint([]byte(`
+releases:
+- {name: capi, version: 1.2.3, url: https://example.com/capi.tgz, sha1: abc}
+stemcells:
+- {alias: default, os: ubuntu-jammy, version: "1.10"}
+instance_groups:
+- name: api
+  stemcell: default
+  jobs:
+  - {name: cloud_controller_ng, release: capi}
+variables:
+- {name: cc_password, type: password}
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(findings).To(BeEmpty())
+	})
+
+	It("reports every finding in manifest order", func() {
+		findings, err := Lint([]byte(lintManifest))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(findings).To(Equal([]Finding{
+			{Rule: RuleIncompleteRelease, Path: "/releases/name=nats", Line: 8, Message: `release "nats" does not declare url`},
+			{Rule: RuleIncompleteRelease, Path: "/releases/name=nats", Line: 8, Message: `release "nats" does not declare sha1`},
+			{Rule: RuleDuplicateRelease, Path: "/releases/name=capi", Line: 10, Message: `release "capi" is declared more than once`},
+			{Rule: RuleUndeclaredRelease, Path: "/addons/name=dns/jobs/name=bosh-dns/release", Line: 22, Message: `job "bosh-dns" uses release "bosh-dns", which is not declared under releases`},
+			{Rule: RuleDuplicateJob, Path: "/instance_groups/name=api/jobs/name=cloud_controller_ng", Line: 29, Message: `job "cloud_controller_ng" is declared more than once`},
+			{Rule: RuleUnknownStemcellAlias, Path: "/instance_groups/name=nats/stemcell", Line: 32, Message: `stemcell alias "windows" is not declared under stemcells`},
+			{Rule: RuleDuplicateInstanceGroup, Path: "/instance_groups/name=api", Line: 36, Message: `instance group "api" is declared more than once`},
+			{Rule: RuleDuplicateVariable, Path: "/variables/name=nats_password", Line: 42, Message: `variable "nats_password" is declared more than once`},
+		}))
+		Expect(findings[0].String()).To(Equal(`8: incomplete-release: release "nats" does not declare url (/releases/name=nats)`))
+	})
+
+	It("reports instance groups without a stemcell", func() {
+		findings, err := Lint([]byte(`
+releases:
+- {name: capi, version: 1.2.3, url: https://example.com/capi.tgz, sha1: abc}
+stemcells:
+- {alias: default, os: ubuntu-jammy, version: "1.10"}
+instance_groups:
+- name: api
+  jobs:
+  - {name: cloud_controller_ng, release: capi}
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(findings).To(Equal([]Finding{
+			{Rule: RuleMissingStemcell, Path: "/instance_groups/name=api", Line: 7, Message: `instance group "api" does not declare a stemcell`},
+		}))
+	})
+
+	It("returns an error when the manifest is not a map", func() {
+		_, err := Lint([]byte("- not a manifest\n"))
+		Expect(err).To(MatchError("manifest is not a map"))
+	})
+})
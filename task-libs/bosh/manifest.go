@@ -1,10 +1,13 @@
 package bosh
 
 import (
-	"io"
+	"context"
+	"fmt"
 	"os"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli"
 )
 
 type Manifest struct {
@@ -28,43 +31,62 @@ func NewManifestFromFile(file []byte) (Manifest, error) {
 	return manifest, nil
 }
 
-//go:generate counterfeiter boshCLI
-type boshCLI interface {
-	Cmd(name string, args ...string) (io.Reader, error)
-}
-
-func (m Manifest) Deploy(boshCLI boshCLI) error {
-	m.Update = block{
-		"canaries":          1,
-		"max_in_flight":     1,
-		"canary_watch_time": 1,
-		"update_watch_time": 1,
+// Deploy deploys the manifest with the given options and returns the parsed
+// result of the bosh deploy. The result of a failed deploy has the id of its
+// task when bosh printed one. When ctx is done or the timeout passes, bosh is
+// stopped and the deploy task is cancelled on the Director.
+func (m Manifest) Deploy(ctx context.Context, boshCLI boshcli.BoshCLI, opts DeployOptions) (DeployResult, error) {
+	m.Update = compilationUpdate()
+	if opts.Update != nil {
+		m.Update = block(opts.Update)
+	}
+	if len(m.Stemcells) > 0 && m.Stemcells[0].Alias == "" {
+		// The caller's stemcells are copied so the default alias is not
+		// written into their slice.
+		m.Stemcells = append([]Stemcell(nil), m.Stemcells...)
+		m.Stemcells[0].Alias = "default"
 	}
-	m.Stemcells[0].Alias = "default"
 	manifestFile, err := yaml.Marshal(m)
 	if err != nil {
-		return err
+		return DeployResult{}, err
 	}
 
 	currentDir, err := os.Getwd()
 	if err != nil {
-		return err
+		return DeployResult{}, err
 	}
 	tempFile, err := os.CreateTemp(currentDir, "manifest*.yml")
 	if err != nil {
-		return err
+		return DeployResult{}, err
 	}
 	defer os.Remove(tempFile.Name()) //nolint:errcheck
 
 	err = os.WriteFile(tempFile.Name(), manifestFile, 0644)
 	if err != nil {
-		return err
+		return DeployResult{}, err
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, opts.Timeout, fmt.Errorf("timed out after %s", opts.Timeout))
+		defer cancel()
+	}
+
+	client := boshcli.NewClient(boshCLI).WithContext(ctx)
+	if opts.Progress != nil {
+		defer opts.Progress.Summary()
+		client = client.WithProgress(opts.Progress)
 	}
 
-	_, err = boshCLI.Cmd("deploy", tempFile.Name(), "-d", m.Name, "-n", "--json")
+	output, err := client.Deploy(m.Name, tempFile.Name(), opts.args()...)
 	if err != nil {
-		return err
+		// The output of a failed deploy still names the task that failed.
+		var result DeployResult
+		if output != nil {
+			result, _ = parseDeployOutput(output)
+		}
+		return result, err
 	}
 
-	return nil
+	return parseDeployOutput(output)
 }
@@ -2,15 +2,19 @@ package bosh_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/runtime-ci/task-libs/bosh"
-	"github.com/cloudfoundry/runtime-ci/task-libs/bosh/boshfakes"
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli/boshclifakes"
 )
 
 var _ = Describe("Manifest", func() {
@@ -56,15 +60,19 @@ stemcells:
 		var (
 			manifestArg Manifest
 
-			fakeBoshCLI *boshfakes.FakeBoshCLI
+			fakeBoshCLI *boshclifakes.FakeBoshCLI
+
+			optsArg DeployOptions
 
 			actualManifest []byte
+			actualResult   DeployResult
 			actualError    error
 		)
 
 		BeforeEach(func() {
-			fakeBoshCLI = new(boshfakes.FakeBoshCLI)
-			fakeBoshCLI.CmdStub = func(cmd string, args ...string) (io.Reader, error) {
+			optsArg = DeployOptions{}
+			fakeBoshCLI = new(boshclifakes.FakeBoshCLI)
+			fakeBoshCLI.CmdStub = func(_ context.Context, cmd string, args ...string) (io.Reader, error) {
 				path := args[0]
 
 				var err error
@@ -74,7 +82,7 @@ stemcells:
 		})
 
 		JustBeforeEach(func() {
-			actualError = manifestArg.Deploy(fakeBoshCLI)
+			actualResult, actualError = manifestArg.Deploy(context.Background(), fakeBoshCLI, optsArg)
 		})
 
 		Context("when the manifest is partially filled", func() {
@@ -91,7 +99,7 @@ stemcells:
 
 				Expect(fakeBoshCLI.CmdCallCount()).To(Equal(1), "expected boshCLI call count")
 
-				cmd, args := fakeBoshCLI.CmdArgsForCall(0)
+				_, cmd, args := fakeBoshCLI.CmdArgsForCall(0)
 				Expect(cmd).To(Equal("deploy"), "expected boshCLI command")
 				Expect(args).To(HaveLen(5), "expected boshCLI arg len")
 				Expect(strings.Join(args[1:], " ")).To(Equal("-d cf-compilation -n --json"))
@@ -118,5 +126,150 @@ stemcells:
 `))
 			})
 		})
+
+		Context("when the first stemcell has no alias", func() {
+			var stemcells []Stemcell
+
+			BeforeEach(func() {
+				stemcells = []Stemcell{{OS: "some-os", Version: "1.2.3"}}
+				manifestArg = Manifest{Name: "cf-compilation", Stemcells: stemcells}
+			})
+
+			It("does not write the default alias into the caller's stemcells", func() {
+				Expect(actualError).ToNot(HaveOccurred())
+				Expect(string(actualManifest)).To(ContainSubstring("alias: default"))
+				Expect(stemcells[0].Alias).To(BeEmpty())
+			})
+		})
+
+		Context("when deploy options are provided", func() {
+			BeforeEach(func() {
+				manifestArg = Manifest{
+					Name:      "cf-compilation",
+					Releases:  []Release{{Name: "release-a"}},
+					Stemcells: []Stemcell{{Alias: "jammy", OS: "some-os", Version: "1.2.3"}},
+				}
+				optsArg = DeployOptions{
+					Update:    map[string]interface{}{"canaries": 2, "max_in_flight": 4},
+					OpsFiles:  []string{"ops-a.yml", "ops-b.yml"},
+					Vars:      map[string]string{"system_domain": "example.com", "az": "z1"},
+					Recreate:  true,
+					Fix:       true,
+					SkipDrain: true,
+					DryRun:    true,
+				}
+			})
+
+			It("passes the options to bosh deploy", func() {
+				Expect(actualError).ToNot(HaveOccurred())
+
+				_, _, args := fakeBoshCLI.CmdArgsForCall(0)
+				Expect(strings.Join(args[1:], " ")).To(Equal("-d cf-compilation -n --json -o ops-a.yml -o ops-b.yml -v az=z1 -v system_domain=example.com --recreate --fix --skip-drain --dry-run"))
+			})
+
+			It("uses the custom update block and keeps the stemcell alias", func() {
+				Expect(string(actualManifest)).To(Equal(`name: cf-compilation
+update:
+    canaries: 2
+    max_in_flight: 4
+releases:
+    - name: release-a
+      sha1: ""
+      url: ""
+      version: ""
+stemcells:
+    - alias: jammy
+      os: some-os
+      version: 1.2.3
+`))
+			})
+		})
+
+		Context("when bosh returns task output", func() {
+			BeforeEach(func() {
+				manifestArg = Manifest{
+					Name:      "cf-compilation",
+					Stemcells: []Stemcell{{OS: "some-os", Version: "1.2.3"}},
+				}
+				fakeBoshCLI.CmdReturns(strings.NewReader(`{
+    "Tables": null,
+    "Blocks": [
+        "Task 4321\n",
+        "\nTask 4321 | 10:00:00 | Preparing deployment: Preparing deployment (00:00:01)\n",
+        "Task 4321 | 10:00:05 | Updating instance api: api/1b2c (0) (canary) (00:01:00)\n",
+        "Task 4321 | 10:01:05 | Updating instance api: api/1b2c (0) (canary) (00:01:00)\n",
+        "Task 4321 | 10:02:05 | Creating missing vms: router/9f8e (0) (00:00:40)\n",
+        "Task 4321 | 10:02:05 | Updating instance router: router/9f8e (0) (00:00:30)\n",
+        "\nTask 4321 Started  Mon Jan  1 10:00:00 UTC 2024\nTask 4321 Finished Mon Jan  1 10:03:25 UTC 2024\nTask 4321 Duration 00:03:25\nTask 4321 done\n"
+    ],
+    "Lines": ["Using environment '10.0.0.6' as client 'admin'", "Succeeded"]
+}`), nil)
+				fakeBoshCLI.CmdStub = nil
+			})
+
+			It("returns the task id, duration and changed instances", func() {
+				Expect(actualError).ToNot(HaveOccurred())
+				Expect(actualResult).To(Equal(DeployResult{
+					TaskID:           4321,
+					Duration:         3*time.Minute + 25*time.Second,
+					InstancesChanged: []string{"api/1b2c", "router/9f8e"},
+				}))
+			})
+		})
+
+		Context("when the deploy task fails", func() {
+			BeforeEach(func() {
+				manifestArg = Manifest{
+					Name:      "cf-compilation",
+					Stemcells: []Stemcell{{OS: "some-os", Version: "1.2.3"}},
+				}
+				fakeBoshCLI.CmdReturns(strings.NewReader(`{
+    "Tables": null,
+    "Blocks": [
+        "Task 4321\n",
+        "\nTask 4321 | 10:00:00 | Preparing deployment: Preparing deployment (00:00:01)\n",
+        "Error: Release 'bad' doesn't exist"
+    ]
+}`), errors.New("Error: Release 'bad' doesn't exist"))
+				fakeBoshCLI.CmdStub = nil
+			})
+
+			It("returns the error with the id of the failed task", func() {
+				Expect(actualError).To(MatchError("Error: Release 'bad' doesn't exist"))
+				Expect(actualResult.TaskID).To(Equal(4321))
+			})
+		})
+
+		Context("when the deploy exceeds the timeout", func() {
+			BeforeEach(func() {
+				manifestArg = Manifest{
+					Name:      "cf-compilation",
+					Stemcells: []Stemcell{{OS: "some-os", Version: "1.2.3"}},
+				}
+				optsArg = DeployOptions{Timeout: 10 * time.Millisecond}
+				fakeBoshCLI.CmdStub = func(ctx context.Context, cmd string, args ...string) (io.Reader, error) {
+					switch cmd {
+					case "deploy":
+						<-ctx.Done()
+						return nil, fmt.Errorf("bosh deploy: %w", context.Cause(ctx))
+					case "tasks":
+						return strings.NewReader(`{"Tables":[{"Rows":[{"id":"77","state":"processing","description":"create deployment"}]}]}`), nil
+					}
+					return new(bytes.Buffer), nil
+				}
+			})
+
+			It("stops bosh and cancels the deploy task", func() {
+				Expect(actualError).To(MatchError("bosh deploy: timed out after 10ms (cancelled Director task 77)"))
+
+				Expect(fakeBoshCLI.CmdCallCount()).To(Equal(3))
+				_, cmd, args := fakeBoshCLI.CmdArgsForCall(1)
+				Expect(cmd).To(Equal("tasks"))
+				Expect(args).To(Equal([]string{"-d", "cf-compilation", "--json"}))
+				_, cmd, args = fakeBoshCLI.CmdArgsForCall(2)
+				Expect(cmd).To(Equal("cancel-task"))
+				Expect(args).To(Equal([]string{"77", "--json"}))
+			})
+		})
 	})
 })
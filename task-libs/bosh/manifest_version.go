@@ -0,0 +1,186 @@
+package bosh
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver"
+	"gopkg.in/yaml.v3"
+)
+
+// autoPopulatedMarker is the comment on the manifest properties that are
+// stamped with the manifest version.
+const autoPopulatedMarker = "# AUTO-POPULATED"
+
+// scalarEdit replaces the text of a scalar in the manifest.
+type scalarEdit struct {
+	node  *yaml.Node
+	key   string
+	value string
+}
+
+// StampManifestVersion sets manifest_version to v<version>, every build
+// marked # AUTO-POPULATED to v<version>, and every version marked
+// # AUTO-POPULATED to the major version. Only those values are rewritten, so
+// the rest of the manifest keeps its layout and comments. It fails if
+// manifest_version or either kind of marker is missing, and checks the
+// result before returning it.
+func StampManifestVersion(manifest []byte, version semver.Version) ([]byte, error) {
+	edits, err := manifestVersionEdits(manifest, version)
+	if err != nil {
+		return nil, err
+	}
+
+	// Edits that share a line, as in a flow mapping, are applied right to left
+	// so that the columns of the ones still to come are not shifted.
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].node.Line != edits[j].node.Line {
+			return edits[i].node.Line < edits[j].node.Line
+		}
+		return edits[i].node.Column > edits[j].node.Column
+	})
+
+	lines := strings.SplitAfter(string(manifest), "\n")
+	for _, edit := range edits {
+		lines[edit.node.Line-1], err = replaceScalar(lines[edit.node.Line-1], edit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	stamped := []byte(strings.Join(lines, ""))
+
+	err = verifyManifestVersion(manifest, stamped, version, len(edits))
+	if err != nil {
+		return nil, fmt.Errorf("stamped manifest failed verification: %w", err)
+	}
+
+	return stamped, nil
+}
+
+func manifestVersionEdits(manifest []byte, version semver.Version) ([]scalarEdit, error) {
+	var doc yaml.Node
+	err := yaml.Unmarshal(manifest, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("manifest is not a map")
+	}
+	root := doc.Content[0]
+
+	manifestVersion := mappingValue(root, "manifest_version")
+	if manifestVersion == nil || manifestVersion.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("manifest has no manifest_version")
+	}
+
+	build := "v" + version.String()
+	major := strconv.FormatUint(version.Major, 10)
+
+	edits := []scalarEdit{{node: manifestVersion, key: "manifest_version", value: build}}
+
+	var markerErr error
+	found := map[string]int{}
+	walkMappings(root, func(key, value *yaml.Node) {
+		if value.Kind != yaml.ScalarNode || strings.TrimSpace(value.LineComment) != autoPopulatedMarker {
+			return
+		}
+
+		switch key.Value {
+		case "build":
+			edits = append(edits, scalarEdit{node: value, key: key.Value, value: build})
+		case "version":
+			edits = append(edits, scalarEdit{node: value, key: key.Value, value: major})
+		default:
+			if markerErr == nil {
+				markerErr = fmt.Errorf("line %d: unknown %s property %q", value.Line, autoPopulatedMarker, key.Value)
+			}
+			return
+		}
+		found[key.Value]++
+	})
+	if markerErr != nil {
+		return nil, markerErr
+	}
+
+	for _, key := range []string{"build", "version"} {
+		if found[key] == 0 {
+			return nil, fmt.Errorf("manifest has no %s marked %s", key, autoPopulatedMarker)
+		}
+	}
+
+	return edits, nil
+}
+
+// replaceScalar replaces the scalar of edit in its line, keeping its quoting.
+func replaceScalar(line string, edit scalarEdit) (string, error) {
+	start := edit.node.Column - 1
+
+	var old, replacement string
+	switch edit.node.Style {
+	case 0:
+		old, replacement = edit.node.Value, edit.value
+	case yaml.DoubleQuotedStyle:
+		old, replacement = `"`+edit.node.Value+`"`, `"`+edit.value+`"`
+	case yaml.SingleQuotedStyle:
+		old, replacement = "'"+edit.node.Value+"'", "'"+edit.value+"'"
+	default:
+		return "", fmt.Errorf("line %d: %s must be a plain or quoted scalar", edit.node.Line, edit.key)
+	}
+
+	if start < 0 || start+len(old) > len(line) || line[start:start+len(old)] != old {
+		return "", fmt.Errorf("line %d: could not find %s value %q", edit.node.Line, edit.key, edit.node.Value)
+	}
+
+	return line[:start] + replacement + line[start+len(old):], nil
+}
+
+func verifyManifestVersion(original, stamped []byte, version semver.Version, expectedEdits int) error {
+	edits, err := manifestVersionEdits(stamped, version)
+	if err != nil {
+		return err
+	}
+
+	if len(edits) != expectedEdits {
+		return fmt.Errorf("expected %d stamped values, found %d", expectedEdits, len(edits))
+	}
+
+	for _, edit := range edits {
+		if edit.node.Value != edit.value {
+			return fmt.Errorf("line %d: %s is %q, expected %q", edit.node.Line, edit.key, edit.node.Value, edit.value)
+		}
+	}
+
+	originalLines := strings.Split(string(original), "\n")
+	stampedLines := strings.Split(string(stamped), "\n")
+	if len(originalLines) != len(stampedLines) {
+		return fmt.Errorf("expected %d lines, found %d", len(originalLines), len(stampedLines))
+	}
+
+	stampedLine := map[int]bool{}
+	for _, edit := range edits {
+		stampedLine[edit.node.Line-1] = true
+	}
+	for i := range originalLines {
+		if !stampedLine[i] && originalLines[i] != stampedLines[i] {
+			return fmt.Errorf("line %d changed unexpectedly", i+1)
+		}
+	}
+
+	return nil
+}
+
+// walkMappings calls fn with every key and value of every map in node.
+func walkMappings(node *yaml.Node, fn func(key, value *yaml.Node)) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			fn(node.Content[i], node.Content[i+1])
+		}
+	}
+
+	for _, child := range node.Content {
+		walkMappings(child, fn)
+	}
+}
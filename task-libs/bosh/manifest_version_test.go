@@ -0,0 +1,113 @@
+package bosh_test
+
+import (
+	"github.com/blang/semver"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+)
+
+var _ = Describe("StampManifestVersion", func() {
+	var manifest string
+
+	BeforeEach(func() {
+		manifest = `---
+name: cf
+manifest_version: v45.2.1 # the cf-deployment version
+update:
+  canaries: 1
+
+instance_groups:
+- name: api
+  jobs:
+  - name: cloud_controller_ng
+    properties:
+      cc:
+        # keep this comment
+        info:
+          build:   "v45.2.1" # AUTO-POPULATED
+          version: 45 # AUTO-POPULATED
+          custom:  'untouched'
+      version: 12
+`
+	})
+
+	It("sets manifest_version and every marker without changing anything else", func() {
+		stamped, err := StampManifestVersion([]byte(manifest), semver.MustParse("46.0.0"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(string(stamped)).To(Equal(`---
+name: cf
+manifest_version: v46.0.0 # the cf-deployment version
+update:
+  canaries: 1
+
+instance_groups:
+- name: api
+  jobs:
+  - name: cloud_controller_ng
+    properties:
+      cc:
+        # keep this comment
+        info:
+          build:   "v46.0.0" # AUTO-POPULATED
+          version: 46 # AUTO-POPULATED
+          custom:  'untouched'
+      version: 12
+`))
+	})
+
+	Context("when stamped values share a line", func() {
+		It("stamps a value that follows manifest_version on its line", func() {
+			stamped, err := StampManifestVersion([]byte(`{manifest_version: v9.0.0, info: {build: v9.0.0 # AUTO-POPULATED
+  }, version: 9 # AUTO-POPULATED
+}
+`), semver.MustParse("10.12.0"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(stamped)).To(Equal(`{manifest_version: v10.12.0, info: {build: v10.12.0 # AUTO-POPULATED
+  }, version: 10 # AUTO-POPULATED
+}
+`))
+		})
+
+		It("keeps the quoting of values that shrink", func() {
+			stamped, err := StampManifestVersion([]byte(`{manifest_version: "v10.12.0", info: {build: 'v10.12.0' # AUTO-POPULATED
+  }, version: "10" # AUTO-POPULATED
+}
+`), semver.MustParse("9.0.0"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(stamped)).To(Equal(`{manifest_version: "v9.0.0", info: {build: 'v9.0.0' # AUTO-POPULATED
+  }, version: "9" # AUTO-POPULATED
+}
+`))
+		})
+	})
+
+	It("fails when manifest_version is missing", func() {
+		_, err := StampManifestVersion([]byte("name: cf\n"), semver.MustParse("46.0.0"))
+		Expect(err).To(MatchError("manifest has no manifest_version"))
+	})
+
+	It("fails when a build marker is missing", func() {
+		_, err := StampManifestVersion([]byte("manifest_version: v1.0.0\nversion: 1 # AUTO-POPULATED\n"), semver.MustParse("2.0.0"))
+		Expect(err).To(MatchError("manifest has no build marked # AUTO-POPULATED"))
+	})
+
+	It("fails when a version marker is missing", func() {
+		_, err := StampManifestVersion([]byte("manifest_version: v1.0.0\nbuild: v1.0.0 # AUTO-POPULATED\n"), semver.MustParse("2.0.0"))
+		Expect(err).To(MatchError("manifest has no version marked # AUTO-POPULATED"))
+	})
+
+	It("fails on an unknown marker", func() {
+		_, err := StampManifestVersion([]byte("manifest_version: v1.0.0\nname: v1 # AUTO-POPULATED\n"), semver.MustParse("2.0.0"))
+		Expect(err).To(MatchError(`line 2: unknown # AUTO-POPULATED property "name"`))
+	})
+
+	It("fails on a block scalar marker", func() {
+		_, err := StampManifestVersion([]byte("manifest_version: v1.0.0\nbuild: |- # AUTO-POPULATED\n  v1.0.0\nversion: 1 # AUTO-POPULATED\n"), semver.MustParse("2.0.0"))
+		Expect(err).To(HaveOccurred())
+	})
+})
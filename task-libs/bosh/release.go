@@ -4,6 +4,36 @@ type Release struct {
 	Name     string
 	SHA1     string
 	Stemcell Stemcell `yaml:",omitempty"`
-	URL      string
-	Version  string
+	// ExportedFrom lists every stemcell a compiled release can be used with.
+	// It takes the place of Stemcell when a tarball is valid for several
+	// stemcell versions.
+	ExportedFrom []Stemcell `yaml:"exported_from,omitempty"`
+	URL          string
+	Version      string
+}
+
+// CompiledStemcells returns the stemcells the release is compiled for, or
+// nil for a source release.
+func (r Release) CompiledStemcells() []Stemcell {
+	if len(r.ExportedFrom) > 0 {
+		return r.ExportedFrom
+	}
+
+	if r.Stemcell.OS == "" && r.Stemcell.Version == "" {
+		return nil
+	}
+
+	return []Stemcell{r.Stemcell}
+}
+
+// IsCompiledFor reports whether the release can be used with the stemcell.
+// Aliases are ignored.
+func (r Release) IsCompiledFor(stemcell Stemcell) bool {
+	for _, s := range r.CompiledStemcells() {
+		if s.OS == stemcell.OS && s.Version == stemcell.Version {
+			return true
+		}
+	}
+
+	return false
 }
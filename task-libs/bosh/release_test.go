@@ -0,0 +1,55 @@
+package bosh_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+
+	. "github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+)
+
+var _ = Describe("Release", func() {
+	Describe("CompiledStemcells", func() {
+		It("returns nil for a source release", func() {
+			Expect(Release{Name: "source"}.CompiledStemcells()).To(BeNil())
+		})
+
+		It("returns the stemcell of a release compiled for one stemcell", func() {
+			release := Release{Stemcell: Stemcell{OS: "ubuntu-jammy", Version: "1.10"}}
+			Expect(release.CompiledStemcells()).To(Equal([]Stemcell{{OS: "ubuntu-jammy", Version: "1.10"}}))
+		})
+
+		It("prefers exported_from", func() {
+			var release Release
+			Expect(yaml.Unmarshal([]byte(`
+name: capi
+exported_from:
+- os: ubuntu-jammy
+  version: "1.10"
+- os: ubuntu-jammy
+  version: "1.11"
+`), &release)).To(Succeed())
+
+			Expect(release.CompiledStemcells()).To(Equal([]Stemcell{
+				{OS: "ubuntu-jammy", Version: "1.10"},
+				{OS: "ubuntu-jammy", Version: "1.11"},
+			}))
+		})
+	})
+
+	Describe("IsCompiledFor", func() {
+		release := Release{ExportedFrom: []Stemcell{
+			{OS: "ubuntu-jammy", Version: "1.10"},
+			{OS: "ubuntu-jammy", Version: "1.11"},
+		}}
+
+		It("matches any exported_from stemcell, ignoring aliases", func() {
+			Expect(release.IsCompiledFor(Stemcell{Alias: "default", OS: "ubuntu-jammy", Version: "1.11"})).To(BeTrue())
+		})
+
+		It("does not match other versions or operating systems", func() {
+			Expect(release.IsCompiledFor(Stemcell{OS: "ubuntu-jammy", Version: "1.12"})).To(BeFalse())
+			Expect(release.IsCompiledFor(Stemcell{OS: "ubuntu-noble", Version: "1.10"})).To(BeFalse())
+		})
+	})
+})
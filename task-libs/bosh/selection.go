@@ -0,0 +1,68 @@
+package bosh
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// SelectionFile is the file deploy-all-releases writes the releases it
+// selected for compilation to, and export-all-compiled-release-tarballs reads
+// to only export those releases.
+const SelectionFile = "selection.json"
+
+// SelectedRelease is a release of a Selection and why it was selected or
+// skipped.
+type SelectedRelease struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Reason  string `json:"reason"`
+}
+
+func (r SelectedRelease) String() string {
+	return fmt.Sprintf("%s/%s: %s", r.Name, r.Version, r.Reason)
+}
+
+// Selection records which releases need a compilation deployment for a
+// stemcell and which already have a compiled release.
+type Selection struct {
+	StemcellOS      string            `json:"stemcell_os"`
+	StemcellVersion string            `json:"stemcell_version"`
+	Selected        []SelectedRelease `json:"selected"`
+	Skipped         []SelectedRelease `json:"skipped"`
+}
+
+// SelectedNames returns the names of the selected releases.
+func (s Selection) SelectedNames() []string {
+	names := []string{}
+	for _, release := range s.Selected {
+		names = append(names, release.Name)
+	}
+	return names
+}
+
+// ReadSelection reads a selection file.
+func ReadSelection(path string) (Selection, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Selection{}, err
+	}
+
+	var selection Selection
+	err = json.Unmarshal(content, &selection)
+	if err != nil {
+		return Selection{}, fmt.Errorf("failed to parse selection %s: %w", path, err)
+	}
+
+	return selection, nil
+}
+
+// WriteSelection writes a selection file.
+func WriteSelection(path string, selection Selection) error {
+	content, err := json.MarshalIndent(selection, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
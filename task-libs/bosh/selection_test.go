@@ -0,0 +1,50 @@
+package bosh_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+)
+
+var _ = Describe("Selection", func() {
+	var path string
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), SelectionFile)
+	})
+
+	It("writes a selection and reads it back", func() {
+		selection := Selection{
+			StemcellOS:      "ubuntu-jammy",
+			StemcellVersion: "1.2",
+			Selected:        []SelectedRelease{{Name: "capi", Version: "1.2.3", Reason: "no compiled release"}},
+			Skipped:         []SelectedRelease{},
+		}
+		Expect(WriteSelection(path, selection)).To(Succeed())
+
+		content, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(MatchJSON(`{
+			"stemcell_os": "ubuntu-jammy",
+			"stemcell_version": "1.2",
+			"selected": [{"name": "capi", "version": "1.2.3", "reason": "no compiled release"}],
+			"skipped": []
+		}`))
+
+		read, err := ReadSelection(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(Equal(selection))
+		Expect(read.SelectedNames()).To(Equal([]string{"capi"}))
+	})
+
+	It("rejects an invalid selection", func() {
+		Expect(os.WriteFile(path, []byte("not json"), 0644)).To(Succeed())
+
+		_, err := ReadSelection(path)
+		Expect(err).To(MatchError(ContainSubstring("failed to parse selection")))
+	})
+})
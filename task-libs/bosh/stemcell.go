@@ -2,12 +2,12 @@ package bosh
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 	"regexp"
 	"strings"
 
 	"github.com/blang/semver"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/concourse"
 )
 
 type Stemcell struct {
@@ -20,18 +20,18 @@ type Stemcell struct {
 func NewStemcellFromInput(stemcellDir string) (Stemcell, error) {
 	var stemcell Stemcell
 
-	version, err := readFile(filepath.Join(stemcellDir, "version"))
+	version, err := concourse.ReadVersion(stemcellDir)
 	if err != nil {
 		return stemcell, err
 	}
-	stemcell.Version = strings.Trim(version, "\n")
+	stemcell.Version = version
 
-	url, err := readFile(filepath.Join(stemcellDir, "url"))
+	url, err := concourse.ReadURL(stemcellDir)
 	if err != nil {
 		return stemcell, err
 	}
 
-	stemcell.OS, err = parseOSfromURL(url)
+	stemcell.OS, err = ParseOSFromURL(url)
 	if err != nil {
 		return stemcell, err
 	}
@@ -39,7 +39,8 @@ func NewStemcellFromInput(stemcellDir string) (Stemcell, error) {
 	return stemcell, nil
 }
 
-func parseOSfromURL(url string) (string, error) {
+// ParseOSFromURL finds the OS of an ubuntu stemcell in its download URL.
+func ParseOSFromURL(url string) (string, error) {
 	versionRegex := regexp.MustCompile(`(ubuntu-[a-z]+(?:-[a-z]+)*?)(?:-go_agent)?\.tgz`)
 
 	allMatches := versionRegex.FindAllStringSubmatch(url, 1)
@@ -52,15 +53,6 @@ func parseOSfromURL(url string) (string, error) {
 	return osMatch, nil
 }
 
-func readFile(path string) (string, error) {
-	content, err := os.ReadFile(path)
-	if os.IsNotExist(err) {
-		return "", fmt.Errorf("missing files: %T", err)
-	}
-
-	return string(content), err
-}
-
 func (s Stemcell) CompareVersion(base Stemcell) (int, error) {
 	if s.OS != base.OS {
 		return 0, fmt.Errorf("stemcell OS mismatch: %q vs %q", s.OS, base.OS)
@@ -81,6 +73,15 @@ func (s Stemcell) CompareVersion(base Stemcell) (int, error) {
 
 func (s Stemcell) DetectBumpTypeFrom(base Stemcell) (string, error) {
 	if s.OS != base.OS {
+		result, err := CompareStemcellOS(s.OS, base.OS)
+		if err != nil {
+			return "", err
+		}
+
+		if result < 0 {
+			return "", fmt.Errorf("change from %s to %s is an OS regression", base.OS, s.OS)
+		}
+
 		return "major", nil
 	}
 
@@ -0,0 +1,166 @@
+package bosh
+
+import (
+	"fmt"
+
+	"github.com/blang/semver"
+)
+
+// Bump types, from the largest to the smallest change.
+const (
+	BumpMajor = "major"
+	BumpMinor = "minor"
+	BumpPatch = "patch"
+	BumpNone  = "none"
+)
+
+var bumpOrder = map[string]int{BumpNone: 0, BumpPatch: 1, BumpMinor: 2, BumpMajor: 3}
+
+// StemcellVersion is a stemcell OS and version as reported in a StemcellBump.
+type StemcellVersion struct {
+	OS      string `json:"os"`
+	Version string `json:"version"`
+}
+
+// StemcellBump is the change of the stemcell of a manifest alias. New is nil
+// when no new stemcell applies to the alias.
+type StemcellBump struct {
+	Alias    string           `json:"alias"`
+	OS       string           `json:"os"`
+	Old      StemcellVersion  `json:"old"`
+	New      *StemcellVersion `json:"new"`
+	BumpType string           `json:"bump_type"`
+}
+
+// BumpTypeFrom classifies the change from base to s. Unlike
+// DetectBumpTypeFrom, a change that is not a forward bump is BumpNone rather
+// than an error. A newer OS of the same line is a major bump.
+func (s Stemcell) BumpTypeFrom(base Stemcell) (string, error) {
+	if s.OS != base.OS {
+		result, err := CompareStemcellOS(s.OS, base.OS)
+		if err != nil {
+			return "", err
+		}
+
+		if result > 0 {
+			return BumpMajor, nil
+		}
+		return BumpNone, nil
+	}
+
+	version, err := semver.ParseTolerant(s.Version)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse stemcell version %q: %w", s.Version, err)
+	}
+
+	baseVersion, err := semver.ParseTolerant(base.Version)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse stemcell version %q: %w", base.Version, err)
+	}
+
+	switch {
+	case version.LTE(baseVersion):
+		return BumpNone, nil
+	case version.Major > baseVersion.Major:
+		return BumpMajor, nil
+	case version.Major == baseVersion.Major && version.Minor > baseVersion.Minor:
+		return BumpMinor, nil
+	default:
+		return BumpPatch, nil
+	}
+}
+
+// StemcellBumps evaluates every stemcell alias of a manifest against the new
+// stemcells. An alias takes the new stemcell of its OS or, failing that, the
+// newest one of a newer OS in the same line that no other alias uses. Each
+// new stemcell must be for a different OS.
+func StemcellBumps(manifestStemcells, newStemcells []Stemcell) ([]StemcellBump, error) {
+	newOS := map[string]bool{}
+	for _, stemcell := range newStemcells {
+		if newOS[stemcell.OS] {
+			return nil, fmt.Errorf("more than one new stemcell is for %s", stemcell.OS)
+		}
+		newOS[stemcell.OS] = true
+	}
+
+	aliasOS := map[string]bool{}
+	for _, stemcell := range manifestStemcells {
+		aliasOS[stemcell.OS] = true
+	}
+
+	var bumps []StemcellBump
+	for _, old := range manifestStemcells {
+		bump := StemcellBump{
+			Alias:    old.Alias,
+			OS:       old.OS,
+			Old:      StemcellVersion{OS: old.OS, Version: old.Version},
+			BumpType: BumpNone,
+		}
+
+		stemcell, ok := matchStemcell(old, newStemcells, aliasOS)
+		if ok {
+			bumpType, err := stemcell.BumpTypeFrom(old)
+			if err != nil {
+				return nil, fmt.Errorf("stemcell alias %q: %w", old.Alias, err)
+			}
+
+			bump.OS = stemcell.OS
+			bump.New = &StemcellVersion{OS: stemcell.OS, Version: stemcell.Version}
+			bump.BumpType = bumpType
+		}
+
+		bumps = append(bumps, bump)
+	}
+
+	return bumps, nil
+}
+
+// LargestBump returns the largest bump type of bumps, or BumpNone.
+func LargestBump(bumps []StemcellBump) string {
+	largest := BumpNone
+	for _, bump := range bumps {
+		if bumpOrder[bump.BumpType] > bumpOrder[largest] {
+			largest = bump.BumpType
+		}
+	}
+	return largest
+}
+
+func matchStemcell(old Stemcell, candidates []Stemcell, aliasOS map[string]bool) (Stemcell, bool) {
+	for _, candidate := range candidates {
+		if candidate.OS == old.OS {
+			return candidate, true
+		}
+	}
+
+	oldOS, err := LookupStemcellOS(old.OS)
+	if err != nil {
+		return Stemcell{}, false
+	}
+
+	var (
+		match      Stemcell
+		generation int
+	)
+	for _, candidate := range candidates {
+		if aliasOS[candidate.OS] {
+			continue
+		}
+
+		candidateOS, err := LookupStemcellOS(candidate.OS)
+		if err != nil {
+			continue
+		}
+
+		result, err := candidateOS.CompareLineage(oldOS)
+		if err != nil || result <= 0 {
+			continue
+		}
+
+		if candidateOS.Generation > generation {
+			match, generation = candidate, candidateOS.Generation
+		}
+	}
+
+	return match, generation > 0
+}
@@ -0,0 +1,103 @@
+package bosh_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+)
+
+var _ = Describe("StemcellBump", func() {
+	Describe("BumpTypeFrom", func() {
+		DescribeTable("classifies the change from the base stemcell",
+			func(base, target Stemcell, expected string) {
+				bumpType, err := target.BumpTypeFrom(base)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(bumpType).To(Equal(expected))
+			},
+			Entry("major", Stemcell{OS: "ubuntu-jammy", Version: "1.10"}, Stemcell{OS: "ubuntu-jammy", Version: "2.0"}, BumpMajor),
+			Entry("minor", Stemcell{OS: "ubuntu-jammy", Version: "1.10"}, Stemcell{OS: "ubuntu-jammy", Version: "1.11"}, BumpMinor),
+			Entry("patch", Stemcell{OS: "ubuntu-jammy", Version: "1.10"}, Stemcell{OS: "ubuntu-jammy", Version: "1.10.1"}, BumpPatch),
+			Entry("same version", Stemcell{OS: "ubuntu-jammy", Version: "1.10"}, Stemcell{OS: "ubuntu-jammy", Version: "1.10"}, BumpNone),
+			Entry("older version", Stemcell{OS: "ubuntu-jammy", Version: "1.10"}, Stemcell{OS: "ubuntu-jammy", Version: "1.9"}, BumpNone),
+			Entry("newer OS", Stemcell{OS: "ubuntu-jammy", Version: "1.10"}, Stemcell{OS: "ubuntu-noble", Version: "1.0"}, BumpMajor),
+			Entry("older OS", Stemcell{OS: "ubuntu-noble", Version: "1.0"}, Stemcell{OS: "ubuntu-jammy", Version: "1.10"}, BumpNone),
+			Entry("newer windows OS", Stemcell{OS: "windows2016", Version: "1709.10"}, Stemcell{OS: "windows2019", Version: "2019.2"}, BumpMajor),
+		)
+
+		It("returns an error for an unparseable version", func() {
+			_, err := Stemcell{OS: "ubuntu-jammy", Version: "latest"}.BumpTypeFrom(Stemcell{OS: "ubuntu-jammy", Version: "1.10"})
+			Expect(err).To(MatchError(ContainSubstring(`failed to parse stemcell version "latest"`)))
+		})
+	})
+
+	Describe("StemcellBumps", func() {
+		var manifestStemcells []Stemcell
+
+		BeforeEach(func() {
+			manifestStemcells = []Stemcell{
+				{Alias: "default", OS: "ubuntu-jammy", Version: "1.10"},
+				{Alias: "noble", OS: "ubuntu-noble", Version: "1.5"},
+			}
+		})
+
+		It("evaluates every alias against the stemcell of its OS", func() {
+			bumps, err := StemcellBumps(manifestStemcells, []Stemcell{
+				{OS: "ubuntu-noble", Version: "1.5.1"},
+				{OS: "ubuntu-jammy", Version: "1.11"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(bumps).To(Equal([]StemcellBump{
+				{
+					Alias:    "default",
+					OS:       "ubuntu-jammy",
+					Old:      StemcellVersion{OS: "ubuntu-jammy", Version: "1.10"},
+					New:      &StemcellVersion{OS: "ubuntu-jammy", Version: "1.11"},
+					BumpType: BumpMinor,
+				},
+				{
+					Alias:    "noble",
+					OS:       "ubuntu-noble",
+					Old:      StemcellVersion{OS: "ubuntu-noble", Version: "1.5"},
+					New:      &StemcellVersion{OS: "ubuntu-noble", Version: "1.5.1"},
+					BumpType: BumpPatch,
+				},
+			}))
+			Expect(LargestBump(bumps)).To(Equal(BumpMinor))
+		})
+
+		It("reports aliases without a new stemcell as none", func() {
+			bumps, err := StemcellBumps(manifestStemcells, []Stemcell{{OS: "ubuntu-noble", Version: "1.5"}})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(bumps[0].New).To(BeNil())
+			Expect(bumps[0].BumpType).To(Equal(BumpNone))
+			Expect(bumps[1].BumpType).To(Equal(BumpNone))
+			Expect(LargestBump(bumps)).To(Equal(BumpNone))
+		})
+
+		It("moves an alias to a newer OS that no other alias uses", func() {
+			bumps, err := StemcellBumps(manifestStemcells[:1], []Stemcell{{OS: "ubuntu-noble", Version: "1.0"}})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(bumps).To(Equal([]StemcellBump{{
+				Alias:    "default",
+				OS:       "ubuntu-noble",
+				Old:      StemcellVersion{OS: "ubuntu-jammy", Version: "1.10"},
+				New:      &StemcellVersion{OS: "ubuntu-noble", Version: "1.0"},
+				BumpType: BumpMajor,
+			}}))
+		})
+
+		It("returns an error for several new stemcells of the same OS", func() {
+			_, err := StemcellBumps(manifestStemcells, []Stemcell{{OS: "ubuntu-noble", Version: "1.6"}, {OS: "ubuntu-noble", Version: "1.7"}})
+			Expect(err).To(MatchError("more than one new stemcell is for ubuntu-noble"))
+		})
+
+		It("returns an error naming the alias for an unparseable version", func() {
+			_, err := StemcellBumps(manifestStemcells, []Stemcell{{OS: "ubuntu-jammy", Version: "latest"}})
+			Expect(err).To(MatchError(ContainSubstring(`stemcell alias "default": failed to parse stemcell version "latest"`)))
+		})
+	})
+})
@@ -0,0 +1,111 @@
+package bosh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StemcellOS describes an operating system that stemcells are published for.
+// Lines group the releases of an OS family that can replace each other, and
+// Generation orders the releases within a line (e.g. xenial < bionic).
+type StemcellOS struct {
+	Name       string
+	Family     string
+	Line       string
+	Generation int
+	FIPS       bool
+}
+
+var stemcellOSCatalogue = []StemcellOS{
+	{Name: "ubuntu-xenial", Family: "ubuntu", Line: "ubuntu", Generation: 1},
+	{Name: "ubuntu-bionic", Family: "ubuntu", Line: "ubuntu", Generation: 2},
+	{Name: "ubuntu-jammy", Family: "ubuntu", Line: "ubuntu", Generation: 3},
+	{Name: "ubuntu-noble", Family: "ubuntu", Line: "ubuntu", Generation: 4},
+
+	{Name: "ubuntu-xenial-fips", Family: "ubuntu", Line: "ubuntu-fips", Generation: 1, FIPS: true},
+	{Name: "ubuntu-bionic-fips", Family: "ubuntu", Line: "ubuntu-fips", Generation: 2, FIPS: true},
+	{Name: "ubuntu-jammy-fips", Family: "ubuntu", Line: "ubuntu-fips", Generation: 3, FIPS: true},
+	{Name: "ubuntu-noble-fips", Family: "ubuntu", Line: "ubuntu-fips", Generation: 4, FIPS: true},
+
+	{Name: "windows2012R2", Family: "windows", Line: "windows", Generation: 1},
+	{Name: "windows2016", Family: "windows", Line: "windows", Generation: 2},
+	{Name: "windows1803", Family: "windows", Line: "windows", Generation: 3},
+	{Name: "windows2019", Family: "windows", Line: "windows", Generation: 4},
+}
+
+// StemcellOSes returns every operating system in the catalogue.
+func StemcellOSes() []StemcellOS {
+	oses := make([]StemcellOS, len(stemcellOSCatalogue))
+	copy(oses, stemcellOSCatalogue)
+	return oses
+}
+
+// LookupStemcellOS finds an operating system in the catalogue by name.
+func LookupStemcellOS(name string) (StemcellOS, error) {
+	name = strings.TrimSpace(name)
+	for _, stemcellOS := range stemcellOSCatalogue {
+		if stemcellOS.Name == name {
+			return stemcellOS, nil
+		}
+	}
+
+	return StemcellOS{}, fmt.Errorf("unsupported stemcell OS %q", name)
+}
+
+// IsSupportedStemcellOS reports whether the catalogue knows the named OS.
+func IsSupportedStemcellOS(name string) bool {
+	_, err := LookupStemcellOS(name)
+	return err == nil
+}
+
+// ValidateStemcellOSes returns an error when a stemcell's OS is not in the
+// catalogue, or when two of the stemcells are for the same OS.
+func ValidateStemcellOSes(stemcells ...Stemcell) error {
+	seen := map[string]bool{}
+	for _, stemcell := range stemcells {
+		_, err := LookupStemcellOS(stemcell.OS)
+		if err != nil {
+			return err
+		}
+
+		if seen[stemcell.OS] {
+			return fmt.Errorf("more than one stemcell is for %s", stemcell.OS)
+		}
+		seen[stemcell.OS] = true
+	}
+
+	return nil
+}
+
+// CompareLineage orders two operating systems of the same line. It returns
+// -1, 0 or 1 if o is older than, the same as or newer than base.
+func (o StemcellOS) CompareLineage(base StemcellOS) (int, error) {
+	if o.Line != base.Line {
+		return 0, fmt.Errorf("stemcell OS %q is not in the same line as %q", o.Name, base.Name)
+	}
+
+	switch {
+	case o.Generation < base.Generation:
+		return -1, nil
+	case o.Generation > base.Generation:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// CompareStemcellOS looks up both operating systems in the catalogue and
+// orders them by lineage.
+func CompareStemcellOS(name, baseName string) (int, error) {
+	stemcellOS, err := LookupStemcellOS(name)
+	if err != nil {
+		return 0, err
+	}
+
+	baseOS, err := LookupStemcellOS(baseName)
+	if err != nil {
+		return 0, err
+	}
+
+	return stemcellOS.CompareLineage(baseOS)
+}
@@ -0,0 +1,85 @@
+package bosh_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+)
+
+var _ = Describe("StemcellOS", func() {
+	Describe("LookupStemcellOS", func() {
+		It("finds ubuntu, fips and windows operating systems", func() {
+			jammy, err := LookupStemcellOS("ubuntu-jammy")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jammy.Family).To(Equal("ubuntu"))
+			Expect(jammy.FIPS).To(BeFalse())
+
+			fips, err := LookupStemcellOS("ubuntu-jammy-fips")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fips.FIPS).To(BeTrue())
+
+			windows, err := LookupStemcellOS("windows2019")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(windows.Family).To(Equal("windows"))
+		})
+
+		It("returns an error for an unknown OS", func() {
+			_, err := LookupStemcellOS("ubuntu-trusty")
+			Expect(err).To(MatchError("unsupported stemcell OS \"ubuntu-trusty\""))
+			Expect(IsSupportedStemcellOS("ubuntu-trusty")).To(BeFalse())
+		})
+	})
+
+	Describe("CompareStemcellOS", func() {
+		It("orders the ubuntu lineage", func() {
+			lineage := []string{"ubuntu-xenial", "ubuntu-bionic", "ubuntu-jammy", "ubuntu-noble"}
+			for i := 1; i < len(lineage); i++ {
+				result, err := CompareStemcellOS(lineage[i], lineage[i-1])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(Equal(1))
+
+				result, err = CompareStemcellOS(lineage[i-1], lineage[i])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(Equal(-1))
+			}
+		})
+
+		It("returns 0 for the same OS", func() {
+			result, err := CompareStemcellOS("ubuntu-jammy-fips", "ubuntu-jammy-fips")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(0))
+		})
+
+		It("orders the windows lineage", func() {
+			result, err := CompareStemcellOS("windows2019", "windows2016")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(1))
+
+			result, err = CompareStemcellOS("windows2012R2", "windows1803")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(-1))
+		})
+
+		It("returns an error across lines", func() {
+			_, err := CompareStemcellOS("ubuntu-jammy-fips", "ubuntu-jammy")
+			Expect(err).To(MatchError("stemcell OS \"ubuntu-jammy-fips\" is not in the same line as \"ubuntu-jammy\""))
+		})
+	})
+
+	Describe("ValidateStemcellOSes", func() {
+		It("accepts stemcells for different operating systems of the catalogue", func() {
+			Expect(ValidateStemcellOSes(Stemcell{OS: "ubuntu-jammy", Version: "1.1"}, Stemcell{OS: "ubuntu-noble", Version: "1.5"})).To(Succeed())
+		})
+
+		It("refuses an unsupported OS", func() {
+			err := ValidateStemcellOSes(Stemcell{OS: "ubuntu-jammy", Version: "1.1"}, Stemcell{OS: "ubuntu-some-os", Version: "1.1"})
+			Expect(err).To(MatchError("unsupported stemcell OS \"ubuntu-some-os\""))
+		})
+
+		It("refuses several stemcells for the same OS", func() {
+			err := ValidateStemcellOSes(Stemcell{OS: "ubuntu-jammy", Version: "1.1"}, Stemcell{OS: "ubuntu-jammy", Version: "1.2"})
+			Expect(err).To(MatchError("more than one stemcell is for ubuntu-jammy"))
+		})
+	})
+})
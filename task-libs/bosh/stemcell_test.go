@@ -209,13 +209,13 @@ var _ = Describe("Stemcell", func() {
 		})
 
 		Context("is a bump to a new OS", func() {
-			It("returns \"major\" when target stemcell's OS changes", func() {
+			It("returns \"major\" when target stemcell's OS is newer in the same line", func() {
 				targetStemcell = Stemcell{
-					OS:      "new-os",
+					OS:      "ubuntu-noble",
 					Version: "1.1",
 				}
 				baseStemcell = Stemcell{
-					OS:      "whatever",
+					OS:      "ubuntu-jammy",
 					Version: "460.0",
 				}
 
@@ -224,6 +224,48 @@ var _ = Describe("Stemcell", func() {
 				Expect(actualErr).NotTo(HaveOccurred())
 				Expect(actualResult).To(Equal("major"))
 			})
+
+			It("returns an OS regression error when target stemcell's OS is older", func() {
+				targetStemcell = Stemcell{
+					OS:      "ubuntu-bionic",
+					Version: "1.100",
+				}
+				baseStemcell = Stemcell{
+					OS:      "ubuntu-jammy",
+					Version: "1.1",
+				}
+
+				_, actualErr := targetStemcell.DetectBumpTypeFrom(baseStemcell)
+				Expect(actualErr).To(MatchError("change from ubuntu-jammy to ubuntu-bionic is an OS regression"))
+			})
+
+			It("returns an error when the OSes are in different lines", func() {
+				targetStemcell = Stemcell{
+					OS:      "ubuntu-noble-fips",
+					Version: "1.1",
+				}
+				baseStemcell = Stemcell{
+					OS:      "ubuntu-jammy",
+					Version: "1.1",
+				}
+
+				_, actualErr := targetStemcell.DetectBumpTypeFrom(baseStemcell)
+				Expect(actualErr).To(MatchError("stemcell OS \"ubuntu-noble-fips\" is not in the same line as \"ubuntu-jammy\""))
+			})
+
+			It("returns an error when an OS is not in the catalogue", func() {
+				targetStemcell = Stemcell{
+					OS:      "new-os",
+					Version: "1.1",
+				}
+				baseStemcell = Stemcell{
+					OS:      "ubuntu-jammy",
+					Version: "460.0",
+				}
+
+				_, actualErr := targetStemcell.DetectBumpTypeFrom(baseStemcell)
+				Expect(actualErr).To(MatchError("unsupported stemcell OS \"new-os\""))
+			})
 		})
 	})
 })
@@ -1,19 +1,21 @@
 // Code generated by counterfeiter. DO NOT EDIT.
-package deploymentfakes
+package boshclifakes
 
 import (
+	context "context"
 	io "io"
 	sync "sync"
 
-	deployment "github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/deployment"
+	boshcli "github.com/cloudfoundry/runtime-ci/task-libs/boshcli"
 )
 
 type FakeBoshCLI struct {
-	CmdStub        func(string, ...string) (io.Reader, error)
+	CmdStub        func(context.Context, string, ...string) (io.Reader, error)
 	cmdMutex       sync.RWMutex
 	cmdArgsForCall []struct {
-		arg1 string
-		arg2 []string
+		arg1 context.Context
+		arg2 string
+		arg3 []string
 	}
 	cmdReturns struct {
 		result1 io.Reader
@@ -27,17 +29,18 @@ type FakeBoshCLI struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeBoshCLI) Cmd(arg1 string, arg2 ...string) (io.Reader, error) {
+func (fake *FakeBoshCLI) Cmd(arg1 context.Context, arg2 string, arg3 ...string) (io.Reader, error) {
 	fake.cmdMutex.Lock()
 	ret, specificReturn := fake.cmdReturnsOnCall[len(fake.cmdArgsForCall)]
 	fake.cmdArgsForCall = append(fake.cmdArgsForCall, struct {
-		arg1 string
-		arg2 []string
-	}{arg1, arg2})
-	fake.recordInvocation("Cmd", []interface{}{arg1, arg2})
+		arg1 context.Context
+		arg2 string
+		arg3 []string
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("Cmd", []interface{}{arg1, arg2, arg3})
 	fake.cmdMutex.Unlock()
 	if fake.CmdStub != nil {
-		return fake.CmdStub(arg1, arg2...)
+		return fake.CmdStub(arg1, arg2, arg3...)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -52,17 +55,17 @@ func (fake *FakeBoshCLI) CmdCallCount() int {
 	return len(fake.cmdArgsForCall)
 }
 
-func (fake *FakeBoshCLI) CmdCalls(stub func(string, ...string) (io.Reader, error)) {
+func (fake *FakeBoshCLI) CmdCalls(stub func(context.Context, string, ...string) (io.Reader, error)) {
 	fake.cmdMutex.Lock()
 	defer fake.cmdMutex.Unlock()
 	fake.CmdStub = stub
 }
 
-func (fake *FakeBoshCLI) CmdArgsForCall(i int) (string, []string) {
+func (fake *FakeBoshCLI) CmdArgsForCall(i int) (context.Context, string, []string) {
 	fake.cmdMutex.RLock()
 	defer fake.cmdMutex.RUnlock()
 	argsForCall := fake.cmdArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *FakeBoshCLI) CmdReturns(result1 io.Reader, result2 error) {
@@ -115,4 +118,4 @@ func (fake *FakeBoshCLI) recordInvocation(key string, args []interface{}) {
 	fake.invocations[key] = append(fake.invocations[key], args)
 }
 
-var _ deployment.BoshCLI = new(FakeBoshCLI)
+var _ boshcli.BoshCLI = new(FakeBoshCLI)
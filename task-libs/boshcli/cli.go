@@ -0,0 +1,182 @@
+package boshcli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultGracePeriod is how long bosh has to exit after it is sent SIGTERM
+// before it is killed.
+const DefaultGracePeriod = 5 * time.Second
+
+// Environment targets a BOSH Director. Empty fields fall back to the BOSH_*
+// variables already set in the process environment.
+type Environment struct {
+	Address      string
+	Client       string
+	ClientSecret string
+	CACert       string
+	Deployment   string
+}
+
+// EnvironmentFromEnv reads the BOSH_* variables from the process environment.
+func EnvironmentFromEnv() Environment {
+	return Environment{
+		Address:      os.Getenv("BOSH_ENVIRONMENT"),
+		Client:       os.Getenv("BOSH_CLIENT"),
+		ClientSecret: os.Getenv("BOSH_CLIENT_SECRET"),
+		CACert:       os.Getenv("BOSH_CA_CERT"),
+		Deployment:   os.Getenv("BOSH_DEPLOYMENT"),
+	}
+}
+
+func (e Environment) vars() []string {
+	var vars []string
+	for name, value := range map[string]string{
+		"BOSH_ENVIRONMENT":   e.Address,
+		"BOSH_CLIENT":        e.Client,
+		"BOSH_CLIENT_SECRET": e.ClientSecret,
+		"BOSH_CA_CERT":       e.CACert,
+		"BOSH_DEPLOYMENT":    e.Deployment,
+	} {
+		if value != "" {
+			vars = append(vars, name+"="+value)
+		}
+	}
+
+	return vars
+}
+
+// CLI runs commands with the bosh binary.
+type CLI struct {
+	// Path to the bosh binary. Defaults to "bosh" on the PATH.
+	Path string
+	Env  Environment
+	// GracePeriod is how long bosh has to exit after it is sent SIGTERM.
+	// Defaults to DefaultGracePeriod.
+	GracePeriod time.Duration
+}
+
+// NewCLI creates a CLI that targets the given environment.
+func NewCLI(env Environment) CLI {
+	return CLI{Env: env}
+}
+
+// Cmd runs a bosh command. When ctx is done before the command exits, bosh
+// is sent SIGTERM, killed if it is still running after the grace period, and
+// an error wrapping the cause of ctx is returned. When bosh fails, its
+// output is returned along with the error.
+func (cli CLI) Cmd(ctx context.Context, name string, args ...string) (io.Reader, error) {
+	path := cli.Path
+	if path == "" {
+		path = "bosh"
+	}
+
+	gracePeriod := cli.GracePeriod
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultGracePeriod
+	}
+
+	boshArgs := append([]string{name}, args...)
+	cmd := exec.CommandContext(ctx, path, boshArgs...)
+	cmd.Env = append(os.Environ(), cli.Env.vars()...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = gracePeriod
+
+	outBuf := new(bytes.Buffer)
+	errBuf := new(bytes.Buffer)
+	cmd.Stdout = outBuf
+	cmd.Stderr = errBuf
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("bosh %s: %w", name, context.Cause(ctx))
+	}
+	if err != nil {
+		return bytes.NewReader(outBuf.Bytes()), parseErr(bytes.NewReader(outBuf.Bytes()), err)
+	}
+
+	return outBuf, nil
+}
+
+// Error is returned when bosh exits with an error. TaskID and TaskState are
+// set when a Director task did not finish successfully, and StatusCode when
+// the Director answered a request with an unsuccessful status. Unreachable
+// is set when a request did not get an answer from the Director at all.
+type Error struct {
+	Message     string
+	TaskID      int
+	TaskState   string
+	StatusCode  int
+	Unreachable bool
+}
+
+func (e Error) Error() string {
+	return e.Message
+}
+
+var (
+	taskStatePattern  = regexp.MustCompile(`(?m)^Task (\d+) (error|cancelled|timeout)$`)
+	statusCodePattern = regexp.MustCompile(`non-successful status code '(\d+)`)
+)
+
+func parseErr(r io.Reader, runErr error) error {
+	var output struct {
+		Blocks []string
+		Lines  []string
+	}
+
+	err := json.NewDecoder(r).Decode(&output)
+	if err != nil {
+		return err
+	}
+
+	cliErr := Error{Message: runErr.Error()}
+	if len(output.Blocks) > 0 {
+		for _, block := range output.Blocks {
+			if strings.HasPrefix(block, "Error:") {
+				cliErr.Message = block
+				break
+			}
+		}
+	} else {
+		var errLines []string
+		for _, line := range output.Lines {
+			if strings.HasPrefix(line, "Using environment") {
+				continue
+			}
+			if strings.HasPrefix(line, "Exit code") {
+				continue
+			}
+
+			errLines = append(errLines, line)
+		}
+		cliErr.Message = strings.Join(errLines, "\n")
+	}
+
+	text := strings.Join(append(output.Blocks, output.Lines...), "\n")
+	if match := taskStatePattern.FindStringSubmatch(text); match != nil {
+		cliErr.TaskID, _ = strconv.Atoi(match[1])
+		cliErr.TaskState = match[2]
+	}
+	if match := statusCodePattern.FindStringSubmatch(text); match != nil {
+		cliErr.StatusCode, _ = strconv.Atoi(match[1])
+	}
+	// bosh reports requests that failed before the Director answered, such
+	// as refused connections, as failures to perform the request.
+	cliErr.Unreachable = cliErr.StatusCode == 0 && strings.Contains(text, "Performing request")
+
+	return cliErr
+}
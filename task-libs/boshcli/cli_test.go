@@ -0,0 +1,189 @@
+package boshcli
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("parseErr", func() {
+	var (
+		readerArg *strings.Reader
+		errArg    error
+
+		actualErr error
+	)
+
+	BeforeEach(func() {
+		readerArg = new(strings.Reader)
+		errArg = errors.New("")
+	})
+
+	JustBeforeEach(func() {
+		actualErr = parseErr(readerArg, errArg)
+	})
+
+	Context("with a valid block error", func() {
+		BeforeEach(func() {
+			readerArg = strings.NewReader(`{
+    "Tables": null,
+    "Blocks": [
+        "\nTask 36835 | 23:50:15 | ",
+        "Error: Release 'bad' doesn't exist"
+    ]
+}`)
+		})
+
+		It("returns the error message", func() {
+			Expect(actualErr).To(MatchError("Error: Release 'bad' doesn't exist"))
+		})
+	})
+
+	Context("with an empty block error", func() {
+		BeforeEach(func() {
+			readerArg = strings.NewReader(`{
+		"Tables": null,
+		"Blocks": null,
+		"Lines": [
+			"Using environment 'https://10.0.0.6:25555' as client 'admin'",
+			"Expected non-empty deployment name",
+			"Exit code 1"
+		]
+}`)
+		})
+
+		It("returns the filtered error message", func() {
+			Expect(actualErr).To(MatchError("Expected non-empty deployment name"))
+		})
+	})
+
+	Context("when a Director task did not succeed", func() {
+		BeforeEach(func() {
+			readerArg = strings.NewReader(`{
+    "Blocks": [
+        "Task 12\n",
+        "\nTask 12 | 10:00:00 | Error: Timed out\n",
+        "\nTask 12 Started  Mon Jan  1 10:00:00 UTC 2024\nTask 12 Finished Mon Jan  1 10:05:00 UTC 2024\nTask 12 Duration 00:05:00\nTask 12 timeout\n"
+    ],
+    "Lines": ["Expected task '12' to succeed but state is 'timeout'", "Exit code 1"]
+}`)
+			errArg = errors.New("exit status 1")
+		})
+
+		It("returns the task and its state", func() {
+			Expect(actualErr).To(Equal(Error{Message: "exit status 1", TaskID: 12, TaskState: "timeout"}))
+		})
+	})
+
+	Context("when the Director responds with an unsuccessful status", func() {
+		BeforeEach(func() {
+			readerArg = strings.NewReader(`{
+    "Lines": [
+        "Fetching info:\n  Director responded with non-successful status code '502' response 'Bad Gateway'",
+        "Exit code 1"
+    ]
+}`)
+		})
+
+		It("returns the status code", func() {
+			var cliErr Error
+			Expect(errors.As(actualErr, &cliErr)).To(BeTrue())
+			Expect(cliErr.StatusCode).To(Equal(502))
+			Expect(cliErr.Unreachable).To(BeFalse())
+		})
+	})
+
+	Context("when the Director cannot be reached", func() {
+		BeforeEach(func() {
+			readerArg = strings.NewReader(`{
+    "Lines": [
+        "Fetching info:\n  Performing request GET 'https://10.0.0.6:25555/info':\n    Performing GET request:\n      dial tcp 10.0.0.6:25555: connect: connection refused",
+        "Exit code 1"
+    ]
+}`)
+		})
+
+		It("reports the Director as unreachable", func() {
+			var cliErr Error
+			Expect(errors.As(actualErr, &cliErr)).To(BeTrue())
+			Expect(cliErr.Unreachable).To(BeTrue())
+			Expect(cliErr.StatusCode).To(BeZero())
+		})
+	})
+})
+
+var _ = Describe("CLI", func() {
+	var (
+		dir    string
+		marker string
+		cli    CLI
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "boshcli-")
+		Expect(err).NotTo(HaveOccurred())
+		marker = filepath.Join(dir, "terminated")
+
+		cli = CLI{Path: filepath.Join(dir, "bosh")}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	writeBOSH := func(script string) {
+		Expect(os.WriteFile(cli.Path, []byte("#!/bin/sh\n"+script), 0755)).To(Succeed())
+	}
+
+	It("returns the output of bosh along with its error", func() {
+		writeBOSH(`echo '{"Blocks": ["Task 12\\n", "Error: Release '"'"'bad'"'"' doesn'"'"'t exist"]}'
+exit 1
+`)
+
+		output, err := cli.Cmd(context.Background(), "deploy")
+		Expect(err).To(MatchError("Error: Release 'bad' doesn't exist"))
+		Expect(output).NotTo(BeNil())
+		content, err := io.ReadAll(output)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring("Task 12"))
+	})
+
+	It("sends SIGTERM to bosh when the context is done", func() {
+		writeBOSH(`trap 'touch "` + marker + `"; exit 1' TERM
+sleep 10 >/dev/null 2>&1 &
+wait
+`)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := cli.Cmd(ctx, "export-release")
+		Expect(err).To(MatchError("bosh export-release: context deadline exceeded"))
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		Expect(marker).To(BeAnExistingFile())
+	})
+
+	It("kills bosh when it does not exit within the grace period", func() {
+		writeBOSH(`trap '' TERM
+sleep 10 >/dev/null 2>&1 &
+wait
+`)
+		cli.GracePeriod = 100 * time.Millisecond
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		started := time.Now()
+		_, err := cli.Cmd(ctx, "export-release")
+		Expect(err).To(MatchError("bosh export-release: context deadline exceeded"))
+		Expect(time.Since(started)).To(BeNumerically("<", 5*time.Second))
+	})
+})
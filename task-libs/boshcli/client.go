@@ -0,0 +1,304 @@
+package boshcli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/taskevents"
+)
+
+//go:generate counterfeiter . BoshCLI
+
+// BoshCLI runs a bosh command and returns its --json output. The command is
+// stopped when ctx is done.
+type BoshCLI interface {
+	Cmd(ctx context.Context, name string, args ...string) (io.Reader, error)
+}
+
+// Client is a typed wrapper around the bosh commands used by the tasks.
+type Client struct {
+	cli BoshCLI
+	ctx context.Context
+
+	reporter     *taskevents.Reporter
+	pollInterval time.Duration
+}
+
+func NewClient(cli BoshCLI) Client {
+	return Client{cli: cli, ctx: context.Background(), pollInterval: 5 * time.Second}
+}
+
+// WithContext returns a Client whose commands are stopped when ctx is done.
+// A Deploy or ExportRelease stopped this way also cancels the Director task
+// it started.
+func (c Client) WithContext(ctx context.Context) Client {
+	c.ctx = ctx
+	return c
+}
+
+// WithProgress returns a Client that follows the Director tasks started by
+// Deploy and ExportRelease and hands their events to reporter as they
+// arrive.
+func (c Client) WithProgress(reporter *taskevents.Reporter) Client {
+	c.reporter = reporter
+	return c
+}
+
+// WithPollInterval sets how often a followed task's events are fetched.
+func (c Client) WithPollInterval(interval time.Duration) Client {
+	c.pollInterval = interval
+	return c
+}
+
+// Deployment is a row of `bosh deployments`. Releases and Stemcells are kept
+// as the "name/version" entries printed by the CLI.
+type Deployment struct {
+	Name      string
+	Releases  []string
+	Stemcells []string
+	Teams     []string
+}
+
+// Stemcell is a row of `bosh stemcells`.
+type Stemcell struct {
+	Name     string
+	OS       string
+	Version  string
+	CID      string
+	Deployed bool
+}
+
+// Release is a row of `bosh releases`.
+type Release struct {
+	Name       string
+	Version    string
+	CommitHash string
+	Deployed   bool
+}
+
+// Task is a row of `bosh tasks`.
+type Task struct {
+	ID          int
+	State       string
+	StartedAt   string
+	FinishedAt  string
+	User        string
+	Deployment  string
+	Description string
+	Result      string
+}
+
+func (c Client) Deployments() ([]Deployment, error) {
+	r, err := c.cli.Cmd(c.ctx, "deployments", "--json")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DecodeRows[struct {
+		Name      string
+		Releases  string `json:"release_s"`
+		Stemcells string `json:"stemcell_s"`
+		Teams     string `json:"team_s"`
+	}](r)
+	if err != nil {
+		return nil, err
+	}
+
+	var deployments []Deployment
+	for _, row := range rows {
+		deployments = append(deployments, Deployment{
+			Name:      row.Name,
+			Releases:  splitCell(row.Releases),
+			Stemcells: splitCell(row.Stemcells),
+			Teams:     splitCell(row.Teams),
+		})
+	}
+
+	return deployments, nil
+}
+
+func (c Client) Stemcells() ([]Stemcell, error) {
+	r, err := c.cli.Cmd(c.ctx, "stemcells", "--json")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DecodeRows[struct {
+		Name    string
+		OS      string
+		Version string
+		CID     string
+	}](r)
+	if err != nil {
+		return nil, err
+	}
+
+	var stemcells []Stemcell
+	for _, row := range rows {
+		stemcells = append(stemcells, Stemcell{
+			Name:     row.Name,
+			OS:       row.OS,
+			Version:  strings.Trim(row.Version, "*"),
+			CID:      row.CID,
+			Deployed: strings.HasSuffix(row.Version, "*"),
+		})
+	}
+
+	return stemcells, nil
+}
+
+func (c Client) Releases() ([]Release, error) {
+	r, err := c.cli.Cmd(c.ctx, "releases", "--json")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DecodeRows[struct {
+		Name       string
+		Version    string
+		CommitHash string `json:"commit_hash"`
+	}](r)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []Release
+	for _, row := range rows {
+		releases = append(releases, Release{
+			Name:       row.Name,
+			Version:    strings.Trim(row.Version, "*"),
+			CommitHash: strings.Trim(row.CommitHash, "+"),
+			Deployed:   strings.HasSuffix(row.Version, "*"),
+		})
+	}
+
+	return releases, nil
+}
+
+// Tasks returns the Director tasks that are currently running.
+func (c Client) Tasks() ([]Task, error) {
+	return c.tasks("--json")
+}
+
+// RecentTasks returns the last n tasks of a deployment, newest first,
+// whatever their state.
+func (c Client) RecentTasks(deployment string, n int) ([]Task, error) {
+	return c.tasks(fmt.Sprintf("--recent=%d", n), "-d", deployment, "--json")
+}
+
+func (c Client) tasks(args ...string) ([]Task, error) {
+	r, err := c.cli.Cmd(c.ctx, "tasks", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DecodeRows[struct {
+		ID          string
+		State       string
+		StartedAt   string `json:"started_at"`
+		FinishedAt  string `json:"finished_at"`
+		User        string
+		Deployment  string
+		Description string
+		Result      string
+	}](r)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []Task
+	for _, row := range rows {
+		id, err := strconv.Atoi(row.ID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid task id %q: %w", row.ID, err)
+		}
+
+		tasks = append(tasks, Task{
+			ID:          id,
+			State:       row.State,
+			StartedAt:   row.StartedAt,
+			FinishedAt:  row.FinishedAt,
+			User:        row.User,
+			Deployment:  row.Deployment,
+			Description: row.Description,
+			Result:      row.Result,
+		})
+	}
+
+	return tasks, nil
+}
+
+// Manifest returns the current manifest of a deployment.
+func (c Client) Manifest(deployment string) ([]byte, error) {
+	r, err := c.cli.Cmd(c.ctx, "manifest", "-d", deployment, "--json")
+	if err != nil {
+		return nil, err
+	}
+
+	var output struct {
+		Blocks []string
+	}
+	err = json.NewDecoder(r).Decode(&output)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.Join(output.Blocks, "")), nil
+}
+
+// CloudConfig returns the Director's default cloud-config.
+func (c Client) CloudConfig() ([]byte, error) {
+	r, err := c.cli.Cmd(c.ctx, "cloud-config", "--json")
+	if err != nil {
+		return nil, err
+	}
+
+	var output struct {
+		Blocks []string
+	}
+	err = json.NewDecoder(r).Decode(&output)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.Join(output.Blocks, "")), nil
+}
+
+// Deploy runs `bosh deploy` non-interactively. Extra args such as ops files
+// or --recreate are appended to the command.
+func (c Client) Deploy(deployment, manifestPath string, args ...string) (io.Reader, error) {
+	deployArgs := append([]string{manifestPath, "-d", deployment, "-n", "--json"}, args...)
+	return c.runTask(deployment, []string{"create deployment"}, "deploy", deployArgs...)
+}
+
+// ExportRelease exports a compiled release tarball into the working
+// directory. release and stemcell are given as "name/version" and
+// "os/version".
+func (c Client) ExportRelease(deployment, release, stemcell string, args ...string) (io.Reader, error) {
+	exportArgs := append([]string{"-d", deployment, "--json", release, stemcell}, args...)
+
+	// A deployment can export the same release for several stemcells at once,
+	// so its task is matched by both slugs.
+	return c.runTask(deployment, []string{release, stemcell}, "export-release", exportArgs...)
+}
+
+// CancelTask asks the Director to cancel a running task.
+func (c Client) CancelTask(id int) error {
+	_, err := c.cli.Cmd(c.ctx, "cancel-task", strconv.Itoa(id), "--json")
+	return err
+}
+
+func (c Client) DeleteDeployment(deployment string, force bool) error {
+	args := []string{"-d", deployment, "-n", "--json"}
+	if force {
+		args = append(args, "--force")
+	}
+
+	_, err := c.cli.Cmd(c.ctx, "delete-deployment", args...)
+	return err
+}
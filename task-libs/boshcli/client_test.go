@@ -0,0 +1,264 @@
+package boshcli_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli"
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli/boshclifakes"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		fakeCLI *boshclifakes.FakeBoshCLI
+		client  boshcli.Client
+	)
+
+	BeforeEach(func() {
+		fakeCLI = new(boshclifakes.FakeBoshCLI)
+		client = boshcli.NewClient(fakeCLI)
+	})
+
+	Describe("Deployments", func() {
+		BeforeEach(func() {
+			fakeCLI.CmdReturns(strings.NewReader(`{
+    "Tables": [
+        {
+            "Content": "deployments",
+            "Rows": [
+                {
+                    "name": "cf",
+                    "release_s": "release-a/0.1.0\nrelease-b/2.0.0",
+                    "stemcell_s": "bosh-stemcell-jammy/1.2\nbosh-stemcell-noble/3.4",
+                    "team_s": ""
+                }
+            ]
+        }
+    ]
+}`), nil)
+		})
+
+		It("runs `bosh deployments --json` and splits the multi-line cells", func() {
+			deployments, err := client.Deployments()
+			Expect(err).NotTo(HaveOccurred())
+
+			_, name, args := fakeCLI.CmdArgsForCall(0)
+			Expect(name).To(Equal("deployments"))
+			Expect(args).To(Equal([]string{"--json"}))
+
+			Expect(deployments).To(Equal([]boshcli.Deployment{{
+				Name:      "cf",
+				Releases:  []string{"release-a/0.1.0", "release-b/2.0.0"},
+				Stemcells: []string{"bosh-stemcell-jammy/1.2", "bosh-stemcell-noble/3.4"},
+			}}))
+		})
+	})
+
+	Describe("Stemcells", func() {
+		BeforeEach(func() {
+			fakeCLI.CmdReturns(strings.NewReader(`{
+    "Tables": [
+        {
+            "Rows": [
+                {"cid": "ami-1", "cpi": "", "name": "some-stemcell", "os": "some-os", "version": "1.2*"},
+                {"cid": "ami-2", "cpi": "", "name": "some-stemcell", "os": "some-os", "version": "1.1"}
+            ]
+        }
+    ]
+}`), nil)
+		})
+
+		It("strips and records the deployed marker", func() {
+			stemcells, err := client.Stemcells()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stemcells).To(Equal([]boshcli.Stemcell{
+				{Name: "some-stemcell", OS: "some-os", Version: "1.2", CID: "ami-1", Deployed: true},
+				{Name: "some-stemcell", OS: "some-os", Version: "1.1", CID: "ami-2"},
+			}))
+		})
+	})
+
+	Describe("Releases", func() {
+		BeforeEach(func() {
+			fakeCLI.CmdReturns(strings.NewReader(`{
+    "Tables": [
+        {
+            "Rows": [
+                {"name": "release-a", "version": "0.1.0*", "commit_hash": "abc123+"}
+            ]
+        }
+    ]
+}`), nil)
+		})
+
+		It("returns the releases", func() {
+			releases, err := client.Releases()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(releases).To(Equal([]boshcli.Release{
+				{Name: "release-a", Version: "0.1.0", CommitHash: "abc123", Deployed: true},
+			}))
+		})
+	})
+
+	Describe("Tasks", func() {
+		It("returns the tasks", func() {
+			fakeCLI.CmdReturns(strings.NewReader(`{
+    "Tables": [
+        {
+            "Rows": [
+                {"id": "42", "state": "processing", "started_at": "Mon Jan  1", "finished_at": "-", "user": "admin", "deployment": "cf", "description": "create deployment", "result": ""}
+            ]
+        }
+    ]
+}`), nil)
+
+			tasks, err := client.Tasks()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tasks).To(Equal([]boshcli.Task{{
+				ID:          42,
+				State:       "processing",
+				StartedAt:   "Mon Jan  1",
+				FinishedAt:  "-",
+				User:        "admin",
+				Deployment:  "cf",
+				Description: "create deployment",
+			}}))
+		})
+
+		It("returns an error for an invalid task id", func() {
+			fakeCLI.CmdReturns(strings.NewReader(`{"Tables": [{"Rows": [{"id": "abc"}]}]}`), nil)
+
+			_, err := client.Tasks()
+			Expect(err).To(MatchError(ContainSubstring(`invalid task id "abc"`)))
+		})
+	})
+
+	Describe("Deploy", func() {
+		It("runs `bosh deploy` with the manifest and extra args", func() {
+			_, err := client.Deploy("cf", "manifest.yml", "--recreate")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, name, args := fakeCLI.CmdArgsForCall(0)
+			Expect(name).To(Equal("deploy"))
+			Expect(args).To(Equal([]string{"manifest.yml", "-d", "cf", "-n", "--json", "--recreate"}))
+		})
+	})
+
+	Describe("Manifest", func() {
+		It("runs `bosh manifest --json` and returns the manifest block", func() {
+			fakeCLI.CmdReturns(strings.NewReader(`{"Tables":null,"Blocks":["name: cf\nreleases: []\n"],"Lines":["Succeeded"]}`), nil)
+
+			manifest, err := client.Manifest("cf")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(manifest)).To(Equal("name: cf\nreleases: []\n"))
+
+			_, name, args := fakeCLI.CmdArgsForCall(0)
+			Expect(name).To(Equal("manifest"))
+			Expect(args).To(Equal([]string{"-d", "cf", "--json"}))
+		})
+
+		It("returns the command error", func() {
+			fakeCLI.CmdReturns(nil, errors.New("some error"))
+			_, err := client.Manifest("cf")
+			Expect(err).To(MatchError("some error"))
+		})
+	})
+
+	Describe("CloudConfig", func() {
+		It("runs `bosh cloud-config --json` and returns the config block", func() {
+			fakeCLI.CmdReturns(strings.NewReader(`{"Tables":null,"Blocks":["compilation:\n  workers: 5\n"],"Lines":["Succeeded"]}`), nil)
+
+			config, err := client.CloudConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(config)).To(Equal("compilation:\n  workers: 5\n"))
+
+			_, name, args := fakeCLI.CmdArgsForCall(0)
+			Expect(name).To(Equal("cloud-config"))
+			Expect(args).To(Equal([]string{"--json"}))
+		})
+	})
+
+	Describe("ExportRelease", func() {
+		It("runs `bosh export-release` for the release and stemcell", func() {
+			_, err := client.ExportRelease("cf", "release-a/0.1.0", "some-os/1.2")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, name, args := fakeCLI.CmdArgsForCall(0)
+			Expect(name).To(Equal("export-release"))
+			Expect(args).To(Equal([]string{"-d", "cf", "--json", "release-a/0.1.0", "some-os/1.2"}))
+		})
+
+		Context("when the context is done before the export finishes", func() {
+			var (
+				ctx    context.Context
+				cancel context.CancelFunc
+				tasks  string
+			)
+
+			BeforeEach(func() {
+				ctx, cancel = context.WithCancel(context.Background())
+				tasks = `{"Tables":[{"Rows":[
+{"id":"13","state":"processing","description":"export release: release-a/0.1.0 for other-os/3.4"},
+{"id":"12","state":"processing","description":"export release: release-b/0.2.0 for some-os/1.2"},
+{"id":"11","state":"processing","description":"export release: release-a/0.1.0 for some-os/1.2"}
+]}]}`
+
+				fakeCLI.CmdStub = func(ctx context.Context, name string, args ...string) (io.Reader, error) {
+					switch name {
+					case "export-release":
+						cancel()
+						return nil, fmt.Errorf("bosh export-release: %w", context.Cause(ctx))
+					case "tasks":
+						return strings.NewReader(tasks), nil
+					}
+					return new(bytes.Buffer), nil
+				}
+			})
+
+			It("cancels the running export task of the release and stemcell", func() {
+				_, err := client.WithContext(ctx).ExportRelease("cf", "release-a/0.1.0", "some-os/1.2")
+				Expect(err).To(MatchError("bosh export-release: context canceled (cancelled Director task 11)"))
+				Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+
+				_, name, args := fakeCLI.CmdArgsForCall(1)
+				Expect(name).To(Equal("tasks"))
+				Expect(args).To(Equal([]string{"-d", "cf", "--json"}))
+
+				cancelCtx, name, args := fakeCLI.CmdArgsForCall(2)
+				Expect(name).To(Equal("cancel-task"))
+				Expect(args).To(Equal([]string{"11", "--json"}))
+				Expect(cancelCtx).NotTo(Equal(ctx))
+			})
+
+			It("returns the command error when the task is not running", func() {
+				tasks = `{"Tables":[{"Rows":[]}]}`
+
+				_, err := client.WithContext(ctx).ExportRelease("cf", "release-a/0.1.0", "some-os/1.2")
+				Expect(err).To(MatchError("bosh export-release: context canceled"))
+				Expect(fakeCLI.CmdCallCount()).To(Equal(2))
+			})
+		})
+	})
+
+	Describe("DeleteDeployment", func() {
+		It("runs `bosh delete-deployment` with --force", func() {
+			Expect(client.DeleteDeployment("cf", true)).To(Succeed())
+
+			_, name, args := fakeCLI.CmdArgsForCall(0)
+			Expect(name).To(Equal("delete-deployment"))
+			Expect(args).To(Equal([]string{"-d", "cf", "-n", "--json", "--force"}))
+		})
+
+		It("returns the command error", func() {
+			fakeCLI.CmdReturns(nil, errors.New("some error"))
+			Expect(client.DeleteDeployment("cf", false)).To(MatchError("some error"))
+		})
+	})
+})
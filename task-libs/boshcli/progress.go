@@ -0,0 +1,198 @@
+package boshcli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/taskevents"
+)
+
+var taskIDPattern = regexp.MustCompile(`Task (\d+)`)
+
+// cancelTaskTimeout bounds cancelling the Director task of a stopped command.
+const cancelTaskTimeout = 10 * time.Second
+
+// runTask runs a bosh command that starts a Director task. When the client
+// has a reporter, the task is followed while the command runs: it is found
+// among the deployment's recent tasks by a description containing each part
+// of match in order, and its events are polled with `bosh task --event`.
+// When the client's context is done before the command finishes, the task is
+// cancelled as well; stopping bosh alone leaves it running on the Director.
+func (c Client) runTask(deployment string, match []string, name string, args ...string) (io.Reader, error) {
+	if c.reporter == nil {
+		output, err := c.cli.Cmd(c.ctx, name, args...)
+		if err != nil && c.ctx.Err() != nil {
+			return nil, c.cancelTask(deployment, match, 0, err)
+		}
+		return output, err
+	}
+
+	follower := &taskFollower{client: c, deployment: deployment, match: match}
+	follower.after = follower.latestTaskID()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(c.pollInterval):
+				follower.poll(false)
+			}
+		}
+	}()
+
+	output, err := c.cli.Cmd(c.ctx, name, args...)
+	close(done)
+	<-stopped
+
+	if err != nil && c.ctx.Err() != nil {
+		return nil, c.cancelTask(deployment, match, follower.id, err)
+	}
+
+	if output == nil {
+		follower.poll(true)
+		return output, err
+	}
+
+	content, readErr := io.ReadAll(output)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	if match := taskIDPattern.FindSubmatch(content); match != nil && follower.id == 0 {
+		follower.id, _ = strconv.Atoi(string(match[1]))
+	}
+	follower.poll(true)
+
+	return bytes.NewReader(content), err
+}
+
+// cancelTask cancels the Director task of a command stopped by the client's
+// context and returns the command's err annotated with the outcome. id is
+// the task when it is known; otherwise it is the running task of deployment
+// whose description contains match.
+func (c Client) cancelTask(deployment string, match []string, id int, err error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.ctx), cancelTaskTimeout)
+	defer cancel()
+	client := c.WithContext(ctx)
+
+	if id == 0 {
+		tasks, listErr := client.tasks("-d", deployment, "--json")
+		if listErr != nil {
+			return fmt.Errorf("%w (failed to find the Director task to cancel: %s)", err, listErr)
+		}
+
+		for _, task := range tasks {
+			if describes(task.Description, match) {
+				id = task.ID
+				break
+			}
+		}
+
+		if id == 0 {
+			return err
+		}
+	}
+
+	cancelErr := client.CancelTask(id)
+	if cancelErr != nil {
+		return fmt.Errorf("%w (failed to cancel Director task %d: %s)", err, id, cancelErr)
+	}
+
+	return fmt.Errorf("%w (cancelled Director task %d)", err, id)
+}
+
+// describes reports whether a task description contains every part of match,
+// in order.
+func describes(description string, match []string) bool {
+	for _, part := range match {
+		i := strings.Index(description, part)
+		if i < 0 {
+			return false
+		}
+		description = description[i+len(part):]
+	}
+	return true
+}
+
+type taskFollower struct {
+	client     Client
+	deployment string
+	match      []string
+
+	after int
+	id    int
+	// offset is how much of the task's event output has been reported.
+	offset int
+}
+
+func (f *taskFollower) latestTaskID() int {
+	tasks, err := f.client.RecentTasks(f.deployment, 1)
+	if err != nil || len(tasks) == 0 {
+		return 0
+	}
+
+	return tasks[0].ID
+}
+
+// poll reports the events the task has emitted since the last poll. Only
+// complete lines are reported until done is set, as the last line of a
+// running task's output may still be being written. Errors are ignored;
+// following a task is best effort and must not fail the command.
+func (f *taskFollower) poll(done bool) {
+	if f.id == 0 {
+		tasks, err := f.client.RecentTasks(f.deployment, 10)
+		if err != nil {
+			return
+		}
+
+		for _, task := range tasks {
+			if task.ID > f.after && describes(task.Description, f.match) {
+				f.id = task.ID
+				break
+			}
+		}
+
+		if f.id == 0 {
+			return
+		}
+	}
+
+	// bosh task cannot start from an offset, so the whole output is fetched
+	// and only what follows the reported part is parsed.
+	r, err := f.client.cli.Cmd(f.client.ctx, "task", strconv.Itoa(f.id), "--event", "--raw", "--json")
+	if err != nil {
+		return
+	}
+
+	var output struct {
+		Blocks []string
+	}
+	if json.NewDecoder(r).Decode(&output) != nil {
+		return
+	}
+
+	content := strings.Join(output.Blocks, "\n")
+	if len(content) <= f.offset {
+		return
+	}
+
+	unreported := content[f.offset:]
+	if !done {
+		unreported = unreported[:strings.LastIndexByte(unreported, '\n')+1]
+	}
+	for _, event := range taskevents.Parse(unreported) {
+		f.client.reporter.Handle(event)
+	}
+	f.offset += len(unreported)
+}
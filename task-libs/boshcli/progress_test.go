@@ -0,0 +1,125 @@
+package boshcli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli"
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli/boshclifakes"
+	"github.com/cloudfoundry/runtime-ci/task-libs/taskevents"
+)
+
+var _ = Describe("following task progress", func() {
+	var (
+		fakeCLI  *boshclifakes.FakeBoshCLI
+		out      *bytes.Buffer
+		mu       sync.Mutex
+		started  bool
+		deployed chan struct{}
+
+		// eventOutputs are the event outputs of successive polls of the
+		// task; the last one is repeated.
+		eventOutputs []string
+	)
+
+	events := []string{
+		`{"time":1700000000,"stage":"Compiling packages","total":1,"task":"golang/abc","index":1,"state":"started","progress":0}`,
+		`{"time":1700000060,"stage":"Compiling packages","total":1,"task":"golang/abc","index":1,"state":"finished","progress":100}`,
+	}
+
+	BeforeEach(func() {
+		out = new(bytes.Buffer)
+		started = false
+		deployed = make(chan struct{})
+		eventOutputs = []string{strings.Join(events, "\n")}
+
+		fakeCLI = new(boshclifakes.FakeBoshCLI)
+		fakeCLI.CmdStub = func(_ context.Context, name string, args ...string) (io.Reader, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch name {
+			case "tasks":
+				if !started {
+					return strings.NewReader(`{"Tables":[{"Rows":[{"id":"7","state":"done","description":"create deployment"}]}]}`), nil
+				}
+				return strings.NewReader(`{"Tables":[{"Rows":[{"id":"8","state":"processing","description":"create deployment"},{"id":"7","state":"done","description":"create deployment"}]}]}`), nil
+			case "task":
+				Expect(args).To(Equal([]string{"8", "--event", "--raw", "--json"}))
+				content, _ := json.Marshal(map[string][]string{"Blocks": {eventOutputs[0]}})
+				if len(eventOutputs) > 1 {
+					eventOutputs = eventOutputs[1:]
+				}
+				return bytes.NewReader(content), nil
+			case "deploy":
+				started = true
+				mu.Unlock()
+				<-deployed
+				mu.Lock()
+				return strings.NewReader(`{"Blocks":["Task 8\n","Task 8 done\n"]}`), nil
+			}
+			return new(bytes.Buffer), nil
+		}
+	})
+
+	It("reports each event of the deploy task once while it runs", func() {
+		reporter := taskevents.NewReporter(out, "")
+		client := boshcli.NewClient(fakeCLI).WithProgress(reporter).WithPollInterval(time.Millisecond)
+
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(20 * time.Millisecond)
+			close(deployed)
+		}()
+
+		output, err := client.Deploy("cf", "manifest.yml")
+		Expect(err).NotTo(HaveOccurred())
+
+		content, err := io.ReadAll(output)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring("Task 8 done"))
+
+		Expect(strings.Count(out.String(), "golang/abc done (00:01:00)")).To(Equal(1))
+		Expect(strings.Count(out.String(), "Compiling packages (1/1): golang/abc")).To(Equal(1))
+	})
+
+	It("reports an event once after it has been written completely", func() {
+		mu.Lock()
+		eventOutputs = []string{
+			events[0] + "\n" + events[1][:20],
+			events[0] + "\n" + events[1][:40],
+			events[0] + "\n" + events[1] + "\n",
+		}
+		mu.Unlock()
+
+		reporter := taskevents.NewReporter(out, "")
+		client := boshcli.NewClient(fakeCLI).WithProgress(reporter).WithPollInterval(time.Millisecond)
+
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(20 * time.Millisecond)
+			close(deployed)
+		}()
+
+		_, err := client.Deploy("cf", "manifest.yml")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(strings.Count(out.String(), "Compiling packages (1/1): golang/abc")).To(Equal(1))
+		Expect(strings.Count(out.String(), "golang/abc done (00:01:00)")).To(Equal(1))
+	})
+
+	It("does not follow tasks without a reporter", func() {
+		close(deployed)
+		_, err := boshcli.NewClient(fakeCLI).Deploy("cf", "manifest.yml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakeCLI.CmdCallCount()).To(Equal(1))
+	})
+})
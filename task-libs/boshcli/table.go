@@ -0,0 +1,41 @@
+package boshcli
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// DecodeRows decodes the rows of every table in the output of a bosh
+// command run with --json.
+func DecodeRows[T any](r io.Reader) ([]T, error) {
+	var output struct {
+		Tables []struct {
+			Rows []T
+		}
+	}
+
+	err := json.NewDecoder(r).Decode(&output)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []T
+	for _, table := range output.Tables {
+		rows = append(rows, table.Rows...)
+	}
+
+	return rows, nil
+}
+
+func splitCell(cell string) []string {
+	var values []string
+	for _, value := range strings.Split(cell, "\n") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
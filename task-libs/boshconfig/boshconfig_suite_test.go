@@ -0,0 +1,13 @@
+package boshconfig_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestBoshConfig(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "BoshConfig Suite")
+}
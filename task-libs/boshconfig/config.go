@@ -0,0 +1,494 @@
+// Package boshconfig merges extensions into BOSH cloud-configs and
+// runtime-configs by name, so that extending a config can be repeated
+// without duplicating or silently replacing definitions.
+package boshconfig
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Kind string
+
+const (
+	CloudConfig   Kind = "cloud-config"
+	RuntimeConfig Kind = "runtime-config"
+)
+
+// namedSections are the top-level lists of each kind of config whose entries
+// are identified by their name. Any other top-level key is merged as a whole.
+var namedSections = map[Kind][]string{
+	CloudConfig:   {"azs", "networks", "vm_types", "vm_extensions", "disk_types"},
+	RuntimeConfig: {"releases", "addons", "variables"},
+}
+
+// Config is a parsed cloud-config or runtime-config. Key order and comments
+// are kept when it is marshalled.
+type Config struct {
+	Kind Kind
+	root *yaml.Node
+}
+
+// Load parses a config of the given kind. Empty content is an empty config.
+func Load(kind Kind, content []byte) (*Config, error) {
+	if _, ok := namedSections[kind]; !ok {
+		return nil, fmt.Errorf("unknown config kind %q", kind)
+	}
+
+	var doc yaml.Node
+	err := yaml.Unmarshal(content, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", kind, err)
+	}
+
+	if len(doc.Content) == 0 {
+		return &Config{Kind: kind, root: &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}}, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s is not a map", kind)
+	}
+
+	config := &Config{Kind: kind, root: root}
+	for _, section := range namedSections[kind] {
+		err := config.validateSection(section)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+// LoadFile reads and parses a config of the given kind.
+func LoadFile(kind Kind, path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Load(kind, content)
+}
+
+func (c *Config) validateSection(section string) error {
+	value := c.get(section)
+	if value == nil {
+		return nil
+	}
+
+	if value.Kind != yaml.SequenceNode {
+		return fmt.Errorf("%s %s is not a list", c.Kind, section)
+	}
+
+	for i, entry := range value.Content {
+		if entryName(entry) == "" {
+			return fmt.Errorf("%s %s entry %d has no name", c.Kind, section, i)
+		}
+	}
+
+	return nil
+}
+
+// Marshal renders the config as YAML.
+func (c *Config) Marshal() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+
+	err := encoder.Encode(c.root)
+	if err != nil {
+		return nil, err
+	}
+
+	err = encoder.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Entry identifies a merged definition. Name is empty for top-level keys
+// that are merged as a whole.
+type Entry struct {
+	Section string
+	Name    string
+}
+
+func (e Entry) String() string {
+	if e.Name == "" {
+		return e.Section
+	}
+	return e.Section + "/" + e.Name
+}
+
+// Summary lists what a merge added to a config, and what the config already
+// defined identically. Replaced and Removed are only set by Apply.
+type Summary struct {
+	Added     []Entry
+	Replaced  []Entry
+	Removed   []Entry
+	Unchanged []Entry
+}
+
+func (s Summary) String() string {
+	var b strings.Builder
+
+	writeEntries := func(heading string, entries []Entry) {
+		fmt.Fprintf(&b, "%s (%d):\n", heading, len(entries))
+		for _, entry := range entries {
+			fmt.Fprintf(&b, "  %s\n", entry)
+		}
+	}
+
+	writeEntries("Added", s.Added)
+	if len(s.Replaced) > 0 {
+		writeEntries("Replaced", s.Replaced)
+	}
+	if len(s.Removed) > 0 {
+		writeEntries("Removed", s.Removed)
+	}
+	writeEntries("Unchanged", s.Unchanged)
+
+	return b.String()
+}
+
+// ConflictError is returned when an extension defines something the config
+// already defines differently.
+type ConflictError struct {
+	Conflicts []Entry
+}
+
+func (e ConflictError) Error() string {
+	var names []string
+	for _, conflict := range e.Conflicts {
+		names = append(names, conflict.String())
+	}
+
+	return fmt.Sprintf("conflicting definitions for %s", strings.Join(names, ", "))
+}
+
+// Merge adds every definition of extension that the config does not have.
+// Definitions with the same name and the same content are left alone, so
+// merging an extension again changes nothing. If any definition conflicts,
+// the config is not modified and a ConflictError is returned.
+func (c *Config) Merge(extension *Config) (Summary, error) {
+	var summary Summary
+
+	if extension.Kind != c.Kind {
+		return summary, fmt.Errorf("cannot merge a %s into a %s", extension.Kind, c.Kind)
+	}
+
+	type addition struct {
+		key   string
+		value *yaml.Node
+	}
+	var (
+		conflicts []Entry
+		additions []addition
+	)
+
+	named := map[string]bool{}
+	for _, section := range namedSections[c.Kind] {
+		named[section] = true
+	}
+
+	for i := 0; i+1 < len(extension.root.Content); i += 2 {
+		key := extension.root.Content[i].Value
+		value := extension.root.Content[i+1]
+
+		if !named[key] {
+			current := c.get(key)
+			switch {
+			case current == nil:
+				additions = append(additions, addition{key: key, value: value})
+				summary.Added = append(summary.Added, Entry{Section: key})
+			case equal(current, value):
+				summary.Unchanged = append(summary.Unchanged, Entry{Section: key})
+			default:
+				conflicts = append(conflicts, Entry{Section: key})
+			}
+			continue
+		}
+
+		existing := map[string]*yaml.Node{}
+		if current := c.get(key); current != nil {
+			for _, entry := range current.Content {
+				existing[entryName(entry)] = entry
+			}
+		}
+
+		for _, entry := range value.Content {
+			name := entryName(entry)
+			current, ok := existing[name]
+			switch {
+			case !ok:
+				existing[name] = entry
+				additions = append(additions, addition{key: key, value: entry})
+				summary.Added = append(summary.Added, Entry{Section: key, Name: name})
+			case equal(current, entry):
+				if !containsEntry(summary.Added, Entry{Section: key, Name: name}) {
+					summary.Unchanged = append(summary.Unchanged, Entry{Section: key, Name: name})
+				}
+			default:
+				conflicts = append(conflicts, Entry{Section: key, Name: name})
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].String() < conflicts[j].String() })
+		return Summary{}, ConflictError{Conflicts: conflicts}
+	}
+
+	for _, a := range additions {
+		if !named[a.key] {
+			c.set(a.key, a.value)
+			continue
+		}
+
+		section := c.get(a.key)
+		if section == nil {
+			section = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			c.set(a.key, section)
+		}
+		section.Content = append(section.Content, a.value)
+	}
+
+	return summary, nil
+}
+
+// MergeFiles merges the extension at extensionPath into the config at
+// configPath and writes the result to outputPath.
+func MergeFiles(kind Kind, configPath, extensionPath, outputPath string) (Summary, error) {
+	config, err := LoadFile(kind, configPath)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	extension, err := LoadFile(kind, extensionPath)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	summary, err := config.Merge(extension)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	content, err := config.Marshal()
+	if err != nil {
+		return Summary{}, err
+	}
+
+	return summary, os.WriteFile(outputPath, content, 0644)
+}
+
+// Apply makes extended, the config with an ops file interpolated into it, the
+// new config, so the replace and remove ops of the ops file take effect. An
+// entry the ops file appended under a name the config already has is dropped
+// when it is identical, so applying the ops file again changes nothing. If it
+// differs, the config is not modified and a ConflictError is returned.
+func (c *Config) Apply(extended *Config) (Summary, error) {
+	var summary Summary
+
+	if extended.Kind != c.Kind {
+		return summary, fmt.Errorf("cannot apply a %s to a %s", extended.Kind, c.Kind)
+	}
+
+	var conflicts []Entry
+
+	named := map[string]bool{}
+	for _, section := range namedSections[c.Kind] {
+		named[section] = true
+	}
+
+	root := *extended.root
+	root.Content = nil
+
+	inExtended := map[string]bool{}
+	for i := 0; i+1 < len(extended.root.Content); i += 2 {
+		key := extended.root.Content[i].Value
+		value := extended.root.Content[i+1]
+		current := c.get(key)
+		inExtended[key] = true
+
+		if !named[key] {
+			switch {
+			case current == nil:
+				summary.Added = append(summary.Added, Entry{Section: key})
+			case equal(current, value):
+				summary.Unchanged = append(summary.Unchanged, Entry{Section: key})
+			default:
+				summary.Replaced = append(summary.Replaced, Entry{Section: key})
+			}
+			root.Content = append(root.Content, extended.root.Content[i], value)
+			continue
+		}
+
+		existing := map[string]*yaml.Node{}
+		if current != nil {
+			for _, entry := range current.Content {
+				existing[entryName(entry)] = entry
+			}
+		}
+
+		section := *value
+		section.Content = nil
+
+		kept := map[string]*yaml.Node{}
+		for _, entry := range value.Content {
+			name := entryName(entry)
+			if first, ok := kept[name]; ok {
+				if !equal(first, entry) {
+					conflicts = append(conflicts, Entry{Section: key, Name: name})
+				}
+				continue
+			}
+			kept[name] = entry
+			section.Content = append(section.Content, entry)
+
+			before, ok := existing[name]
+			switch {
+			case !ok:
+				summary.Added = append(summary.Added, Entry{Section: key, Name: name})
+			case equal(before, entry):
+				summary.Unchanged = append(summary.Unchanged, Entry{Section: key, Name: name})
+			default:
+				summary.Replaced = append(summary.Replaced, Entry{Section: key, Name: name})
+			}
+		}
+
+		if current != nil {
+			for _, entry := range current.Content {
+				if name := entryName(entry); kept[name] == nil {
+					summary.Removed = append(summary.Removed, Entry{Section: key, Name: name})
+				}
+			}
+		}
+
+		root.Content = append(root.Content, extended.root.Content[i], &section)
+	}
+
+	for i := 0; i+1 < len(c.root.Content); i += 2 {
+		key := c.root.Content[i].Value
+		if inExtended[key] {
+			continue
+		}
+
+		if !named[key] {
+			summary.Removed = append(summary.Removed, Entry{Section: key})
+			continue
+		}
+		for _, entry := range c.root.Content[i+1].Content {
+			summary.Removed = append(summary.Removed, Entry{Section: key, Name: entryName(entry)})
+		}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].String() < conflicts[j].String() })
+		return Summary{}, ConflictError{Conflicts: conflicts}
+	}
+
+	c.root = &root
+	return summary, nil
+}
+
+// ApplyFiles applies the extended config at extendedPath to the config at
+// configPath and writes the result to outputPath.
+func ApplyFiles(kind Kind, configPath, extendedPath, outputPath string) (Summary, error) {
+	config, err := LoadFile(kind, configPath)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	extended, err := LoadFile(kind, extendedPath)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	summary, err := config.Apply(extended)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	content, err := config.Marshal()
+	if err != nil {
+		return Summary{}, err
+	}
+
+	return summary, os.WriteFile(outputPath, content, 0644)
+}
+
+// CompilationWorkers returns the number of compilation workers of a
+// cloud-config, or 0 when it has no compilation block.
+func (c *Config) CompilationWorkers() (int, error) {
+	compilation := c.get("compilation")
+	if compilation == nil {
+		return 0, nil
+	}
+
+	var block struct {
+		Workers int `yaml:"workers"`
+	}
+	err := compilation.Decode(&block)
+	if err != nil {
+		return 0, fmt.Errorf("invalid compilation block in %s: %w", c.Kind, err)
+	}
+
+	return block.Workers, nil
+}
+
+func (c *Config) get(key string) *yaml.Node {
+	for i := 0; i+1 < len(c.root.Content); i += 2 {
+		if c.root.Content[i].Value == key {
+			return c.root.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func (c *Config) set(key string, value *yaml.Node) {
+	c.root.Content = append(c.root.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		value,
+	)
+}
+
+func entryName(entry *yaml.Node) string {
+	if entry.Kind != yaml.MappingNode {
+		return ""
+	}
+
+	for i := 0; i+1 < len(entry.Content); i += 2 {
+		if entry.Content[i].Value == "name" {
+			return entry.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+func equal(a, b *yaml.Node) bool {
+	var aValue, bValue interface{}
+	if a.Decode(&aValue) != nil || b.Decode(&bValue) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(aValue, bValue)
+}
+
+func containsEntry(entries []Entry, entry Entry) bool {
+	for _, e := range entries {
+		if e == entry {
+			return true
+		}
+	}
+	return false
+}
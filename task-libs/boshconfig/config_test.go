@@ -0,0 +1,339 @@
+package boshconfig_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshconfig"
+)
+
+const cloudConfig = `azs:
+- name: z1
+  cloud_properties:
+    zone: us-central1-f
+vm_types:
+- name: default
+  cloud_properties:
+    machine_type: n1-standard-2
+# compilation is used by every deployment
+compilation:
+  workers: 5
+  network: default
+`
+
+var _ = Describe("Config", func() {
+	Describe("Load", func() {
+		It("treats empty content as an empty config", func() {
+			config, err := boshconfig.Load(boshconfig.CloudConfig, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			extension, err := boshconfig.Load(boshconfig.CloudConfig, []byte("azs: [{name: z1}]\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			summary, err := config.Merge(extension)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.Added).To(ConsistOf(boshconfig.Entry{Section: "azs", Name: "z1"}))
+		})
+
+		It("rejects unknown kinds", func() {
+			_, err := boshconfig.Load("cpi-config", nil)
+			Expect(err).To(MatchError(`unknown config kind "cpi-config"`))
+		})
+
+		It("rejects named sections that are not lists", func() {
+			_, err := boshconfig.Load(boshconfig.CloudConfig, []byte("vm_types: {name: default}\n"))
+			Expect(err).To(MatchError("cloud-config vm_types is not a list"))
+		})
+
+		It("rejects entries without a name", func() {
+			_, err := boshconfig.Load(boshconfig.RuntimeConfig, []byte("addons:\n- jobs: []\n"))
+			Expect(err).To(MatchError("runtime-config addons entry 0 has no name"))
+		})
+	})
+
+	Describe("Merge", func() {
+		var config *boshconfig.Config
+
+		BeforeEach(func() {
+			var err error
+			config, err = boshconfig.Load(boshconfig.CloudConfig, []byte(cloudConfig))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("adds new definitions by name and keeps existing ones", func() {
+			extension, err := boshconfig.Load(boshconfig.CloudConfig, []byte(`azs:
+- name: z1
+  cloud_properties:
+    zone: us-central1-f
+- name: z2
+  cloud_properties:
+    zone: us-central1-c
+vm_extensions:
+- name: 50GB_ephemeral_disk
+  cloud_properties:
+    root_disk_size_gb: 50
+networks:
+- name: private
+  type: manual
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			summary, err := config.Merge(extension)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.Added).To(Equal([]boshconfig.Entry{
+				{Section: "azs", Name: "z2"},
+				{Section: "vm_extensions", Name: "50GB_ephemeral_disk"},
+				{Section: "networks", Name: "private"},
+			}))
+			Expect(summary.Unchanged).To(Equal([]boshconfig.Entry{{Section: "azs", Name: "z1"}}))
+
+			merged, err := config.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(merged)).To(Equal(`azs:
+  - name: z1
+    cloud_properties:
+      zone: us-central1-f
+  - name: z2
+    cloud_properties:
+      zone: us-central1-c
+vm_types:
+  - name: default
+    cloud_properties:
+      machine_type: n1-standard-2
+# compilation is used by every deployment
+compilation:
+  workers: 5
+  network: default
+vm_extensions:
+  - name: 50GB_ephemeral_disk
+    cloud_properties:
+      root_disk_size_gb: 50
+networks:
+  - name: private
+    type: manual
+`))
+		})
+
+		It("changes nothing when the same extension is merged again", func() {
+			extension, err := boshconfig.Load(boshconfig.CloudConfig, []byte("vm_types:\n- name: large\n  cloud_properties: {machine_type: n1-standard-8}\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = config.Merge(extension)
+			Expect(err).NotTo(HaveOccurred())
+			first, err := config.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+
+			summary, err := config.Merge(extension)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.Added).To(BeEmpty())
+			Expect(summary.Unchanged).To(ConsistOf(boshconfig.Entry{Section: "vm_types", Name: "large"}))
+
+			second, err := config.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second).To(Equal(first))
+		})
+
+		It("merges top-level keys other than named sections as a whole", func() {
+			extension, err := boshconfig.Load(boshconfig.CloudConfig, []byte("compilation: {workers: 5, network: default}\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			summary, err := config.Merge(extension)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.Unchanged).To(ConsistOf(boshconfig.Entry{Section: "compilation"}))
+		})
+
+		It("reports every conflict and leaves the config untouched", func() {
+			extension, err := boshconfig.Load(boshconfig.CloudConfig, []byte(`vm_types:
+- name: default
+  cloud_properties:
+    machine_type: n1-standard-4
+- name: large
+compilation:
+  workers: 10
+azs:
+- name: z1
+  cloud_properties:
+    zone: us-east1-b
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			before, err := config.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = config.Merge(extension)
+			Expect(err).To(MatchError("conflicting definitions for azs/z1, compilation, vm_types/default"))
+
+			var conflictErr boshconfig.ConflictError
+			Expect(err).To(BeAssignableToTypeOf(conflictErr))
+
+			after, err := config.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(after).To(Equal(before))
+		})
+
+		It("reports duplicate definitions within an extension as unchanged", func() {
+			extension, err := boshconfig.Load(boshconfig.CloudConfig, []byte("vm_types:\n- name: large\n- name: large\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			summary, err := config.Merge(extension)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.Added).To(ConsistOf(boshconfig.Entry{Section: "vm_types", Name: "large"}))
+			Expect(summary.Unchanged).To(BeEmpty())
+		})
+
+		It("merges runtime-config addons and releases", func() {
+			runtimeConfig, err := boshconfig.Load(boshconfig.RuntimeConfig, []byte("releases:\n- name: bpm\n  version: 1.2.3\naddons:\n- name: bpm\n  jobs: [{name: bpm, release: bpm}]\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			extension, err := boshconfig.Load(boshconfig.RuntimeConfig, []byte("releases:\n- name: os-conf\n  version: 22.0.0\naddons:\n- name: os-configuration\n  jobs: [{name: sysctl, release: os-conf}]\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			summary, err := runtimeConfig.Merge(extension)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.Added).To(Equal([]boshconfig.Entry{
+				{Section: "releases", Name: "os-conf"},
+				{Section: "addons", Name: "os-configuration"},
+			}))
+			Expect(summary.String()).To(Equal("Added (2):\n  releases/os-conf\n  addons/os-configuration\nUnchanged (0):\n"))
+		})
+
+		It("refuses to merge a different kind of config", func() {
+			extension, err := boshconfig.Load(boshconfig.RuntimeConfig, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = config.Merge(extension)
+			Expect(err).To(MatchError("cannot merge a runtime-config into a cloud-config"))
+		})
+	})
+
+	Describe("Apply", func() {
+		var config *boshconfig.Config
+
+		BeforeEach(func() {
+			var err error
+			config, err = boshconfig.Load(boshconfig.CloudConfig, []byte(cloudConfig))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("keeps the replaced and removed definitions of the interpolated config", func() {
+			extended, err := boshconfig.Load(boshconfig.CloudConfig, []byte(`vm_types:
+- name: default
+  cloud_properties:
+    machine_type: n1-standard-4
+- name: large
+compilation:
+  workers: 10
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			summary, err := config.Apply(extended)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.Added).To(Equal([]boshconfig.Entry{{Section: "vm_types", Name: "large"}}))
+			Expect(summary.Replaced).To(Equal([]boshconfig.Entry{{Section: "vm_types", Name: "default"}, {Section: "compilation"}}))
+			Expect(summary.Removed).To(Equal([]boshconfig.Entry{{Section: "azs", Name: "z1"}}))
+			Expect(summary.String()).To(Equal("Added (1):\n  vm_types/large\nReplaced (2):\n  vm_types/default\n  compilation\nRemoved (1):\n  azs/z1\nUnchanged (0):\n"))
+
+			content, err := config.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("vm_types:\n  - name: default\n    cloud_properties:\n      machine_type: n1-standard-4\n  - name: large\ncompilation:\n  workers: 10\n"))
+		})
+
+		It("drops appended definitions identical to existing ones", func() {
+			extended, err := boshconfig.Load(boshconfig.CloudConfig, []byte(`azs:
+- name: z1
+  cloud_properties:
+    zone: us-central1-f
+vm_types:
+- name: default
+  cloud_properties:
+    machine_type: n1-standard-2
+- name: default
+  cloud_properties:
+    machine_type: n1-standard-2
+compilation:
+  workers: 5
+  network: default
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			summary, err := config.Apply(extended)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.Added).To(BeEmpty())
+			Expect(summary.Replaced).To(BeEmpty())
+			Expect(summary.Removed).To(BeEmpty())
+			Expect(summary.Unchanged).To(Equal([]boshconfig.Entry{{Section: "azs", Name: "z1"}, {Section: "vm_types", Name: "default"}, {Section: "compilation"}}))
+
+			content, err := config.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.Count(string(content), "name: default")).To(Equal(1))
+		})
+
+		It("reports appended definitions that differ from existing ones and leaves the config untouched", func() {
+			extended, err := boshconfig.Load(boshconfig.CloudConfig, []byte("vm_types:\n- name: default\n- name: default\n  cloud_properties: {machine_type: n1-standard-8}\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			before, err := config.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = config.Apply(extended)
+			Expect(err).To(MatchError("conflicting definitions for vm_types/default"))
+
+			after, err := config.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(after).To(Equal(before))
+		})
+
+		It("refuses to apply a different kind of config", func() {
+			extended, err := boshconfig.Load(boshconfig.RuntimeConfig, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = config.Apply(extended)
+			Expect(err).To(MatchError("cannot apply a runtime-config to a cloud-config"))
+		})
+	})
+
+	Describe("CompilationWorkers", func() {
+		It("returns the workers of the compilation block", func() {
+			config, err := boshconfig.Load(boshconfig.CloudConfig, []byte(cloudConfig))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.CompilationWorkers()).To(Equal(5))
+		})
+
+		It("returns 0 without a compilation block", func() {
+			config, err := boshconfig.Load(boshconfig.CloudConfig, []byte("vm_types: []\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.CompilationWorkers()).To(Equal(0))
+		})
+
+		It("rejects an invalid compilation block", func() {
+			config, err := boshconfig.Load(boshconfig.CloudConfig, []byte("compilation:\n  workers: many\n"))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = config.CompilationWorkers()
+			Expect(err).To(MatchError(ContainSubstring("invalid compilation block in cloud-config")))
+		})
+	})
+
+	Describe("MergeFiles", func() {
+		It("writes the merged config", func() {
+			dir := GinkgoT().TempDir()
+			configPath := filepath.Join(dir, "cloud-config.yml")
+			extensionPath := filepath.Join(dir, "extension.yml")
+			outputPath := filepath.Join(dir, "merged.yml")
+
+			Expect(os.WriteFile(configPath, []byte(cloudConfig), 0644)).To(Succeed())
+			Expect(os.WriteFile(extensionPath, []byte("disk_types:\n- name: 10GB\n  disk_size: 10240\n"), 0644)).To(Succeed())
+
+			summary, err := boshconfig.MergeFiles(boshconfig.CloudConfig, configPath, extensionPath, outputPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.Added).To(ConsistOf(boshconfig.Entry{Section: "disk_types", Name: "10GB"}))
+
+			merged, err := os.ReadFile(outputPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(merged)).To(HaveSuffix("disk_types:\n  - name: 10GB\n    disk_size: 10240\n"))
+		})
+	})
+})
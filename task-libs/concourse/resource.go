@@ -0,0 +1,32 @@
+package concourse
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadFile reads a file that a resource puts in its directory, such as
+// version or url.
+func ReadFile(dir, name string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("missing files: '%s'", filepath.Join(filepath.Base(dir), name))
+	}
+
+	return string(content), err
+}
+
+// ReadVersion reads the version file of a resource, without surrounding
+// whitespace.
+func ReadVersion(dir string) (string, error) {
+	version, err := ReadFile(dir, "version")
+	return strings.TrimSpace(version), err
+}
+
+// ReadURL reads the url file of a resource, without surrounding whitespace.
+func ReadURL(dir string) (string, error) {
+	url, err := ReadFile(dir, "url")
+	return strings.TrimSpace(url), err
+}
@@ -0,0 +1,33 @@
+package concourse_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/concourse"
+)
+
+var _ = Describe("resource files", func() {
+	var resourceDir string
+
+	BeforeEach(func() {
+		resourceDir = filepath.Join(GinkgoT().TempDir(), "stemcell")
+		Expect(os.Mkdir(resourceDir, 0755)).To(Succeed())
+	})
+
+	It("reads version and url without surrounding whitespace", func() {
+		Expect(os.WriteFile(filepath.Join(resourceDir, "version"), []byte("1.10\n"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(resourceDir, "url"), []byte("https://example.com/stemcell.tgz\n"), 0644)).To(Succeed())
+
+		Expect(concourse.ReadVersion(resourceDir)).To(Equal("1.10"))
+		Expect(concourse.ReadURL(resourceDir)).To(Equal("https://example.com/stemcell.tgz"))
+	})
+
+	It("names the missing file relative to the resource", func() {
+		_, err := concourse.ReadFile(resourceDir, "sha1")
+		Expect(err).To(MatchError("missing files: 'stemcell/sha1'"))
+	})
+})
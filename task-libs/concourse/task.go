@@ -0,0 +1,153 @@
+// Package concourse declares the inputs, outputs and params of a Concourse
+// task once, resolves them in the task's build directory, and checks the
+// declaration against the task's task.yml.
+package concourse
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Task is what a task's Go code expects Concourse to provide.
+type Task struct {
+	Inputs         []string
+	OptionalInputs []string
+	Outputs        []string
+	Params         []string
+}
+
+// Dirs are the directories of a task's inputs and outputs in its build
+// directory.
+type Dirs struct {
+	dirs map[string]string
+}
+
+// Setup finds every input and output in buildDir. A missing optional input
+// is not an error, but any other missing directory is.
+func (t Task) Setup(buildDir string) (Dirs, error) {
+	dirs := Dirs{dirs: map[string]string{}}
+
+	for _, name := range append(append([]string{}, t.Inputs...), t.Outputs...) {
+		dir, err := buildSubDir(buildDir, name)
+		if err != nil {
+			return Dirs{}, err
+		}
+		dirs.dirs[name] = dir
+	}
+
+	for _, name := range t.OptionalInputs {
+		if dir, err := buildSubDir(buildDir, name); err == nil {
+			dirs.dirs[name] = dir
+		}
+	}
+
+	return dirs, nil
+}
+
+// Dir returns the directory of an input or output, or an empty string for an
+// optional input that was not provided.
+func (d Dirs) Dir(name string) string {
+	return d.dirs[name]
+}
+
+// Has reports whether an input or output was provided.
+func (d Dirs) Has(name string) bool {
+	_, ok := d.dirs[name]
+	return ok
+}
+
+// Param returns the value of a declared param. It panics if the param is not
+// declared, so that a typo fails the first test that reaches it.
+func (t Task) Param(name string) string {
+	for _, param := range t.Params {
+		if param == name {
+			return os.Getenv(name)
+		}
+	}
+
+	panic(fmt.Sprintf("param %q is not declared by the task", name))
+}
+
+type taskConfig struct {
+	Inputs []struct {
+		Name     string
+		Optional bool
+	}
+	Outputs []struct {
+		Name string
+	}
+	Params map[string]interface{}
+}
+
+// Validate checks that the task.yml at path provides every declared input,
+// output and param, and that inputs are optional exactly when declared so.
+// task.yml may list more, such as the runtime-ci input the task runs from.
+func (t Task) Validate(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var config taskConfig
+	err = yaml.Unmarshal(content, &config)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	inputs := map[string]bool{}
+	for _, input := range config.Inputs {
+		inputs[input.Name] = input.Optional
+	}
+	outputs := map[string]bool{}
+	for _, output := range config.Outputs {
+		outputs[output.Name] = true
+	}
+
+	var problems []string
+	checkInput := func(name string, optional bool) {
+		configOptional, ok := inputs[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("missing input %q", name))
+		case configOptional != optional:
+			problems = append(problems, fmt.Sprintf("input %q should have optional: %t", name, optional))
+		}
+	}
+
+	for _, name := range t.Inputs {
+		checkInput(name, false)
+	}
+	for _, name := range t.OptionalInputs {
+		checkInput(name, true)
+	}
+	for _, name := range t.Outputs {
+		if !outputs[name] {
+			problems = append(problems, fmt.Sprintf("missing output %q", name))
+		}
+	}
+	for _, name := range t.Params {
+		if _, ok := config.Params[name]; !ok {
+			problems = append(problems, fmt.Sprintf("missing param %q", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s does not match the task: %s", path, strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+func buildSubDir(buildDir, subDir string) (string, error) {
+	dir := filepath.Join(buildDir, subDir)
+	_, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("missing sub directory '%s' in build directory '%s'", subDir, buildDir)
+	}
+
+	return dir, nil
+}
@@ -0,0 +1,113 @@
+package concourse_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/concourse"
+)
+
+var _ = Describe("Task", func() {
+	var (
+		buildDir string
+		task     concourse.Task
+	)
+
+	BeforeEach(func() {
+		buildDir = GinkgoT().TempDir()
+		task = concourse.Task{
+			Inputs:         []string{"cf-deployment", "stemcell"},
+			OptionalInputs: []string{"release-list"},
+			Outputs:        []string{"updated-cf-deployment"},
+			Params:         []string{"SOME_PARAM"},
+		}
+	})
+
+	Describe("Setup", func() {
+		BeforeEach(func() {
+			for _, dir := range []string{"cf-deployment", "stemcell", "updated-cf-deployment"} {
+				Expect(os.Mkdir(filepath.Join(buildDir, dir), 0755)).To(Succeed())
+			}
+		})
+
+		It("resolves every input and output", func() {
+			dirs, err := task.Setup(buildDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dirs.Dir("stemcell")).To(Equal(filepath.Join(buildDir, "stemcell")))
+			Expect(dirs.Dir("updated-cf-deployment")).To(Equal(filepath.Join(buildDir, "updated-cf-deployment")))
+			Expect(dirs.Has("release-list")).To(BeFalse())
+			Expect(dirs.Dir("release-list")).To(BeEmpty())
+		})
+
+		It("resolves optional inputs when they are provided", func() {
+			Expect(os.Mkdir(filepath.Join(buildDir, "release-list"), 0755)).To(Succeed())
+
+			dirs, err := task.Setup(buildDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dirs.Has("release-list")).To(BeTrue())
+		})
+
+		It("fails when a required directory is missing", func() {
+			Expect(os.Remove(filepath.Join(buildDir, "updated-cf-deployment"))).To(Succeed())
+
+			_, err := task.Setup(buildDir)
+			Expect(err).To(MatchError(fmt.Sprintf("missing sub directory 'updated-cf-deployment' in build directory '%s'", buildDir)))
+		})
+	})
+
+	Describe("Param", func() {
+		It("reads declared params from the environment", func() {
+			GinkgoT().Setenv("SOME_PARAM", "some-value")
+			Expect(task.Param("SOME_PARAM")).To(Equal("some-value"))
+		})
+
+		It("panics for undeclared params", func() {
+			Expect(func() { task.Param("OTHER_PARAM") }).To(PanicWith(`param "OTHER_PARAM" is not declared by the task`))
+		})
+	})
+
+	Describe("Validate", func() {
+		var taskYML string
+
+		BeforeEach(func() {
+			taskYML = filepath.Join(buildDir, "task.yml")
+		})
+
+		It("accepts a task.yml that provides everything, and more", func() {
+			Expect(os.WriteFile(taskYML, []byte(`---
+inputs:
+- name: runtime-ci
+- name: cf-deployment
+- name: stemcell
+- name: release-list
+  optional: true
+outputs:
+- name: updated-cf-deployment
+params:
+  SOME_PARAM:
+`), 0644)).To(Succeed())
+
+			Expect(task.Validate(taskYML)).To(Succeed())
+		})
+
+		It("reports everything that does not match", func() {
+			Expect(os.WriteFile(taskYML, []byte(`---
+inputs:
+- name: cf-deployment
+  optional: true
+- name: release-list
+outputs: []
+`), 0644)).To(Succeed())
+
+			Expect(task.Validate(taskYML)).To(MatchError(taskYML + ` does not match the task: ` +
+				`input "cf-deployment" should have optional: false, missing input "stemcell", ` +
+				`input "release-list" should have optional: true, missing output "updated-cf-deployment", ` +
+				`missing param "SOME_PARAM"`))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package deploymentdiff_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDeploymentdiff(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Deploymentdiff Suite")
+}
@@ -0,0 +1,357 @@
+// Package deploymentdiff compares two checkouts of cf-deployment: the
+// releases and stemcells of the manifest, every ops file, and
+// use-compiled-releases.yml.
+package deploymentdiff
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+)
+
+const (
+	manifestPath         = "cf-deployment.yml"
+	compiledReleasesPath = "operations/use-compiled-releases.yml"
+)
+
+// Release change kinds.
+const (
+	Added      = "added"
+	Removed    = "removed"
+	Upgraded   = "upgraded"
+	Downgraded = "downgraded"
+)
+
+// ReleaseChange is a release whose version differs between the two
+// checkouts. Old is nil for an added release and New for a removed one.
+type ReleaseChange struct {
+	Name string
+	Kind string
+	Old  *bosh.Release
+	New  *bosh.Release
+	// Files are the manifest and ops files that declare the release.
+	Files []string
+}
+
+// StemcellChange is a manifest stemcell alias whose stemcell differs between
+// the two checkouts.
+type StemcellChange struct {
+	Alias string
+	Old   *bosh.Stemcell
+	New   *bosh.Stemcell
+}
+
+// CompiledReleasesChange summarises the differences of
+// operations/use-compiled-releases.yml.
+type CompiledReleasesChange struct {
+	OldStemcells []string
+	NewStemcells []string
+	Added        []string
+	Removed      []string
+	Recompiled   []string
+}
+
+// IsEmpty reports whether use-compiled-releases.yml is unchanged.
+func (c CompiledReleasesChange) IsEmpty() bool {
+	return strings.Join(c.OldStemcells, ",") == strings.Join(c.NewStemcells, ",") &&
+		len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Recompiled) == 0
+}
+
+// Changes are the differences between the main branch and a release
+// candidate.
+type Changes struct {
+	Releases         []ReleaseChange
+	Stemcells        []StemcellChange
+	CompiledReleases CompiledReleasesChange
+	NewOpsFiles      []string
+	RemovedOpsFiles  []string
+	UpdatedOpsFiles  []string
+}
+
+// checkout is the releases and stemcells declared by one cf-deployment
+// checkout.
+type checkout struct {
+	manifest         bosh.Manifest
+	opsFiles         map[string][]byte
+	releases         map[string]bosh.Release
+	releaseFiles     map[string][]string
+	compiledReleases []bosh.Release
+}
+
+// Diff compares the cf-deployment checkout in mainDir with the release
+// candidate in candidateDir. It covers cf-deployment.yml, every ops file, and
+// use-compiled-releases.yml.
+func Diff(mainDir, candidateDir string) (Changes, error) {
+	main, err := loadCheckout(mainDir)
+	if err != nil {
+		return Changes{}, err
+	}
+
+	candidate, err := loadCheckout(candidateDir)
+	if err != nil {
+		return Changes{}, err
+	}
+
+	var changes Changes
+
+	changes.Releases = diffReleases(main, candidate)
+	changes.Stemcells = diffStemcells(main.manifest.Stemcells, candidate.manifest.Stemcells)
+	changes.CompiledReleases = diffCompiledReleases(main.compiledReleases, candidate.compiledReleases)
+
+	for _, path := range sortedKeys(candidate.opsFiles) {
+		old, ok := main.opsFiles[path]
+		switch {
+		case !ok:
+			changes.NewOpsFiles = append(changes.NewOpsFiles, path)
+		case string(old) != string(candidate.opsFiles[path]):
+			changes.UpdatedOpsFiles = append(changes.UpdatedOpsFiles, path)
+		}
+	}
+	for _, path := range sortedKeys(main.opsFiles) {
+		if _, ok := candidate.opsFiles[path]; !ok {
+			changes.RemovedOpsFiles = append(changes.RemovedOpsFiles, path)
+		}
+	}
+
+	return changes, nil
+}
+
+func loadCheckout(dir string) (checkout, error) {
+	c := checkout{
+		opsFiles:     map[string][]byte{},
+		releases:     map[string]bosh.Release{},
+		releaseFiles: map[string][]string{},
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, manifestPath))
+	if err != nil {
+		return c, fmt.Errorf("failed to read %s: %w", manifestPath, err)
+	}
+
+	c.manifest, err = bosh.NewManifestFromFile(content)
+	if err != nil {
+		return c, fmt.Errorf("failed to parse %s in %s: %w", manifestPath, dir, err)
+	}
+
+	for _, release := range c.manifest.Releases {
+		c.addRelease(manifestPath, release)
+	}
+
+	operationsDir := filepath.Join(dir, "operations")
+	err = filepath.WalkDir(operationsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || filepath.Ext(path) != ".yml" {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		// Not every yml file under operations is an ops file, e.g. example
+		// vars files, so files that are not a list of ops are skipped.
+		releases, err := bosh.ParseReleaseOps(content)
+		if err != nil {
+			releases = nil
+		}
+
+		if rel == compiledReleasesPath {
+			c.compiledReleases = releases
+			return nil
+		}
+
+		c.opsFiles[rel] = content
+		for _, release := range releases {
+			c.addRelease(rel, release)
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// addRelease records a release declared by a file. The manifest is read
+// first, so it wins over ops files declaring the same release.
+func (c *checkout) addRelease(file string, release bosh.Release) {
+	if release.Name == "" || release.Version == "" {
+		return
+	}
+
+	if _, ok := c.releases[release.Name]; !ok {
+		c.releases[release.Name] = release
+	}
+	c.releaseFiles[release.Name] = append(c.releaseFiles[release.Name], file)
+}
+
+func diffReleases(main, candidate checkout) []ReleaseChange {
+	names := map[string]bool{}
+	for name := range main.releases {
+		names[name] = true
+	}
+	for name := range candidate.releases {
+		names[name] = true
+	}
+
+	var changes []ReleaseChange
+	for _, name := range sortedKeys(names) {
+		old, inMain := main.releases[name]
+		updated, inCandidate := candidate.releases[name]
+
+		change := ReleaseChange{Name: name, Files: candidate.releaseFiles[name]}
+		switch {
+		case !inMain:
+			change.Kind = Added
+			change.New = &updated
+		case !inCandidate:
+			change.Kind = Removed
+			change.Old = &old
+			change.Files = main.releaseFiles[name]
+		case old.Version == updated.Version:
+			continue
+		default:
+			change.Old, change.New = &old, &updated
+			change.Kind = Upgraded
+			if compareVersions(updated.Version, old.Version) < 0 {
+				change.Kind = Downgraded
+			}
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// compareVersions orders release versions by semver where both parse, and
+// treats any other change as an upgrade.
+func compareVersions(version, base string) int {
+	v, err := semver.ParseTolerant(version)
+	if err != nil {
+		return 1
+	}
+
+	b, err := semver.ParseTolerant(base)
+	if err != nil {
+		return 1
+	}
+
+	return v.Compare(b)
+}
+
+func diffStemcells(main, candidate []bosh.Stemcell) []StemcellChange {
+	var changes []StemcellChange
+
+	oldByAlias := map[string]bosh.Stemcell{}
+	for _, stemcell := range main {
+		oldByAlias[stemcell.Alias] = stemcell
+	}
+
+	seen := map[string]bool{}
+	for _, stemcell := range candidate {
+		stemcell := stemcell
+		seen[stemcell.Alias] = true
+
+		old, ok := oldByAlias[stemcell.Alias]
+		switch {
+		case !ok:
+			changes = append(changes, StemcellChange{Alias: stemcell.Alias, New: &stemcell})
+		case old.OS != stemcell.OS || old.Version != stemcell.Version:
+			changes = append(changes, StemcellChange{Alias: stemcell.Alias, Old: &old, New: &stemcell})
+		}
+	}
+
+	for _, stemcell := range main {
+		stemcell := stemcell
+		if !seen[stemcell.Alias] {
+			changes = append(changes, StemcellChange{Alias: stemcell.Alias, Old: &stemcell})
+		}
+	}
+
+	return changes
+}
+
+func diffCompiledReleases(main, candidate []bosh.Release) CompiledReleasesChange {
+	var change CompiledReleasesChange
+
+	change.OldStemcells = compiledStemcells(main)
+	change.NewStemcells = compiledStemcells(candidate)
+
+	oldByName := map[string]bosh.Release{}
+	for _, release := range main {
+		oldByName[release.Name] = release
+	}
+
+	newByName := map[string]bool{}
+	for _, release := range candidate {
+		newByName[release.Name] = true
+
+		old, ok := oldByName[release.Name]
+		switch {
+		case !ok:
+			change.Added = append(change.Added, release.Name)
+		case old.Version != release.Version || old.SHA1 != release.SHA1:
+			change.Recompiled = append(change.Recompiled, release.Name)
+		}
+	}
+
+	for _, release := range main {
+		if !newByName[release.Name] {
+			change.Removed = append(change.Removed, release.Name)
+		}
+	}
+
+	sort.Strings(change.Added)
+	sort.Strings(change.Removed)
+	sort.Strings(change.Recompiled)
+
+	return change
+}
+
+func compiledStemcells(releases []bosh.Release) []string {
+	seen := map[string]bool{}
+	for _, release := range releases {
+		for _, stemcell := range release.CompiledStemcells() {
+			seen[fmt.Sprintf("%s/%s", stemcell.OS, stemcell.Version)] = true
+		}
+	}
+
+	return sortedKeys(seen)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// IsEmpty reports whether the checkouts declare the same releases, stemcells
+// and ops files.
+func (c Changes) IsEmpty() bool {
+	return len(c.Releases) == 0 && len(c.Stemcells) == 0 && c.CompiledReleases.IsEmpty() &&
+		len(c.NewOpsFiles) == 0 && len(c.RemovedOpsFiles) == 0 && len(c.UpdatedOpsFiles) == 0
+}
@@ -0,0 +1,196 @@
+package deploymentdiff_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/task-libs/deploymentdiff"
+)
+
+var _ = Describe("Deploymentdiff", func() {
+	var (
+		mainDir      string
+		candidateDir string
+	)
+
+	writeFile := func(dir, path, content string) {
+		path = filepath.Join(dir, path)
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		mainDir, err = os.MkdirTemp("", "cf-deployment-main-")
+		Expect(err).NotTo(HaveOccurred())
+		candidateDir, err = os.MkdirTemp("", "cf-deployment-release-candidate-")
+		Expect(err).NotTo(HaveOccurred())
+
+		writeFile(mainDir, "cf-deployment.yml", `---
+releases:
+- name: capi
+  version: 1.2.3
+  url: https://bosh.io/d/github.com/cloudfoundry/capi-release?v=1.2.3
+- name: uaa
+  version: 4.5.6
+  url: https://bosh.io/d/github.com/cloudfoundry/uaa-release?v=4.5.6
+- name: garden
+  version: 1.0.0
+  url: https://github.com/cloudfoundry/garden-runc-release/releases/download/v1.0.0/garden-runc-1.0.0.tgz
+stemcells:
+- alias: default
+  os: ubuntu-jammy
+  version: "1.10"
+`)
+		writeFile(candidateDir, "cf-deployment.yml", `---
+releases:
+- name: capi
+  version: 1.3.0
+  url: https://bosh.io/d/github.com/cloudfoundry/capi-release?v=1.3.0
+- name: uaa
+  version: 4.5.6
+  url: https://bosh.io/d/github.com/cloudfoundry/uaa-release?v=4.5.6
+- name: garden
+  version: 0.9.0
+  url: https://github.com/cloudfoundry/garden-runc-release/releases/download/v0.9.0/garden-runc-0.9.0.tgz
+stemcells:
+- alias: default
+  os: ubuntu-jammy
+  version: "1.11"
+`)
+
+		writeFile(mainDir, "operations/use-postgres.yml", `---
+- type: replace
+  path: /releases/name=postgres?
+  value:
+    name: postgres
+    version: "40"
+    url: https://bosh.io/d/github.com/cloudfoundry/postgres-release?v=40
+`)
+		writeFile(candidateDir, "operations/use-postgres.yml", `---
+- type: replace
+  path: /releases/name=postgres?
+  value:
+    name: postgres
+    version: "41"
+    url: https://bosh.io/d/github.com/cloudfoundry/postgres-release?v=41
+`)
+		writeFile(candidateDir, "operations/experimental/add-otel.yml", `---
+- type: replace
+  path: /releases/-
+  value:
+    name: otel
+    version: 0.1.0
+    url: https://bosh.io/d/github.com/cloudfoundry/otel-collector-release?v=0.1.0
+`)
+		writeFile(mainDir, "operations/scale-to-one-az.yml", `---
+- type: replace
+  path: /instance_groups/name=api/azs
+  value: [z1]
+`)
+		writeFile(mainDir, "operations/example-vars-files/vars.yml", "key: value\n")
+		writeFile(candidateDir, "operations/example-vars-files/vars.yml", "key: value\n")
+
+		writeFile(mainDir, "operations/use-compiled-releases.yml", `---
+- type: replace
+  path: /releases/name=capi
+  value:
+    name: capi
+    version: 1.2.3
+    sha1: aaa
+    stemcell:
+      os: ubuntu-jammy
+      version: "1.10"
+- type: replace
+  path: /releases/name=uaa
+  value:
+    name: uaa
+    version: 4.5.6
+    sha1: bbb
+    stemcell:
+      os: ubuntu-jammy
+      version: "1.10"
+`)
+		writeFile(candidateDir, "operations/use-compiled-releases.yml", `---
+- type: replace
+  path: /releases/name=capi
+  value:
+    name: capi
+    version: 1.3.0
+    sha1: ccc
+    stemcell:
+      os: ubuntu-jammy
+      version: "1.11"
+`)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(mainDir)).To(Succeed())
+		Expect(os.RemoveAll(candidateDir)).To(Succeed())
+	})
+
+	Describe("Diff", func() {
+		It("classifies release changes across the manifest and ops files", func() {
+			changes, err := deploymentdiff.Diff(mainDir, candidateDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			var summary [][]string
+			for _, change := range changes.Releases {
+				summary = append(summary, append([]string{change.Name, change.Kind}, change.Files...))
+			}
+			Expect(summary).To(Equal([][]string{
+				{"capi", deploymentdiff.Upgraded, "cf-deployment.yml"},
+				{"garden", deploymentdiff.Downgraded, "cf-deployment.yml"},
+				{"otel", deploymentdiff.Added, "operations/experimental/add-otel.yml"},
+				{"postgres", deploymentdiff.Upgraded, "operations/use-postgres.yml"},
+			}))
+		})
+
+		It("reports stemcell changes", func() {
+			changes, err := deploymentdiff.Diff(mainDir, candidateDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(changes.Stemcells).To(Equal([]deploymentdiff.StemcellChange{{
+				Alias: "default",
+				Old:   &bosh.Stemcell{Alias: "default", OS: "ubuntu-jammy", Version: "1.10"},
+				New:   &bosh.Stemcell{Alias: "default", OS: "ubuntu-jammy", Version: "1.11"},
+			}}))
+		})
+
+		It("summarises use-compiled-releases.yml", func() {
+			changes, err := deploymentdiff.Diff(mainDir, candidateDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(changes.CompiledReleases).To(Equal(deploymentdiff.CompiledReleasesChange{
+				OldStemcells: []string{"ubuntu-jammy/1.10"},
+				NewStemcells: []string{"ubuntu-jammy/1.11"},
+				Removed:      []string{"uaa"},
+				Recompiled:   []string{"capi"},
+			}))
+		})
+
+		It("lists new, removed and updated ops files", func() {
+			changes, err := deploymentdiff.Diff(mainDir, candidateDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(changes.NewOpsFiles).To(Equal([]string{"operations/experimental/add-otel.yml"}))
+			Expect(changes.RemovedOpsFiles).To(Equal([]string{"operations/scale-to-one-az.yml"}))
+			Expect(changes.UpdatedOpsFiles).To(Equal([]string{"operations/use-postgres.yml"}))
+		})
+
+		Context("when a checkout has no manifest", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(filepath.Join(mainDir, "cf-deployment.yml"))).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				_, err := deploymentdiff.Diff(mainDir, candidateDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to read cf-deployment.yml")))
+			})
+		})
+	})
+})
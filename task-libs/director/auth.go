@@ -0,0 +1,67 @@
+package director
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// uaaAuth fetches and caches client-credentials tokens from UAA.
+type uaaAuth struct {
+	url          string
+	client       string
+	clientSecret string
+	httpClient   *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+func (a *uaaAuth) token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Refresh a little early so a token does not expire mid-request.
+	if a.accessToken != "" && time.Now().Add(30*time.Second).Before(a.expiresAt) {
+		return a.accessToken, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(a.client), url.QueryEscape(a.clientSecret))
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch UAA token: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch UAA token: UAA responded with status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse UAA token: %w", err)
+	}
+
+	a.accessToken = token.AccessToken
+	a.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+
+	return a.accessToken, nil
+}
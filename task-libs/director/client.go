@@ -0,0 +1,243 @@
+package director
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config targets a BOSH Director.
+type Config struct {
+	// URL of the Director, e.g. https://10.0.0.6:25555.
+	URL          string
+	Client       string
+	ClientSecret string
+	// CACert is the PEM encoded certificate used to verify both the Director
+	// and UAA. The system roots are used when it is empty.
+	CACert string
+
+	// PollInterval is how often running tasks are checked. Defaults to 2s.
+	PollInterval time.Duration
+}
+
+// ConfigFromEnv reads the BOSH_* variables used by the bosh CLI.
+func ConfigFromEnv() Config {
+	address := os.Getenv("BOSH_ENVIRONMENT")
+	if address != "" && !strings.Contains(address, "://") {
+		address = "https://" + address
+	}
+	if u, err := url.Parse(address); err == nil && address != "" && u.Port() == "" {
+		u.Host += ":25555"
+		address = u.String()
+	}
+
+	caCert := os.Getenv("BOSH_CA_CERT")
+	if content, err := os.ReadFile(caCert); err == nil {
+		caCert = string(content)
+	}
+
+	return Config{
+		URL:          address,
+		Client:       os.Getenv("BOSH_CLIENT"),
+		ClientSecret: os.Getenv("BOSH_CLIENT_SECRET"),
+		CACert:       caCert,
+	}
+}
+
+// Client talks to the Director REST API.
+type Client struct {
+	url          string
+	httpClient   *http.Client
+	auth         *uaaAuth
+	client       string
+	clientSecret string
+	pollInterval time.Duration
+}
+
+// Error is returned when the Director responds with an unexpected status.
+type Error struct {
+	StatusCode  int
+	Code        int
+	Description string
+}
+
+func (e Error) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("director responded with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("director responded with status %d: %s", e.StatusCode, e.Description)
+}
+
+// NewClient creates a Director client. The Director's /info endpoint is used
+// to discover the UAA that issues tokens for the configured client. Directors
+// without a UAA are sent the client and secret with basic auth instead.
+func NewClient(ctx context.Context, config Config) (*Client, error) {
+	if config.URL == "" {
+		return nil, errors.New("missing director URL")
+	}
+
+	httpClient, err := newHTTPClient(config.CACert)
+	if err != nil {
+		return nil, err
+	}
+
+	pollInterval := config.PollInterval
+	if pollInterval == 0 {
+		pollInterval = 2 * time.Second
+	}
+
+	client := &Client{
+		url:          strings.TrimSuffix(config.URL, "/"),
+		httpClient:   httpClient,
+		pollInterval: pollInterval,
+	}
+
+	info, err := client.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.UserAuthentication.Type == "uaa" {
+		client.auth = &uaaAuth{
+			url:          strings.TrimSuffix(info.UserAuthentication.Options.URL, "/"),
+			client:       config.Client,
+			clientSecret: config.ClientSecret,
+			httpClient:   httpClient,
+		}
+	} else {
+		client.client = config.Client
+		client.clientSecret = config.ClientSecret
+	}
+
+	return client, nil
+}
+
+func newHTTPClient(caCert string) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caCert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(caCert)) {
+			return nil, errors.New("failed to parse director CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		// The Director answers asynchronous requests with a redirect to the
+		// task it started, which is read from the Location header instead.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, nil
+}
+
+// Info is the response of the unauthenticated /info endpoint.
+type Info struct {
+	Name               string
+	UUID               string
+	Version            string
+	UserAuthentication struct {
+		Type    string
+		Options struct {
+			URL string
+		}
+	} `json:"user_authentication"`
+}
+
+func (c *Client) Info(ctx context.Context) (Info, error) {
+	var info Info
+	err := c.getJSON(ctx, "/info", &info)
+	return info, err
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c.auth != nil:
+		token, err := c.auth.token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case c.client != "":
+		req.SetBasicAuth(c.client, c.clientSecret)
+	}
+
+	return req, nil
+}
+
+func (c *Client) do(req *http.Request, expectedStatus ...int) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range expectedStatus {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	directorErr := Error{StatusCode: resp.StatusCode}
+	body, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(body, &directorErr) != nil {
+		directorErr.Description = strings.TrimSpace(string(body))
+	}
+
+	return nil, directorErr
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, v interface{}) error {
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// startTask sends a request that the Director runs asynchronously and
+// returns the id of the task it redirected to.
+func (c *Client) startTask(req *http.Request) (int, error) {
+	resp, err := c.do(req, http.StatusFound, http.StatusSeeOther)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(location.Path, "/tasks/"))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected task location %q", resp.Header.Get("Location"))
+	}
+
+	return id, nil
+}
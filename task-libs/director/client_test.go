@@ -0,0 +1,296 @@
+package director_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/director"
+	"github.com/cloudfoundry/runtime-ci/task-libs/taskevents"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		server      *httptest.Server
+		mux         *http.ServeMux
+		caCert      string
+		tokenCalls  int
+		taskPolls   int
+		lastRequest *http.Request
+		lastBody    []byte
+
+		client *director.Client
+	)
+
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer some-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+
+	BeforeEach(func() {
+		tokenCalls = 0
+		taskPolls = 0
+		mux = http.NewServeMux()
+		server = httptest.NewTLSServer(mux)
+		caCert = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+		mux.HandleFunc("/info", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"name":"some-director","user_authentication":{"type":"uaa","options":{"url":"%s/uaa"}}}`, server.URL)
+		})
+		mux.HandleFunc("/uaa/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+			tokenCalls++
+			client, secret, ok := r.BasicAuth()
+			Expect(ok).To(BeTrue())
+			Expect(client).To(Equal("some-client"))
+			Expect(secret).To(Equal("some-secret"))
+			Expect(r.FormValue("grant_type")).To(Equal("client_credentials"))
+			fmt.Fprint(w, `{"access_token":"some-token","expires_in":3600}`)
+		})
+		mux.HandleFunc("/deployments", func(w http.ResponseWriter, r *http.Request) {
+			if !authorized(w, r) {
+				return
+			}
+			lastRequest = r
+			lastBody, _ = io.ReadAll(r.Body)
+			if r.Method == http.MethodPost {
+				w.Header().Set("Location", server.URL+"/tasks/12")
+				w.WriteHeader(http.StatusFound)
+				return
+			}
+			fmt.Fprint(w, `[{"name":"cf","releases":[{"name":"release-a","version":"1.0"}],"stemcells":[{"name":"bosh-stemcell","version":"1.2"}],"teams":[]}]`)
+		})
+		mux.HandleFunc("/tasks/12", func(w http.ResponseWriter, r *http.Request) {
+			if !authorized(w, r) {
+				return
+			}
+			taskPolls++
+			state := "processing"
+			if taskPolls > 2 {
+				state = "done"
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 12, "state": state, "description": "create deployment"}) //nolint:errcheck
+		})
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		client, err = director.NewClient(context.Background(), director.Config{
+			URL:          server.URL,
+			Client:       "some-client",
+			ClientSecret: "some-secret",
+			CACert:       caCert,
+			PollInterval: time.Millisecond,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("NewClient", func() {
+		It("fails to verify the director without its CA", func() {
+			_, err := director.NewClient(context.Background(), director.Config{URL: server.URL})
+			Expect(err).To(MatchError(ContainSubstring("certificate")))
+		})
+
+		It("fails with an invalid CA", func() {
+			_, err := director.NewClient(context.Background(), director.Config{URL: server.URL, CACert: "not-a-cert"})
+			Expect(err).To(MatchError("failed to parse director CA certificate"))
+		})
+
+		Context("when the director does not use UAA", func() {
+			BeforeEach(func() {
+				mux.HandleFunc("/stemcells", func(w http.ResponseWriter, r *http.Request) {
+					client, secret, ok := r.BasicAuth()
+					if !ok || client != "some-client" || secret != "some-secret" {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					fmt.Fprint(w, `[]`)
+				})
+			})
+
+			It("sends the client and secret with basic auth", func() {
+				basicServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path == "/info" {
+						fmt.Fprint(w, `{"name":"some-director","user_authentication":{"type":"basic","options":{}}}`)
+						return
+					}
+					mux.ServeHTTP(w, r)
+				}))
+				defer basicServer.Close()
+
+				basicClient, err := director.NewClient(context.Background(), director.Config{
+					URL:          basicServer.URL,
+					Client:       "some-client",
+					ClientSecret: "some-secret",
+					CACert:       string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: basicServer.Certificate().Raw})),
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = basicClient.Stemcells(context.Background())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tokenCalls).To(BeZero())
+			})
+		})
+	})
+
+	Describe("Deployments", func() {
+		It("authenticates with UAA and lists deployments", func() {
+			deployments, err := client.Deployments(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deployments).To(Equal([]director.Deployment{{
+				Name:      "cf",
+				Releases:  []director.NameVersion{{Name: "release-a", Version: "1.0"}},
+				Stemcells: []director.NameVersion{{Name: "bosh-stemcell", Version: "1.2"}},
+				Teams:     []string{},
+			}}))
+		})
+
+		It("reuses the token until it expires", func() {
+			_, err := client.Deployments(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			_, err = client.Deployments(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(tokenCalls).To(Equal(1))
+		})
+	})
+
+	Describe("Deploy", func() {
+		It("posts the manifest and returns the task id", func() {
+			taskID, err := client.Deploy(context.Background(), []byte("name: cf"), director.DeployOptions{Recreate: true, SkipDrain: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(taskID).To(Equal(12))
+
+			Expect(lastRequest.Header.Get("Content-Type")).To(Equal("text/yaml"))
+			Expect(lastRequest.URL.Query().Get("recreate")).To(Equal("true"))
+			Expect(lastRequest.URL.Query().Get("skip_drain")).To(Equal("*"))
+			Expect(string(lastBody)).To(Equal("name: cf"))
+		})
+	})
+
+	Describe("WaitForTask", func() {
+		It("polls until the task is done", func() {
+			task, err := client.WaitForTask(context.Background(), 12)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(task.State).To(Equal("done"))
+			Expect(taskPolls).To(Equal(3))
+		})
+
+		It("stops polling once the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			mux.HandleFunc("/tasks/14", func(w http.ResponseWriter, r *http.Request) {
+				cancel()
+				fmt.Fprint(w, `{"id":14,"state":"processing"}`)
+			})
+
+			_, err := client.WaitForTask(ctx, 14)
+			Expect(err).To(MatchError(context.Canceled))
+		})
+	})
+
+	Describe("FollowTask", func() {
+		var ranges []string
+
+		BeforeEach(func() {
+			ranges = nil
+			mux.HandleFunc("/tasks/12/output", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get("type")).To(Equal("event"))
+				ranges = append(ranges, r.Header.Get("Range"))
+
+				output := `{"time":1700000000,"stage":"Compiling packages","total":1,"task":"golang/abc","index":1,"state":"started"}` + "\n"
+				if taskPolls > 1 {
+					output += `{"time":1700000060,"stage":"Compiling packages","total":1,"task":"golang/abc","index":1,"state":"finished"}` + "\n"
+				}
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader([]byte(output)))
+			})
+		})
+
+		It("only downloads the events after the ones it has seen", func() {
+			_, err := client.FollowTask(context.Background(), 12, func(taskevents.Event) {})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ranges).To(Equal([]string{"", "bytes=107-", "bytes=215-"}))
+		})
+
+		It("passes each new event to the handler as it arrives", func() {
+			var states []string
+			task, err := client.FollowTask(context.Background(), 12, func(event taskevents.Event) {
+				states = append(states, event.State)
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(task.State).To(Equal("done"))
+			Expect(states).To(Equal([]string{"started", "finished"}))
+		})
+	})
+
+	Describe("ExportRelease", func() {
+		BeforeEach(func() {
+			mux.HandleFunc("/releases/export", func(w http.ResponseWriter, r *http.Request) {
+				var request director.ExportReleaseRequest
+				Expect(json.NewDecoder(r.Body).Decode(&request)).To(Succeed())
+				Expect(request).To(Equal(director.ExportReleaseRequest{
+					Deployment:      "cf",
+					Release:         "release-a",
+					ReleaseVersion:  "1.0",
+					StemcellOS:      "ubuntu-jammy",
+					StemcellVersion: "1.2",
+				}))
+				w.Header().Set("Location", "/tasks/13")
+				w.WriteHeader(http.StatusFound)
+			})
+			mux.HandleFunc("/tasks/13/output", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get("type")).To(Equal("result"))
+				fmt.Fprintln(w, `{"blobstore_id":"some-blob","sha1":"some-sha"}`)
+			})
+			mux.HandleFunc("/resources/some-blob", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "tarball-contents")
+			})
+		})
+
+		It("exports, reads the result and downloads the tarball", func() {
+			taskID, err := client.ExportRelease(context.Background(), director.ExportReleaseRequest{
+				Deployment:      "cf",
+				Release:         "release-a",
+				ReleaseVersion:  "1.0",
+				StemcellOS:      "ubuntu-jammy",
+				StemcellVersion: "1.2",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(taskID).To(Equal(13))
+
+			exported, err := client.ExportReleaseResult(context.Background(), taskID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exported).To(Equal(director.ExportedRelease{BlobstoreID: "some-blob", SHA1: "some-sha"}))
+
+			tarball := new(bytes.Buffer)
+			Expect(client.DownloadResource(context.Background(), exported.BlobstoreID, tarball)).To(Succeed())
+			Expect(tarball.String()).To(Equal("tarball-contents"))
+		})
+	})
+
+	Describe("errors", func() {
+		It("returns the director error description", func() {
+			mux.HandleFunc("/stemcells", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"code":70000,"description":"Stemcell not found"}`)
+			})
+
+			_, err := client.Stemcells(context.Background())
+			Expect(err).To(MatchError(director.Error{StatusCode: http.StatusNotFound, Code: 70000, Description: "Stemcell not found"}))
+		})
+	})
+})
@@ -0,0 +1,20 @@
+package fakedirector
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/onsi/gomega/gexec"
+)
+
+// InstallFakeBOSH builds the fakebosh command and links it into dir as
+// "bosh", so that dir can be put at the front of the PATH of the code under
+// test. Call gexec.CleanupBuildArtifacts when done.
+func InstallFakeBOSH(dir string) error {
+	path, err := gexec.Build("github.com/cloudfoundry/runtime-ci/task-libs/director/fakedirector/fakebosh")
+	if err != nil {
+		return err
+	}
+
+	return os.Symlink(path, filepath.Join(dir, "bosh"))
+}
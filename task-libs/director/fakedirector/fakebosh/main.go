@@ -0,0 +1,398 @@
+// Command fakebosh is a stand-in for the bosh CLI. It understands the
+// commands the tasks run, talks to the Director named by BOSH_ENVIRONMENT
+// (usually a fakedirector) and prints --json output like the real CLI.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/director"
+)
+
+type table struct {
+	Content string
+	Header  map[string]string
+	Rows    []map[string]string
+	Notes   []string
+}
+
+type output struct {
+	Tables []table
+	Blocks []string
+	Lines  []string
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fail(output{}, errors.New("Expected a command"))
+	}
+
+	flags := pflag.NewFlagSet(os.Args[1], pflag.ContinueOnError)
+	deployment := flags.StringP("deployment", "d", os.Getenv("BOSH_DEPLOYMENT"), "")
+	flags.BoolP("non-interactive", "n", false, "")
+	flags.Bool("json", false, "")
+	flags.StringArrayP("ops-file", "o", nil, "")
+	flags.StringArrayP("var", "v", nil, "")
+	recreate := flags.Bool("recreate", false, "")
+	fix := flags.Bool("fix", false, "")
+	skipDrain := flags.Bool("skip-drain", false, "")
+	dryRun := flags.Bool("dry-run", false, "")
+	force := flags.Bool("force", false, "")
+	event := flags.Bool("event", false, "")
+	result := flags.Bool("result", false, "")
+	flags.Bool("raw", false, "")
+	recent := flags.IntP("recent", "r", 0, "")
+	flags.Lookup("recent").NoOptDefVal = "30"
+	dir := flags.String("dir", ".", "")
+
+	out := output{Lines: []string{fmt.Sprintf("Using environment '%s' as client '%s'", os.Getenv("BOSH_ENVIRONMENT"), os.Getenv("BOSH_CLIENT"))}}
+
+	err := flags.Parse(os.Args[2:])
+	if err != nil {
+		fail(out, err)
+	}
+
+	ctx := context.Background()
+	client, err := director.NewClient(ctx, director.ConfigFromEnv())
+	if err != nil {
+		fail(out, err)
+	}
+
+	args := flags.Args()
+
+	switch os.Args[1] {
+	case "deployments":
+		err = deployments(ctx, client, &out)
+	case "manifest":
+		err = manifest(ctx, client, &out, *deployment)
+	case "cloud-config":
+		err = cloudConfig(ctx, client, &out)
+	case "stemcells":
+		err = stemcells(ctx, client, &out)
+	case "releases":
+		err = releases(ctx, client, &out)
+	case "tasks":
+		err = tasks(ctx, client, &out, *deployment, *recent)
+	case "task":
+		err = showTask(ctx, client, &out, args, *event, *result)
+	case "cancel-task":
+		err = cancelTask(ctx, client, args)
+	case "deploy":
+		err = deploy(ctx, client, &out, args, director.DeployOptions{
+			Recreate:  *recreate,
+			Fix:       *fix,
+			SkipDrain: *skipDrain,
+			DryRun:    *dryRun,
+		})
+	case "export-release":
+		err = exportRelease(ctx, client, &out, *deployment, args, *dir)
+	case "delete-deployment":
+		err = deleteDeployment(ctx, client, &out, *deployment, *force)
+	default:
+		err = fmt.Errorf("Unknown command `%s'", os.Args[1])
+	}
+	if err != nil {
+		fail(out, err)
+	}
+
+	out.Lines = append(out.Lines, "Succeeded")
+	printOutput(out)
+}
+
+func deployments(ctx context.Context, client *director.Client, out *output) error {
+	deployments, err := client.Deployments(ctx)
+	if err != nil {
+		return err
+	}
+
+	t := table{Content: "deployments", Header: map[string]string{"name": "Name", "release_s": "Release(s)", "stemcell_s": "Stemcell(s)", "team_s": "Team(s)"}}
+	for _, deployment := range deployments {
+		t.Rows = append(t.Rows, map[string]string{
+			"name":       deployment.Name,
+			"release_s":  joinNameVersions(deployment.Releases),
+			"stemcell_s": joinNameVersions(deployment.Stemcells),
+			"team_s":     strings.Join(deployment.Teams, "\n"),
+		})
+	}
+	out.Tables = append(out.Tables, t)
+
+	return nil
+}
+
+func manifest(ctx context.Context, client *director.Client, out *output, deployment string) error {
+	content, err := client.Manifest(ctx, deployment)
+	if err != nil {
+		return err
+	}
+
+	out.Blocks = append(out.Blocks, string(content))
+	return nil
+}
+
+func cloudConfig(ctx context.Context, client *director.Client, out *output) error {
+	content, err := client.CloudConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if content == nil {
+		return errors.New("No cloud config")
+	}
+
+	out.Blocks = append(out.Blocks, string(content))
+	return nil
+}
+
+func stemcells(ctx context.Context, client *director.Client, out *output) error {
+	stemcells, err := client.Stemcells(ctx)
+	if err != nil {
+		return err
+	}
+
+	t := table{Content: "stemcells", Header: map[string]string{"name": "Name", "os": "OS", "version": "Version", "cid": "CID", "cpi": "CPI"}}
+	for _, stemcell := range stemcells {
+		version := stemcell.Version
+		if len(stemcell.Deployments) > 0 {
+			version += "*"
+		}
+
+		t.Rows = append(t.Rows, map[string]string{
+			"name":    stemcell.Name,
+			"os":      stemcell.OS,
+			"version": version,
+			"cid":     stemcell.CID,
+			"cpi":     "",
+		})
+	}
+	out.Tables = append(out.Tables, t)
+
+	return nil
+}
+
+func releases(ctx context.Context, client *director.Client, out *output) error {
+	releases, err := client.Releases(ctx)
+	if err != nil {
+		return err
+	}
+
+	t := table{Content: "releases", Header: map[string]string{"name": "Name", "version": "Version", "commit_hash": "Commit Hash"}}
+	for _, release := range releases {
+		for _, releaseVersion := range release.ReleaseVersions {
+			version := releaseVersion.Version
+			if releaseVersion.CurrentlyDeployed {
+				version += "*"
+			}
+
+			t.Rows = append(t.Rows, map[string]string{
+				"name":        release.Name,
+				"version":     version,
+				"commit_hash": releaseVersion.CommitHash,
+			})
+		}
+	}
+	out.Tables = append(out.Tables, t)
+
+	return nil
+}
+
+func tasks(ctx context.Context, client *director.Client, out *output, deployment string, recent int) error {
+	filter := director.TasksFilter{State: "processing,cancelling,queued", Deployment: deployment}
+	if recent > 0 {
+		filter = director.TasksFilter{Deployment: deployment, Limit: recent}
+	}
+
+	tasks, err := client.Tasks(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	t := table{Content: "tasks", Header: map[string]string{"id": "ID", "state": "State", "started_at": "Started At", "finished_at": "Finished At", "user": "User", "deployment": "Deployment", "description": "Description", "result": "Result"}}
+	for _, task := range tasks {
+		t.Rows = append(t.Rows, map[string]string{
+			"id":          strconv.Itoa(task.ID),
+			"state":       task.State,
+			"started_at":  time.Unix(task.StartedAt, 0).UTC().Format(time.UnixDate),
+			"finished_at": "-",
+			"user":        task.User,
+			"deployment":  task.Deployment,
+			"description": task.Description,
+			"result":      task.Result,
+		})
+	}
+	out.Tables = append(out.Tables, t)
+
+	return nil
+}
+
+func showTask(ctx context.Context, client *director.Client, out *output, args []string, event, result bool) error {
+	if len(args) != 1 {
+		return errors.New("Expected a task id")
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+
+	outputType := "debug"
+	switch {
+	case event:
+		outputType = "event"
+	case result:
+		outputType = "result"
+	}
+
+	content, err := client.TaskOutput(ctx, id, outputType)
+	if err != nil {
+		return err
+	}
+	out.Blocks = append(out.Blocks, string(content))
+
+	return nil
+}
+
+func cancelTask(ctx context.Context, client *director.Client, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Expected a task id")
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+
+	return client.CancelTask(ctx, id)
+}
+
+func deploy(ctx context.Context, client *director.Client, out *output, args []string, opts director.DeployOptions) error {
+	if len(args) != 1 {
+		return errors.New("Expected a manifest path")
+	}
+
+	manifest, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	id, err := client.Deploy(ctx, manifest, opts)
+	if err != nil {
+		return err
+	}
+
+	return waitForTask(ctx, client, out, id)
+}
+
+func exportRelease(ctx context.Context, client *director.Client, out *output, deployment string, args []string, dir string) error {
+	if len(args) != 2 {
+		return errors.New("Expected release and stemcell slugs")
+	}
+
+	release := strings.SplitN(args[0], "/", 2)
+	stemcell := strings.SplitN(args[1], "/", 2)
+	if len(release) != 2 || len(stemcell) != 2 {
+		return errors.New("Expected release and stemcell slugs in the form name/version")
+	}
+
+	id, err := client.ExportRelease(ctx, director.ExportReleaseRequest{
+		Deployment:      deployment,
+		Release:         release[0],
+		ReleaseVersion:  release[1],
+		StemcellOS:      stemcell[0],
+		StemcellVersion: stemcell[1],
+	})
+	if err != nil {
+		return err
+	}
+
+	err = waitForTask(ctx, client, out, id)
+	if err != nil {
+		return err
+	}
+
+	exported, err := client.ExportReleaseResult(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	tarballName := fmt.Sprintf("%s-%s-%s-%s-%s-%09d.tgz", release[0], release[1], stemcell[0], stemcell[1], now.Format("20060102-150405"), now.Nanosecond())
+
+	tarball, err := os.Create(filepath.Join(dir, tarballName))
+	if err != nil {
+		return err
+	}
+	defer tarball.Close() //nolint:errcheck
+
+	return client.DownloadResource(ctx, exported.BlobstoreID, tarball)
+}
+
+func deleteDeployment(ctx context.Context, client *director.Client, out *output, deployment string, force bool) error {
+	id, err := client.DeleteDeployment(ctx, deployment, force)
+	if err != nil {
+		return err
+	}
+
+	return waitForTask(ctx, client, out, id)
+}
+
+func waitForTask(ctx context.Context, client *director.Client, out *output, id int) error {
+	out.Blocks = append(out.Blocks, fmt.Sprintf("Task %d\n", id))
+
+	task, err := client.WaitForTask(ctx, id)
+
+	events, outputErr := client.TaskOutput(ctx, id, "event")
+	if outputErr == nil {
+		for _, line := range strings.Split(strings.TrimSpace(string(events)), "\n") {
+			var event struct {
+				Time  int64
+				Stage string
+				Task  string
+				State string
+			}
+			if json.Unmarshal([]byte(line), &event) != nil || event.State != "finished" {
+				continue
+			}
+			out.Blocks = append(out.Blocks, fmt.Sprintf("Task %d | %s | %s: %s\n", id, time.Unix(event.Time, 0).UTC().Format("15:04:05"), event.Stage, event.Task))
+		}
+	}
+
+	if err != nil {
+		out.Blocks = append(out.Blocks, fmt.Sprintf("Error: %s", task.Result), fmt.Sprintf("\nTask %d %s\n", id, task.State))
+		return err
+	}
+
+	out.Blocks = append(out.Blocks, fmt.Sprintf("\nTask %d Duration 00:00:00\nTask %d done\n", id, id))
+	return nil
+}
+
+func joinNameVersions(nameVersions []director.NameVersion) string {
+	var slugs []string
+	for _, nameVersion := range nameVersions {
+		slugs = append(slugs, nameVersion.Name+"/"+nameVersion.Version)
+	}
+	return strings.Join(slugs, "\n")
+}
+
+func printOutput(out output) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "    ")
+	encoder.Encode(out) //nolint:errcheck
+}
+
+func fail(out output, err error) {
+	if len(out.Blocks) == 0 {
+		out.Lines = append(out.Lines, err.Error())
+	}
+	out.Lines = append(out.Lines, "Exit code 1")
+	printOutput(out)
+	os.Exit(1)
+}
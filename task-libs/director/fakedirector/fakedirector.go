@@ -0,0 +1,315 @@
+// Package fakedirector is an in-memory BOSH Director for tests. It serves the
+// subset of the Director and UAA APIs used by task-libs/director, and the
+// fakebosh command wraps it for code that shells out to the bosh CLI.
+package fakedirector
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/pem"
+	"fmt"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/director"
+)
+
+const (
+	Client       = "fake-client"
+	ClientSecret = "fake-client-secret"
+	token        = "fake-token"
+)
+
+// Director holds the in-memory state of the fake.
+type Director struct {
+	mu sync.Mutex
+
+	server *httptest.Server
+
+	deployments map[string]*deployment
+	stemcells   []director.Stemcell
+	releases    map[string][]string
+	tasks       []*task
+	resources   map[string][]byte
+
+	cloudConfig []byte
+
+	failures map[string]*failure
+	hangs    []string
+}
+
+// failure ends matching tasks in state with message. When remaining is
+// positive, only that many more tasks fail.
+type failure struct {
+	state     string
+	message   string
+	remaining int
+}
+
+type deployment struct {
+	name      string
+	manifest  []byte
+	releases  []director.NameVersion
+	stemcells []director.NameVersion
+}
+
+type task struct {
+	director.Task
+
+	events []string
+	result string
+}
+
+// New creates an empty Director. Call Start to serve it.
+func New() *Director {
+	return &Director{
+		deployments: map[string]*deployment{},
+		releases:    map[string][]string{},
+		resources:   map[string][]byte{},
+		failures:    map[string]*failure{},
+	}
+}
+
+// Start serves the Director over TLS until Close is called.
+func (d *Director) Start() *Director {
+	d.server = httptest.NewUnstartedServer(d.handler())
+	d.server.StartTLS()
+	return d
+}
+
+func (d *Director) Close() {
+	d.server.Close()
+}
+
+func (d *Director) URL() string {
+	return d.server.URL
+}
+
+// CACert is the PEM encoded certificate of the TLS server.
+func (d *Director) CACert() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: d.server.Certificate().Raw}))
+}
+
+// Config returns a director.Config that targets the fake.
+func (d *Director) Config() director.Config {
+	return director.Config{
+		URL:          d.URL(),
+		Client:       Client,
+		ClientSecret: ClientSecret,
+		CACert:       d.CACert(),
+		PollInterval: time.Millisecond,
+	}
+}
+
+// Env returns the BOSH_* variables that point the bosh CLI, or fakebosh, at
+// the fake.
+func (d *Director) Env() []string {
+	return []string{
+		"BOSH_ENVIRONMENT=" + d.URL(),
+		"BOSH_CLIENT=" + Client,
+		"BOSH_CLIENT_SECRET=" + ClientSecret,
+		"BOSH_CA_CERT=" + d.CACert(),
+	}
+}
+
+// UploadStemcell adds a stemcell as if `bosh upload-stemcell` had run.
+func (d *Director) UploadStemcell(name, os, version string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stemcells = append(d.stemcells, director.Stemcell{
+		Name:    name,
+		OS:      os,
+		Version: version,
+		CID:     fmt.Sprintf("stemcell-cid-%d", len(d.stemcells)),
+	})
+}
+
+// UploadRelease adds a release version as if `bosh upload-release` had run.
+func (d *Director) UploadRelease(name, version string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.releases[name] = append(d.releases[name], version)
+}
+
+// UpdateCloudConfig sets the default cloud-config as if `bosh
+// update-cloud-config` had run.
+func (d *Director) UpdateCloudConfig(content string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.cloudConfig = []byte(content)
+}
+
+// FailTasks makes every task whose description contains match fail with the
+// given message.
+func (d *Director) FailTasks(match, message string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.failures[match] = &failure{state: "error", message: message}
+}
+
+// FailTasksTimes makes the next n tasks whose description contains match fail
+// with the given message. Tasks after those succeed.
+func (d *Director) FailTasksTimes(match, message string, n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.failures[match] = &failure{state: "error", message: message, remaining: n}
+}
+
+// TimeOutTasksTimes makes the next n tasks whose description contains match
+// end in the timeout state, like tasks the Director gave up on. Tasks after
+// those succeed.
+func (d *Director) TimeOutTasksTimes(match string, n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.failures[match] = &failure{state: "timeout", message: "Timed out", remaining: n}
+}
+
+// HangTasks makes every task whose description contains match keep
+// processing until it is cancelled, like a compilation that never finishes.
+func (d *Director) HangTasks(match string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.hangs = append(d.hangs, match)
+}
+
+// Deployments returns the names of the current deployments.
+func (d *Director) Deployments() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var names []string
+	for name := range d.deployments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Manifest returns the last manifest deployed for a deployment.
+func (d *Director) Manifest(name string) []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if dep, ok := d.deployments[name]; ok {
+		return dep.manifest
+	}
+
+	return nil
+}
+
+// Tasks returns every task the fake has run, oldest first.
+func (d *Director) Tasks() []director.Task {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var tasks []director.Task
+	for _, t := range d.tasks {
+		tasks = append(tasks, t.Task)
+	}
+
+	return tasks
+}
+
+func (d *Director) hasRelease(name, version string) bool {
+	for _, v := range d.releases[name] {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Director) findStemcell(os, version string) (director.Stemcell, bool) {
+	for _, stemcell := range d.stemcells {
+		if stemcell.OS == os && stemcell.Version == version {
+			return stemcell, true
+		}
+	}
+	return director.Stemcell{}, false
+}
+
+// runTask records a finished task, or a processing one when it hangs. work
+// returns the task result, or an error that fails the task.
+func (d *Director) runTask(description, deploymentName string, work func(t *task) (string, error)) int {
+	t := &task{Task: director.Task{
+		ID:          len(d.tasks) + 1,
+		State:       "processing",
+		Description: description,
+		Timestamp:   time.Now().Unix(),
+		StartedAt:   time.Now().Unix(),
+		User:        Client,
+		Deployment:  deploymentName,
+	}}
+	d.tasks = append(d.tasks, t)
+
+	for _, match := range d.hangs {
+		if strings.Contains(description, match) {
+			return t.ID
+		}
+	}
+
+	for match, f := range d.failures {
+		if strings.Contains(description, match) {
+			if f.remaining > 0 {
+				f.remaining--
+				if f.remaining == 0 {
+					delete(d.failures, match)
+				}
+			}
+			t.State = f.state
+			t.Result = f.message
+			return t.ID
+		}
+	}
+
+	result, err := work(t)
+	if err != nil {
+		t.State = "error"
+		t.Result = err.Error()
+		return t.ID
+	}
+
+	t.State = "done"
+	t.Result = "/" + description
+	t.result = result
+
+	return t.ID
+}
+
+func compiledReleaseTarball(name, version, os, stemcellVersion string) ([]byte, string, error) {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+
+	releaseMF := fmt.Sprintf("name: %s\nversion: %q\ncompiled_packages:\n- name: %s-package\n  stemcell: %s/%s\n", name, version, name, os, stemcellVersion)
+	err := tw.WriteHeader(&tar.Header{Name: "./release.MF", Mode: 0644, Size: int64(len(releaseMF))})
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = tw.Write([]byte(releaseMF))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, "", err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), fmt.Sprintf("%x", sha1.Sum(buf.Bytes())), nil
+}
@@ -0,0 +1,18 @@
+package fakedirector_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+func TestFakeDirector(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "FakeDirector Suite")
+}
+
+var _ = AfterSuite(func() {
+	gexec.CleanupBuildArtifacts()
+})
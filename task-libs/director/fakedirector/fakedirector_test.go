@@ -0,0 +1,240 @@
+package fakedirector_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli"
+	"github.com/cloudfoundry/runtime-ci/task-libs/director"
+	"github.com/cloudfoundry/runtime-ci/task-libs/director/fakedirector"
+)
+
+const manifest = `---
+name: release-a-compilation
+releases:
+- name: release-a
+  version: "1.0"
+stemcells:
+- alias: default
+  os: ubuntu-jammy
+  version: "1.2"
+`
+
+var _ = Describe("Director", func() {
+	var (
+		fake   *fakedirector.Director
+		client *director.Client
+	)
+
+	BeforeEach(func() {
+		fake = fakedirector.New().Start()
+		fake.UploadStemcell("bosh-warden-boshlite-ubuntu-jammy-go_agent", "ubuntu-jammy", "1.2")
+		fake.UploadRelease("release-a", "1.0")
+
+		var err error
+		client, err = director.NewClient(context.Background(), fake.Config())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		fake.Close()
+	})
+
+	It("deploys, exports and deletes through the Director API", func() {
+		taskID, err := client.Deploy(context.Background(), []byte(manifest), director.DeployOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.WaitForTask(context.Background(), taskID)
+		Expect(err).NotTo(HaveOccurred())
+
+		deployments, err := client.Deployments(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deployments).To(HaveLen(1))
+		Expect(deployments[0].Stemcells).To(Equal([]director.NameVersion{{Name: "bosh-warden-boshlite-ubuntu-jammy-go_agent", Version: "1.2"}}))
+
+		taskID, err = client.ExportRelease(context.Background(), director.ExportReleaseRequest{
+			Deployment:      "release-a-compilation",
+			Release:         "release-a",
+			ReleaseVersion:  "1.0",
+			StemcellOS:      "ubuntu-jammy",
+			StemcellVersion: "1.2",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.WaitForTask(context.Background(), taskID)
+		Expect(err).NotTo(HaveOccurred())
+
+		exported, err := client.ExportReleaseResult(context.Background(), taskID)
+		Expect(err).NotTo(HaveOccurred())
+		tarball := new(bytes.Buffer)
+		Expect(client.DownloadResource(context.Background(), exported.BlobstoreID, tarball)).To(Succeed())
+		Expect(tarball.Len()).To(BeNumerically(">", 0))
+
+		taskID, err = client.DeleteDeployment(context.Background(), "release-a-compilation", false)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.WaitForTask(context.Background(), taskID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fake.Deployments()).To(BeEmpty())
+	})
+
+	It("fails deploys that reference missing releases", func() {
+		taskID, err := client.Deploy(context.Background(), []byte("name: broken\nreleases:\n- name: missing\n  version: \"1\"\n"), director.DeployOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = client.WaitForTask(context.Background(), taskID)
+		Expect(err).To(MatchError(ContainSubstring("Release 'missing/1' doesn't exist")))
+	})
+
+	It("fails tasks on request", func() {
+		fake.FailTasks("export release", "compilation failed")
+		_, err := client.Deploy(context.Background(), []byte(manifest), director.DeployOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		taskID, err := client.ExportRelease(context.Background(), director.ExportReleaseRequest{Deployment: "release-a-compilation", Release: "release-a", ReleaseVersion: "1.0", StemcellOS: "ubuntu-jammy", StemcellVersion: "1.2"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = client.WaitForTask(context.Background(), taskID)
+		Expect(err).To(MatchError(ContainSubstring("compilation failed")))
+	})
+
+	It("fails a limited number of tasks on request", func() {
+		fake.FailTasksTimes("export release", "Failed to acquire lock", 1)
+		_, err := client.Deploy(context.Background(), []byte(manifest), director.DeployOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		request := director.ExportReleaseRequest{Deployment: "release-a-compilation", Release: "release-a", ReleaseVersion: "1.0", StemcellOS: "ubuntu-jammy", StemcellVersion: "1.2"}
+
+		taskID, err := client.ExportRelease(context.Background(), request)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.WaitForTask(context.Background(), taskID)
+		Expect(err).To(MatchError(ContainSubstring("Failed to acquire lock")))
+
+		taskID, err = client.ExportRelease(context.Background(), request)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.WaitForTask(context.Background(), taskID)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("hangs tasks until they are cancelled", func() {
+		fake.HangTasks("export release")
+		_, err := client.Deploy(context.Background(), []byte(manifest), director.DeployOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		taskID, err := client.ExportRelease(context.Background(), director.ExportReleaseRequest{Deployment: "release-a-compilation", Release: "release-a", ReleaseVersion: "1.0", StemcellOS: "ubuntu-jammy", StemcellVersion: "1.2"})
+		Expect(err).NotTo(HaveOccurred())
+
+		task, err := client.Task(context.Background(), taskID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(task.State).To(Equal("processing"))
+
+		Expect(client.CancelTask(context.Background(), taskID)).To(Succeed())
+		_, err = client.WaitForTask(context.Background(), taskID)
+		Expect(err).To(MatchError(ContainSubstring(`finished with state "cancelled"`)))
+	})
+
+	Describe("fakebosh", func() {
+		var (
+			binDir string
+			cli    boshcli.CLI
+		)
+
+		BeforeEach(func() {
+			var err error
+			binDir, err = os.MkdirTemp("", "fakebosh-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakedirector.InstallFakeBOSH(binDir)).To(Succeed())
+
+			cli = boshcli.CLI{
+				Path: filepath.Join(binDir, "bosh"),
+				Env: boshcli.Environment{
+					Address:      fake.URL(),
+					Client:       fakedirector.Client,
+					ClientSecret: fakedirector.ClientSecret,
+					CACert:       fake.CACert(),
+				},
+			}
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(binDir)).To(Succeed())
+		})
+
+		It("serves the typed bosh CLI client", func() {
+			manifestPath := filepath.Join(binDir, "manifest.yml")
+			Expect(os.WriteFile(manifestPath, []byte(manifest), 0644)).To(Succeed())
+
+			client := boshcli.NewClient(cli)
+			_, err := client.Deploy("release-a-compilation", manifestPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			deployed, err := client.Manifest("release-a-compilation")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(deployed)).To(Equal(manifest))
+
+			deployments, err := client.Deployments()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deployments).To(Equal([]boshcli.Deployment{{
+				Name:      "release-a-compilation",
+				Releases:  []string{"release-a/1.0"},
+				Stemcells: []string{"bosh-warden-boshlite-ubuntu-jammy-go_agent/1.2"},
+			}}))
+
+			stemcells, err := client.Stemcells()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stemcells).To(ConsistOf(boshcli.Stemcell{
+				Name:     "bosh-warden-boshlite-ubuntu-jammy-go_agent",
+				OS:       "ubuntu-jammy",
+				Version:  "1.2",
+				CID:      "stemcell-cid-0",
+				Deployed: true,
+			}))
+
+			_, err = client.ExportRelease("release-a-compilation", "release-a/1.0", "ubuntu-jammy/1.2", "--dir", binDir)
+			Expect(err).NotTo(HaveOccurred())
+			tarballs, err := filepath.Glob(filepath.Join(binDir, "release-a-1.0-ubuntu-jammy-1.2-*.tgz"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tarballs).To(HaveLen(1))
+
+			Expect(client.DeleteDeployment("release-a-compilation", false)).To(Succeed())
+			Expect(fake.Deployments()).To(BeEmpty())
+		})
+
+		It("stops bosh and cancels the Director task when the context is done", func() {
+			fake.HangTasks("export release")
+			manifestPath := filepath.Join(binDir, "manifest.yml")
+			Expect(os.WriteFile(manifestPath, []byte(manifest), 0644)).To(Succeed())
+
+			client := boshcli.NewClient(cli)
+			_, err := client.Deploy("release-a-compilation", manifestPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			ctx, cancel := context.WithTimeoutCause(context.Background(), time.Second, errors.New("timed out"))
+			defer cancel()
+
+			_, err = client.WithContext(ctx).ExportRelease("release-a-compilation", "release-a/1.0", "ubuntu-jammy/1.2", "--dir", binDir)
+			Expect(err).To(MatchError(MatchRegexp(`^bosh export-release: timed out \(cancelled Director task \d+\)$`)))
+
+			tasks := fake.Tasks()
+			Expect(tasks[len(tasks)-1].Description).To(HavePrefix("export release"))
+			Expect(tasks[len(tasks)-1].State).To(Equal("cancelled"))
+		})
+
+		It("serves the cloud-config", func() {
+			fake.UpdateCloudConfig("compilation:\n  workers: 3\n")
+
+			config, err := boshcli.NewClient(cli).CloudConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(config)).To(Equal("compilation:\n  workers: 3\n"))
+		})
+
+		It("reports Director errors like the bosh CLI", func() {
+			Expect(boshcli.NewClient(cli).DeleteDeployment("missing", false)).
+				To(MatchError("Error: Deployment 'missing' doesn't exist"))
+		})
+	})
+})
@@ -0,0 +1,425 @@
+package fakedirector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/director"
+)
+
+func (d *Director) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /info", d.info)
+	mux.HandleFunc("POST /uaa/oauth/token", d.oauthToken)
+
+	mux.HandleFunc("GET /deployments", d.authorized(d.listDeployments))
+	mux.HandleFunc("POST /deployments", d.authorized(d.deploy))
+	mux.HandleFunc("GET /deployments/{name}", d.authorized(d.getDeployment))
+	mux.HandleFunc("DELETE /deployments/{name}", d.authorized(d.deleteDeployment))
+	mux.HandleFunc("GET /configs", d.authorized(d.listConfigs))
+	mux.HandleFunc("GET /stemcells", d.authorized(d.listStemcells))
+	mux.HandleFunc("GET /releases", d.authorized(d.listReleases))
+	mux.HandleFunc("POST /releases/export", d.authorized(d.exportRelease))
+	mux.HandleFunc("GET /resources/{id}", d.authorized(d.resource))
+	mux.HandleFunc("GET /tasks", d.authorized(d.listTasks))
+	mux.HandleFunc("GET /tasks/{id}", d.authorized(d.getTask))
+	mux.HandleFunc("GET /tasks/{id}/output", d.authorized(d.taskOutput))
+	mux.HandleFunc("DELETE /task/{id}", d.authorized(d.cancelTask))
+
+	return mux
+}
+
+func (d *Director) info(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"name":    "fake-director",
+		"uuid":    "fake-director-uuid",
+		"version": "0.0.0 (fake)",
+		"user_authentication": map[string]interface{}{
+			"type":    "uaa",
+			"options": map[string]string{"url": d.URL() + "/uaa"},
+		},
+	})
+}
+
+func (d *Director) oauthToken(w http.ResponseWriter, r *http.Request) {
+	client, secret, ok := r.BasicAuth()
+	if !ok || client != Client || secret != ClientSecret || r.FormValue("grant_type") != "client_credentials" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"access_token": token,
+		"token_type":   "bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (d *Director) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			writeError(w, http.StatusUnauthorized, 600000, "Not authorized")
+			return
+		}
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		next(w, r)
+	}
+}
+
+func (d *Director) listDeployments(w http.ResponseWriter, r *http.Request) {
+	deployments := []director.Deployment{}
+	for _, name := range sortedKeys(d.deployments) {
+		dep := d.deployments[name]
+		deployments = append(deployments, director.Deployment{
+			Name:      dep.name,
+			Releases:  dep.releases,
+			Stemcells: dep.stemcells,
+			Teams:     []string{},
+		})
+	}
+
+	writeJSON(w, deployments)
+}
+
+func (d *Director) deploy(w http.ResponseWriter, r *http.Request) {
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, 440001, err.Error())
+		return
+	}
+
+	var manifest struct {
+		Name     string
+		Releases []struct {
+			Name    string
+			Version string
+		}
+		Stemcells []struct {
+			Alias   string
+			OS      string
+			Version string
+		}
+	}
+	err = yaml.Unmarshal(content, &manifest)
+	if err != nil || manifest.Name == "" {
+		writeError(w, http.StatusBadRequest, 440001, "Manifest should specify a deployment name")
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	id := d.runTask("create deployment", manifest.Name, func(t *task) (string, error) {
+		dep := &deployment{name: manifest.Name, manifest: content}
+
+		for _, release := range manifest.Releases {
+			if !d.hasRelease(release.Name, release.Version) {
+				return "", fmt.Errorf("Release '%s/%s' doesn't exist", release.Name, release.Version)
+			}
+			dep.releases = append(dep.releases, director.NameVersion{Name: release.Name, Version: release.Version})
+		}
+
+		for _, manifestStemcell := range manifest.Stemcells {
+			stemcell, ok := d.findStemcell(manifestStemcell.OS, manifestStemcell.Version)
+			if !ok {
+				return "", fmt.Errorf("Stemcell '%s/%s' doesn't exist", manifestStemcell.OS, manifestStemcell.Version)
+			}
+			dep.stemcells = append(dep.stemcells, director.NameVersion{Name: stemcell.Name, Version: stemcell.Version})
+		}
+
+		t.addEvent("Preparing deployment", "Preparing deployment", 1, 1)
+		for i, release := range dep.releases {
+			t.addEvent("Compiling packages", fmt.Sprintf("%s-package/%s", release.Name, release.Version), i+1, len(dep.releases))
+		}
+
+		if !dryRun {
+			d.deployments[dep.name] = dep
+		}
+
+		return "", nil
+	})
+
+	redirectToTask(w, id)
+}
+
+func (d *Director) getDeployment(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	dep, ok := d.deployments[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, 70000, fmt.Sprintf("Deployment '%s' doesn't exist", name))
+		return
+	}
+
+	writeJSON(w, map[string]string{"manifest": string(dep.manifest)})
+}
+
+func (d *Director) deleteDeployment(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	id := d.runTask("delete deployment "+name, name, func(t *task) (string, error) {
+		if _, ok := d.deployments[name]; !ok && r.URL.Query().Get("force") != "true" {
+			return "", fmt.Errorf("Deployment '%s' doesn't exist", name)
+		}
+
+		t.addEvent("Deleting instances", name, 1, 1)
+		delete(d.deployments, name)
+		return "", nil
+	})
+
+	redirectToTask(w, id)
+}
+
+func (d *Director) listConfigs(w http.ResponseWriter, r *http.Request) {
+	configs := []map[string]string{}
+	if r.URL.Query().Get("type") == "cloud" && d.cloudConfig != nil {
+		configs = append(configs, map[string]string{"id": "1", "type": "cloud", "name": "default", "content": string(d.cloudConfig)})
+	}
+
+	writeJSON(w, configs)
+}
+
+func (d *Director) listStemcells(w http.ResponseWriter, r *http.Request) {
+	type stemcellDeployment struct {
+		Name string `json:"name"`
+	}
+
+	stemcells := []map[string]interface{}{}
+	for _, stemcell := range d.stemcells {
+		deployments := []stemcellDeployment{}
+		for _, name := range sortedKeys(d.deployments) {
+			for _, used := range d.deployments[name].stemcells {
+				if used.Name == stemcell.Name && used.Version == stemcell.Version {
+					deployments = append(deployments, stemcellDeployment{Name: name})
+				}
+			}
+		}
+
+		stemcells = append(stemcells, map[string]interface{}{
+			"name":             stemcell.Name,
+			"operating_system": stemcell.OS,
+			"version":          stemcell.Version,
+			"cid":              stemcell.CID,
+			"deployments":      deployments,
+		})
+	}
+
+	writeJSON(w, stemcells)
+}
+
+func (d *Director) listReleases(w http.ResponseWriter, r *http.Request) {
+	releases := []director.Release{}
+	for _, name := range sortedKeys(d.releases) {
+		release := director.Release{Name: name}
+		for _, version := range d.releases[name] {
+			release.ReleaseVersions = append(release.ReleaseVersions, director.ReleaseVersion{
+				Version:           version,
+				CommitHash:        "abcdef12",
+				CurrentlyDeployed: d.releaseDeployed(name, version),
+			})
+		}
+		releases = append(releases, release)
+	}
+
+	writeJSON(w, releases)
+}
+
+func (d *Director) releaseDeployed(name, version string) bool {
+	for _, dep := range d.deployments {
+		for _, release := range dep.releases {
+			if release.Name == name && release.Version == version {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *Director) exportRelease(w http.ResponseWriter, r *http.Request) {
+	var request director.ExportReleaseRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, 440001, err.Error())
+		return
+	}
+
+	description := fmt.Sprintf("export release: %s/%s for %s/%s", request.Release, request.ReleaseVersion, request.StemcellOS, request.StemcellVersion)
+
+	id := d.runTask(description, request.Deployment, func(t *task) (string, error) {
+		dep, ok := d.deployments[request.Deployment]
+		if !ok {
+			return "", fmt.Errorf("Deployment '%s' doesn't exist", request.Deployment)
+		}
+
+		found := false
+		for _, release := range dep.releases {
+			if release.Name == request.Release && release.Version == request.ReleaseVersion {
+				found = true
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("Release '%s/%s' is not used by deployment '%s'", request.Release, request.ReleaseVersion, request.Deployment)
+		}
+
+		if _, ok := d.findStemcell(request.StemcellOS, request.StemcellVersion); !ok {
+			return "", fmt.Errorf("Stemcell version '%s' for OS '%s' doesn't exist", request.StemcellVersion, request.StemcellOS)
+		}
+
+		tarball, sha1, err := compiledReleaseTarball(request.Release, request.ReleaseVersion, request.StemcellOS, request.StemcellVersion)
+		if err != nil {
+			return "", err
+		}
+
+		blobstoreID := fmt.Sprintf("blob-%d", t.ID)
+		d.resources[blobstoreID] = tarball
+
+		t.addEvent("Compiling packages", fmt.Sprintf("%s-package/%s", request.Release, request.ReleaseVersion), 1, 1)
+		t.addEvent("Creating compiled release tarball", fmt.Sprintf("%s/%s", request.Release, request.ReleaseVersion), 1, 1)
+
+		result, err := json.Marshal(director.ExportedRelease{BlobstoreID: blobstoreID, SHA1: sha1})
+		return string(result), err
+	})
+
+	redirectToTask(w, id)
+}
+
+func (d *Director) resource(w http.ResponseWriter, r *http.Request) {
+	content, ok := d.resources[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, 100001, "Resource not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-compressed")
+	w.Write(content) //nolint:errcheck
+}
+
+func (d *Director) listTasks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	tasks := []director.Task{}
+	for i := len(d.tasks) - 1; i >= 0; i-- {
+		t := d.tasks[i]
+		if state := query.Get("state"); state != "" && !strings.Contains(state, t.State) {
+			continue
+		}
+		if dep := query.Get("deployment"); dep != "" && dep != t.Deployment {
+			continue
+		}
+
+		tasks = append(tasks, t.Task)
+		if limit > 0 && len(tasks) == limit {
+			break
+		}
+	}
+
+	writeJSON(w, tasks)
+}
+
+func (d *Director) findTask(w http.ResponseWriter, r *http.Request) (*task, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 || id > len(d.tasks) {
+		writeError(w, http.StatusNotFound, 10001, fmt.Sprintf("Task %s doesn't exist", r.PathValue("id")))
+		return nil, false
+	}
+
+	return d.tasks[id-1], true
+}
+
+func (d *Director) getTask(w http.ResponseWriter, r *http.Request) {
+	t, ok := d.findTask(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, t.Task)
+}
+
+func (d *Director) taskOutput(w http.ResponseWriter, r *http.Request) {
+	t, ok := d.findTask(w, r)
+	if !ok {
+		return
+	}
+
+	var output bytes.Buffer
+	switch r.URL.Query().Get("type") {
+	case "event":
+		for _, event := range t.events {
+			fmt.Fprintln(&output, event)
+		}
+	case "result":
+		fmt.Fprintln(&output, t.result)
+	default:
+		fmt.Fprintf(&output, "I, [%s] INFO -- DirectorJobRunner: %s\n", time.Unix(t.StartedAt, 0).Format(time.RFC3339), t.Description)
+	}
+
+	// Like the Director, answer Range requests with only the requested bytes.
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(output.Bytes()))
+}
+
+func (d *Director) cancelTask(w http.ResponseWriter, r *http.Request) {
+	t, ok := d.findTask(w, r)
+	if !ok {
+		return
+	}
+
+	if !t.Finished() {
+		t.State = "cancelled"
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (t *task) addEvent(stage, name string, index, total int) {
+	for _, state := range []string{"started", "finished"} {
+		event, _ := json.Marshal(map[string]interface{}{
+			"time":     time.Now().Unix(),
+			"stage":    stage,
+			"tags":     []string{},
+			"total":    total,
+			"task":     name,
+			"index":    index,
+			"state":    state,
+			"progress": map[string]int{"started": 0, "finished": 100}[state],
+		})
+		t.events = append(t.events, string(event))
+	}
+}
+
+func redirectToTask(w http.ResponseWriter, id int) {
+	w.Header().Set("Location", fmt.Sprintf("/tasks/%d", id))
+	w.WriteHeader(http.StatusFound)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v) //nolint:errcheck
+}
+
+func writeError(w http.ResponseWriter, status, code int, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "description": description}) //nolint:errcheck
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
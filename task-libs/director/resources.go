@@ -0,0 +1,208 @@
+package director
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+type NameVersion struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type Deployment struct {
+	Name      string        `json:"name"`
+	Releases  []NameVersion `json:"releases"`
+	Stemcells []NameVersion `json:"stemcells"`
+	Teams     []string      `json:"teams"`
+}
+
+type Stemcell struct {
+	Name        string `json:"name"`
+	OS          string `json:"operating_system"`
+	Version     string `json:"version"`
+	CID         string `json:"cid"`
+	Deployments []struct {
+		Name string `json:"name"`
+	} `json:"deployments"`
+}
+
+type Release struct {
+	Name            string           `json:"name"`
+	ReleaseVersions []ReleaseVersion `json:"release_versions"`
+}
+
+type ReleaseVersion struct {
+	Version            string `json:"version"`
+	CommitHash         string `json:"commit_hash"`
+	UncommittedChanges bool   `json:"uncommitted_changes"`
+	CurrentlyDeployed  bool   `json:"currently_deployed"`
+}
+
+func (c *Client) Deployments(ctx context.Context) ([]Deployment, error) {
+	var deployments []Deployment
+	err := c.getJSON(ctx, "/deployments", &deployments)
+	return deployments, err
+}
+
+func (c *Client) Stemcells(ctx context.Context) ([]Stemcell, error) {
+	var stemcells []Stemcell
+	err := c.getJSON(ctx, "/stemcells", &stemcells)
+	return stemcells, err
+}
+
+func (c *Client) Releases(ctx context.Context) ([]Release, error) {
+	var releases []Release
+	err := c.getJSON(ctx, "/releases", &releases)
+	return releases, err
+}
+
+// Manifest returns the current manifest of a deployment.
+func (c *Client) Manifest(ctx context.Context, name string) ([]byte, error) {
+	var deployment struct {
+		Manifest string `json:"manifest"`
+	}
+	err := c.getJSON(ctx, "/deployments/"+url.PathEscape(name), &deployment)
+	return []byte(deployment.Manifest), err
+}
+
+// CloudConfig returns the latest default cloud-config, or nothing when none
+// has been uploaded.
+func (c *Client) CloudConfig(ctx context.Context) ([]byte, error) {
+	var configs []struct {
+		Content string `json:"content"`
+	}
+	err := c.getJSON(ctx, "/configs?type=cloud&name=default&latest=true", &configs)
+	if err != nil || len(configs) == 0 {
+		return nil, err
+	}
+
+	return []byte(configs[0].Content), nil
+}
+
+// DeployOptions are the query flags accepted by POST /deployments.
+type DeployOptions struct {
+	Recreate  bool
+	Fix       bool
+	SkipDrain bool
+	DryRun    bool
+}
+
+// Deploy starts a deploy of the manifest and returns the Director task id.
+func (c *Client) Deploy(ctx context.Context, manifest []byte, opts DeployOptions) (int, error) {
+	query := url.Values{}
+	if opts.Recreate {
+		query.Set("recreate", "true")
+	}
+	if opts.Fix {
+		query.Set("fix", "true")
+	}
+	if opts.SkipDrain {
+		query.Set("skip_drain", "*")
+	}
+	if opts.DryRun {
+		query.Set("dry_run", "true")
+	}
+
+	path := "/deployments"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, path, bytes.NewReader(manifest))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "text/yaml")
+
+	return c.startTask(req)
+}
+
+// DeleteDeployment starts deleting a deployment and returns the Director
+// task id.
+func (c *Client) DeleteDeployment(ctx context.Context, name string, force bool) (int, error) {
+	path := "/deployments/" + url.PathEscape(name)
+	if force {
+		path += "?force=true"
+	}
+
+	req, err := c.newRequest(ctx, http.MethodDelete, path, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.startTask(req)
+}
+
+// ExportReleaseRequest identifies a compiled release to export.
+type ExportReleaseRequest struct {
+	Deployment      string `json:"deployment_name"`
+	Release         string `json:"release_name"`
+	ReleaseVersion  string `json:"release_version"`
+	StemcellOS      string `json:"stemcell_os"`
+	StemcellVersion string `json:"stemcell_version"`
+}
+
+// ExportedRelease is the result of a finished export-release task.
+type ExportedRelease struct {
+	BlobstoreID string `json:"blobstore_id"`
+	SHA1        string `json:"sha1"`
+}
+
+// ExportRelease starts compiling and exporting a release and returns the
+// Director task id.
+func (c *Client) ExportRelease(ctx context.Context, request ExportReleaseRequest) (int, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/releases/export", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.startTask(req)
+}
+
+// ExportReleaseResult reads the blobstore id of a finished export-release
+// task.
+func (c *Client) ExportReleaseResult(ctx context.Context, taskID int) (ExportedRelease, error) {
+	var exported ExportedRelease
+
+	output, err := c.TaskOutput(ctx, taskID, "result")
+	if err != nil {
+		return exported, err
+	}
+
+	err = json.Unmarshal(bytes.TrimSpace(output), &exported)
+	if err != nil {
+		return exported, fmt.Errorf("failed to parse export-release result: %w", err)
+	}
+
+	return exported, nil
+}
+
+// DownloadResource streams a blobstore resource, such as an exported release
+// tarball, to w.
+func (c *Client) DownloadResource(ctx context.Context, blobstoreID string, w io.Writer) error {
+	req, err := c.newRequest(ctx, http.MethodGet, "/resources/"+url.PathEscape(blobstoreID), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
@@ -0,0 +1,179 @@
+package director
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/taskevents"
+)
+
+type Task struct {
+	ID          int    `json:"id"`
+	State       string `json:"state"`
+	Description string `json:"description"`
+	Timestamp   int64  `json:"timestamp"`
+	StartedAt   int64  `json:"started_at"`
+	Result      string `json:"result"`
+	User        string `json:"user"`
+	Deployment  string `json:"deployment"`
+}
+
+// Finished reports whether the task has reached a final state.
+func (t Task) Finished() bool {
+	switch t.State {
+	case "done", "error", "cancelled", "timeout":
+		return true
+	default:
+		return false
+	}
+}
+
+// TasksFilter narrows the tasks returned by Tasks.
+type TasksFilter struct {
+	State      string
+	Deployment string
+	Limit      int
+	Verbose    int
+}
+
+func (c *Client) Tasks(ctx context.Context, filter TasksFilter) ([]Task, error) {
+	query := url.Values{}
+	if filter.State != "" {
+		query.Set("state", filter.State)
+	}
+	if filter.Deployment != "" {
+		query.Set("deployment", filter.Deployment)
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Verbose > 0 {
+		query.Set("verbose", strconv.Itoa(filter.Verbose))
+	}
+
+	path := "/tasks"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var tasks []Task
+	err := c.getJSON(ctx, path, &tasks)
+	return tasks, err
+}
+
+func (c *Client) Task(ctx context.Context, id int) (Task, error) {
+	var task Task
+	err := c.getJSON(ctx, fmt.Sprintf("/tasks/%d", id), &task)
+	return task, err
+}
+
+// TaskOutput downloads the event, result, debug or cpi output of a task.
+func (c *Client) TaskOutput(ctx context.Context, id int, outputType string) ([]byte, error) {
+	return c.taskOutput(ctx, id, outputType, 0)
+}
+
+// taskOutput downloads the output of a task from offset bytes on. The
+// Director answers the Range request with only the new bytes, or with 416
+// when there are none yet.
+func (c *Client) taskOutput(ctx context.Context, id int, outputType string, offset int) ([]byte, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, fmt.Sprintf("/tasks/%d/output?type=%s", id, url.QueryEscape(outputType)), nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := c.do(req, http.StatusOK, http.StatusNoContent, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return nil, nil
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// A server that ignores the Range header sends the whole output again.
+	if resp.StatusCode == http.StatusOK {
+		content = content[min(offset, len(content)):]
+	}
+
+	return content, nil
+}
+
+// WaitForTask polls a task until it finishes and returns an error unless it
+// finished successfully.
+func (c *Client) WaitForTask(ctx context.Context, id int) (Task, error) {
+	return c.FollowTask(ctx, id, nil)
+}
+
+// FollowTask polls a task until it finishes, passing every new event to
+// onEvent as it arrives. It returns an error unless the task finished
+// successfully, or the context's error once ctx is done.
+func (c *Client) FollowTask(ctx context.Context, id int, onEvent func(taskevents.Event)) (Task, error) {
+	offset := 0
+	for {
+		task, err := c.Task(ctx, id)
+		if err != nil {
+			return task, err
+		}
+
+		if onEvent != nil {
+			output, err := c.taskOutput(ctx, id, "event", offset)
+			if err != nil {
+				return task, err
+			}
+
+			// Only complete lines are parsed while the task runs; a partially
+			// written event is downloaded again on the next poll.
+			complete := len(output)
+			if !task.Finished() {
+				complete = bytes.LastIndexByte(output, '\n') + 1
+			}
+			for _, event := range taskevents.Parse(string(output[:complete])) {
+				onEvent(event)
+			}
+			offset += complete
+		}
+
+		if task.Finished() {
+			if task.State != "done" {
+				return task, fmt.Errorf("task %d finished with state %q: %s", id, task.State, task.Result)
+			}
+			return task, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return task, ctx.Err()
+		case <-time.After(c.pollInterval):
+		}
+	}
+}
+
+// CancelTask asks the Director to cancel a running task.
+func (c *Client) CancelTask(ctx context.Context, id int) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, fmt.Sprintf("/task/%d", id), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req, http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
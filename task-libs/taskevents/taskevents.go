@@ -0,0 +1,178 @@
+// Package taskevents parses BOSH Director task events and reports their
+// progress as they arrive.
+package taskevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Event is a line of a Director task's event output.
+type Event struct {
+	Time     int64
+	Stage    string
+	Tags     []string
+	Total    int
+	Task     string
+	Index    int
+	State    string
+	Progress int
+	Error    *struct {
+		Code    int
+		Message string
+	} `json:",omitempty"`
+}
+
+func (e Event) time() time.Time {
+	return time.Unix(e.Time, 0).UTC()
+}
+
+// Parse parses the event output of a task, one JSON event per line. Lines
+// that are not events are skipped.
+func Parse(output string) []Event {
+	var events []Event
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		var event Event
+		if json.Unmarshal([]byte(line), &event) != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
+
+type stageTiming struct {
+	name     string
+	tasks    int
+	failed   int
+	start    time.Time
+	finish   time.Time
+	finished bool
+}
+
+// Reporter renders one line per started, finished or failed event and keeps
+// per-stage timings for Summary. It is safe for concurrent use.
+type Reporter struct {
+	out    io.Writer
+	prefix string
+
+	mu      sync.Mutex
+	started map[string]time.Time
+	stages  []*stageTiming
+}
+
+// NewReporter creates a Reporter that writes to out, prefixing every line
+// with prefix when it is not empty.
+func NewReporter(out io.Writer, prefix string) *Reporter {
+	if prefix != "" {
+		prefix = "[" + prefix + "] "
+	}
+
+	return &Reporter{out: out, prefix: prefix, started: map[string]time.Time{}}
+}
+
+// Handle reports a single event.
+func (r *Reporter) Handle(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event.Stage == "" && event.Error != nil {
+		fmt.Fprintf(r.out, "%sError: %s\n", r.prefix, event.Error.Message)
+		return
+	}
+
+	stage := r.stage(event.Stage)
+	key := event.Stage + "\x00" + event.Task
+	at := event.time()
+
+	switch event.State {
+	case "started":
+		r.started[key] = at
+		if stage.start.IsZero() || at.Before(stage.start) {
+			stage.start = at
+		}
+		stage.tasks++
+		fmt.Fprintf(r.out, "%s%s | %s (%d/%d): %s\n", r.prefix, at.Format("15:04:05"), event.Stage, event.Index, event.Total, event.Task)
+	case "finished", "failed":
+		if at.After(stage.finish) {
+			stage.finish = at
+		}
+		stage.finished = true
+
+		duration := at.Sub(r.started[key])
+		if event.State == "failed" {
+			stage.failed++
+			message := ""
+			if event.Error != nil {
+				message = ": " + event.Error.Message
+			}
+			fmt.Fprintf(r.out, "%s%s | %s: %s failed after %s%s\n", r.prefix, at.Format("15:04:05"), event.Stage, event.Task, formatDuration(duration), message)
+			return
+		}
+		fmt.Fprintf(r.out, "%s%s | %s: %s done (%s)\n", r.prefix, at.Format("15:04:05"), event.Stage, event.Task, formatDuration(duration))
+	}
+}
+
+func (r *Reporter) stage(name string) *stageTiming {
+	for _, stage := range r.stages {
+		if stage.name == name {
+			return stage
+		}
+	}
+
+	stage := &stageTiming{name: name}
+	r.stages = append(r.stages, stage)
+	return stage
+}
+
+// Summary writes the wall-clock duration and task count of every stage in
+// the order the stages started.
+func (r *Reporter) Summary() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.stages) == 0 {
+		return
+	}
+
+	fmt.Fprintf(r.out, "%sStage timings:\n", r.prefix)
+
+	w := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+	for _, stage := range r.stages {
+		duration := "-"
+		if stage.finished {
+			duration = formatDuration(stage.finish.Sub(stage.start))
+		}
+
+		tasks := fmt.Sprintf("%d tasks", stage.tasks)
+		if stage.tasks == 1 {
+			tasks = "1 task"
+		}
+		if stage.failed > 0 {
+			tasks += fmt.Sprintf(", %d failed", stage.failed)
+		}
+
+		fmt.Fprintf(w, "%s  %s\t%s\t%s\n", r.prefix, stage.name, tasks, duration)
+	}
+	w.Flush() //nolint:errcheck
+}
+
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	d = d.Round(time.Second)
+
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
@@ -0,0 +1,13 @@
+package taskevents_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestTaskEvents(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TaskEvents Suite")
+}
@@ -0,0 +1,62 @@
+package taskevents_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/taskevents"
+)
+
+const eventOutput = `{"time":1700000000,"stage":"Preparing deployment","tags":[],"total":1,"task":"Preparing deployment","index":1,"state":"started","progress":0}
+{"time":1700000002,"stage":"Preparing deployment","tags":[],"total":1,"task":"Preparing deployment","index":1,"state":"finished","progress":100}
+not an event
+{"time":1700000002,"stage":"Compiling packages","tags":[],"total":2,"task":"golang/abc","index":1,"state":"started","progress":0}
+{"time":1700000003,"stage":"Compiling packages","tags":[],"total":2,"task":"nginx/def","index":2,"state":"started","progress":0}
+{"time":1700000062,"stage":"Compiling packages","tags":[],"total":2,"task":"golang/abc","index":1,"state":"finished","progress":100}
+{"time":1700000125,"stage":"Compiling packages","tags":[],"total":2,"task":"nginx/def","index":2,"state":"failed","progress":100,"data":{"status":"failed"},"error":{"code":450001,"message":"make: not found"}}
+`
+
+var _ = Describe("taskevents", func() {
+	Describe("Parse", func() {
+		It("parses every event line and skips the rest", func() {
+			events := taskevents.Parse(eventOutput)
+			Expect(events).To(HaveLen(6))
+			Expect(events[2].Stage).To(Equal("Compiling packages"))
+			Expect(events[2].Task).To(Equal("golang/abc"))
+			Expect(events[2].Total).To(Equal(2))
+			Expect(events[5].State).To(Equal("failed"))
+			Expect(events[5].Error.Message).To(Equal("make: not found"))
+		})
+	})
+
+	Describe("Reporter", func() {
+		It("renders a line per event and a per-stage summary", func() {
+			out := new(bytes.Buffer)
+			reporter := taskevents.NewReporter(out, "release-a/1.0")
+
+			for _, event := range taskevents.Parse(eventOutput) {
+				reporter.Handle(event)
+			}
+			reporter.Summary()
+
+			Expect(out.String()).To(Equal(`[release-a/1.0] 22:13:20 | Preparing deployment (1/1): Preparing deployment
+[release-a/1.0] 22:13:22 | Preparing deployment: Preparing deployment done (00:00:02)
+[release-a/1.0] 22:13:22 | Compiling packages (1/2): golang/abc
+[release-a/1.0] 22:13:23 | Compiling packages (2/2): nginx/def
+[release-a/1.0] 22:14:22 | Compiling packages: golang/abc done (00:01:00)
+[release-a/1.0] 22:15:25 | Compiling packages: nginx/def failed after 00:02:02: make: not found
+[release-a/1.0] Stage timings:
+[release-a/1.0]   Preparing deployment  1 task             00:00:02
+[release-a/1.0]   Compiling packages    2 tasks, 1 failed  00:02:03
+`))
+		})
+
+		It("prints nothing for a summary without events", func() {
+			out := new(bytes.Buffer)
+			taskevents.NewReporter(out, "").Summary()
+			Expect(out.String()).To(BeEmpty())
+		})
+	})
+})
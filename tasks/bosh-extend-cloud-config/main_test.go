@@ -0,0 +1,122 @@
+package main_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+const currentConfig = `azs:
+- name: z1
+- name: z2
+vm_types:
+- name: default
+  cloud_properties:
+    machine_type: n1-standard-2
+compilation:
+  workers: 5
+  network: default
+`
+
+var _ = Describe("bosh-extend-cloud-config", func() {
+	var (
+		task string
+		dir  string
+	)
+
+	BeforeEach(func() {
+		var err error
+		task, err = gexec.Build("github.com/cloudfoundry/runtime-ci/tasks/bosh-extend-cloud-config")
+		Expect(err).NotTo(HaveOccurred())
+
+		dir = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "current.yml"), []byte(currentConfig), 0644)).To(Succeed())
+	})
+
+	// run takes the output of `bosh int current.yml -o ops-file`, as the task
+	// script does, and returns the config it would update the Director with.
+	run := func(interpolated string) (*gexec.Session, string) {
+		Expect(os.WriteFile(filepath.Join(dir, "extended.yml"), []byte(interpolated), 0644)).To(Succeed())
+
+		cmd := exec.Command(task, filepath.Join(dir, "current.yml"), filepath.Join(dir, "extended.yml"), filepath.Join(dir, "merged.yml"))
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, 10*time.Second).Should(gexec.Exit())
+
+		merged, _ := os.ReadFile(filepath.Join(dir, "merged.yml"))
+		return session, string(merged)
+	}
+
+	It("applies replace and remove ops", func() {
+		// - type: replace
+		//   path: /vm_types/name=default/cloud_properties/machine_type
+		//   value: n1-standard-4
+		// - type: replace
+		//   path: /compilation/workers
+		//   value: 10
+		// - type: remove
+		//   path: /azs/name=z2
+		session, merged := run(`azs:
+- name: z1
+vm_types:
+- name: default
+  cloud_properties:
+    machine_type: n1-standard-4
+compilation:
+  workers: 10
+  network: default
+`)
+		Expect(session.ExitCode()).To(Equal(0))
+		Expect(session.Out).To(gbytes.Say(`Replaced \(2\):\n  vm_types/default\n  compilation\nRemoved \(1\):\n  azs/z2\n`))
+
+		Expect(merged).To(ContainSubstring("machine_type: n1-standard-4"))
+		Expect(merged).To(ContainSubstring("workers: 10"))
+		Expect(merged).NotTo(ContainSubstring("z2"))
+	})
+
+	It("changes nothing when an ops file that appends a vm_type is applied again", func() {
+		// - type: replace
+		//   path: /vm_types/-
+		//   value: {name: default, cloud_properties: {machine_type: n1-standard-2}}
+		session, merged := run(`azs:
+- name: z1
+- name: z2
+vm_types:
+- name: default
+  cloud_properties:
+    machine_type: n1-standard-2
+- name: default
+  cloud_properties:
+    machine_type: n1-standard-2
+compilation:
+  workers: 5
+  network: default
+`)
+		Expect(session.ExitCode()).To(Equal(0))
+		Expect(session.Out).To(gbytes.Say(`Added \(0\):\nUnchanged \(4\):`))
+		Expect(merged).To(MatchYAML(currentConfig))
+	})
+
+	It("refuses to append a vm_type that is already defined differently", func() {
+		// - type: replace
+		//   path: /vm_types/-
+		//   value: {name: default, cloud_properties: {machine_type: n1-standard-8}}
+		session, _ := run(`vm_types:
+- name: default
+  cloud_properties:
+    machine_type: n1-standard-2
+- name: default
+  cloud_properties:
+    machine_type: n1-standard-8
+`)
+		Expect(session.ExitCode()).To(Equal(1))
+		Expect(session.Out).To(gbytes.Say("Refusing to replace them."))
+		Expect(session.Out).To(gbytes.Say("conflicting definitions for vm_types/default"))
+	})
+})
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshconfig"
+	"github.com/spf13/pflag"
+)
+
+func main() {
+	pflag.Parse()
+	if pflag.NArg() != 3 {
+		fmt.Println("usage: main.go <current-config> <interpolated-config> <output>")
+		os.Exit(1)
+	}
+
+	summary, err := boshconfig.ApplyFiles(boshconfig.RuntimeConfig, pflag.Arg(0), pflag.Arg(1), pflag.Arg(2))
+	if err != nil {
+		var conflictErr boshconfig.ConflictError
+		if errors.As(err, &conflictErr) {
+			fmt.Println("The ops file appends entries that the runtime-config already defines differently. Refusing to replace them.")
+		}
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Print(summary)
+}
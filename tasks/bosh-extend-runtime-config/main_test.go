@@ -0,0 +1,65 @@
+package main_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("bosh-extend-runtime-config", func() {
+	It("applies replace and remove ops", func() {
+		task, err := gexec.Build("github.com/cloudfoundry/runtime-ci/tasks/bosh-extend-runtime-config")
+		Expect(err).NotTo(HaveOccurred())
+
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "current.yml"), []byte(`releases:
+- name: bpm
+  version: 1.2.3
+- name: os-conf
+  version: 22.0.0
+addons:
+- name: bpm
+  jobs: [{name: bpm, release: bpm}]
+- name: os-configuration
+  jobs: [{name: sysctl, release: os-conf}]
+`), 0644)).To(Succeed())
+
+		// `bosh int current.yml` with:
+		// - type: replace
+		//   path: /releases/name=bpm/version
+		//   value: 1.2.4
+		// - type: remove
+		//   path: /releases/name=os-conf
+		// - type: remove
+		//   path: /addons/name=os-configuration
+		Expect(os.WriteFile(filepath.Join(dir, "extended.yml"), []byte(`releases:
+- name: bpm
+  version: 1.2.4
+addons:
+- name: bpm
+  jobs: [{name: bpm, release: bpm}]
+`), 0644)).To(Succeed())
+
+		cmd := exec.Command(task, filepath.Join(dir, "current.yml"), filepath.Join(dir, "extended.yml"), filepath.Join(dir, "merged.yml"))
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, 10*time.Second).Should(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say(`Replaced \(1\):\n  releases/bpm\nRemoved \(2\):\n  releases/os-conf\n  addons/os-configuration\n`))
+
+		merged, err := os.ReadFile(filepath.Join(dir, "merged.yml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(merged).To(MatchYAML(`releases:
+- name: bpm
+  version: 1.2.4
+addons:
+- name: bpm
+  jobs: [{name: bpm, release: bpm}]
+`))
+	})
+})
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blang/semver"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/concourse"
+	"github.com/cloudfoundry/runtime-ci/task-libs/deploymentdiff"
+	"github.com/cloudfoundry/runtime-ci/tasks/calculate-cf-deployment-version/versionbump"
+)
+
+var task = concourse.Task{
+	Inputs:  []string{"cf-deployment-main", "cf-deployment-release-candidate"},
+	Outputs: []string{"cf-deployment-version"},
+	Params:  []string{"VERSION_RULES"},
+}
+
+func main() {
+	err := task.Validate("task.yml")
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
+	dirs, err := task.Setup(os.Args[1])
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
+	rules, err := versionbump.ParseRules([]byte(task.Param("VERSION_RULES")))
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
+	current, err := readManifestVersion(dirs.Dir("cf-deployment-main"))
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
+	changes, err := deploymentdiff.Diff(dirs.Dir("cf-deployment-main"), dirs.Dir("cf-deployment-release-candidate"))
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
+	reasons := versionbump.Reasons(changes, rules)
+	next, bump := versionbump.Next(current, reasons)
+
+	var b strings.Builder
+	for _, reason := range reasons {
+		fmt.Fprintln(&b, reason)
+	}
+
+	fmt.Printf("Current version: %s\nNext version: %s (%s)\n%s", current, next, bump, b.String())
+
+	outputDir := dirs.Dir("cf-deployment-version")
+	for name, content := range map[string]string{
+		"version":     next.String(),
+		"bump":        bump,
+		"reasons.txt": b.String(),
+	} {
+		err = os.WriteFile(filepath.Join(outputDir, name), []byte(content), 0644)
+		if err != nil {
+			fmt.Print(err)
+			os.Exit(1)
+		}
+	}
+}
+
+// readManifestVersion reads the manifest_version of cf-deployment.yml, e.g.
+// v45.2.1.
+func readManifestVersion(dir string) (semver.Version, error) {
+	content, err := concourse.ReadFile(dir, "cf-deployment.yml")
+	if err != nil {
+		return semver.Version{}, err
+	}
+
+	var manifest struct {
+		ManifestVersion string `yaml:"manifest_version"`
+	}
+	err = yaml.Unmarshal([]byte(content), &manifest)
+	if err != nil {
+		return semver.Version{}, err
+	}
+
+	version, err := semver.ParseTolerant(manifest.ManifestVersion)
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("failed to parse manifest_version %q of %s: %w", manifest.ManifestVersion, filepath.Base(dir), err)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,166 @@
+// Package versionbump proposes the next cf-deployment version from the
+// changes between the main branch and a release candidate.
+package versionbump
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/task-libs/deploymentdiff"
+)
+
+// Bumps, from the largest to the smallest.
+const (
+	Major = "major"
+	Minor = "minor"
+	Patch = "patch"
+	None  = "none"
+)
+
+var bumpOrder = map[string]int{None: 0, Patch: 1, Minor: 2, Major: 3}
+
+// Kinds of change that rules apply to.
+const (
+	ReleaseRemoved          = "release-removed"
+	ReleaseAdded            = "release-added"
+	ReleaseUpgraded         = "release-upgraded"
+	ReleaseDowngraded       = "release-downgraded"
+	OpsFileRemoved          = "ops-file-removed"
+	OpsFileAdded            = "ops-file-added"
+	OpsFileUpdated          = "ops-file-updated"
+	StemcellUpdated         = "stemcell-updated"
+	CompiledReleasesUpdated = "compiled-releases-updated"
+)
+
+// Rules maps each kind of change to the bump it requires.
+type Rules map[string]string
+
+// DefaultRules makes removals major, new and bumped releases minor, and
+// everything else, such as a stemcell-only change, a patch.
+func DefaultRules() Rules {
+	return Rules{
+		ReleaseRemoved:          Major,
+		ReleaseAdded:            Minor,
+		ReleaseUpgraded:         Minor,
+		ReleaseDowngraded:       Minor,
+		OpsFileRemoved:          Major,
+		OpsFileAdded:            Minor,
+		OpsFileUpdated:          Patch,
+		StemcellUpdated:         Patch,
+		CompiledReleasesUpdated: Patch,
+	}
+}
+
+// ParseRules reads a YAML map of kinds of change to bumps, e.g.
+// "ops-file-updated: minor", over the default rules.
+func ParseRules(content []byte) (Rules, error) {
+	rules := DefaultRules()
+
+	var overrides map[string]string
+	err := yaml.Unmarshal(content, &overrides)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse version rules: %w", err)
+	}
+
+	for kind, bump := range overrides {
+		if _, ok := rules[kind]; !ok {
+			return nil, fmt.Errorf("unknown kind of change %q in version rules", kind)
+		}
+		if _, ok := bumpOrder[bump]; !ok {
+			return nil, fmt.Errorf("unknown bump %q for %s in version rules", bump, kind)
+		}
+		rules[kind] = bump
+	}
+
+	return rules, nil
+}
+
+// Reason is a change and the bump that a rule gives it.
+type Reason struct {
+	Kind    string
+	Subject string
+	Bump    string
+}
+
+func (r Reason) String() string {
+	return fmt.Sprintf("%s: %s %s", r.Bump, r.Kind, r.Subject)
+}
+
+// Reasons applies the rules to every change, largest bump first.
+func Reasons(changes deploymentdiff.Changes, rules Rules) []Reason {
+	var reasons []Reason
+	add := func(kind, subject string) {
+		reasons = append(reasons, Reason{Kind: kind, Subject: subject, Bump: rules[kind]})
+	}
+
+	for _, change := range changes.Releases {
+		subject := fmt.Sprintf("%s (%s)", change.Name, strings.Join(change.Files, ", "))
+		switch change.Kind {
+		case deploymentdiff.Added:
+			add(ReleaseAdded, fmt.Sprintf("%s %s", subject, change.New.Version))
+		case deploymentdiff.Removed:
+			add(ReleaseRemoved, fmt.Sprintf("%s %s", subject, change.Old.Version))
+		case deploymentdiff.Upgraded:
+			add(ReleaseUpgraded, fmt.Sprintf("%s %s -> %s", subject, change.Old.Version, change.New.Version))
+		case deploymentdiff.Downgraded:
+			add(ReleaseDowngraded, fmt.Sprintf("%s %s -> %s", subject, change.Old.Version, change.New.Version))
+		}
+	}
+
+	for _, path := range changes.RemovedOpsFiles {
+		add(OpsFileRemoved, path)
+	}
+	for _, path := range changes.NewOpsFiles {
+		add(OpsFileAdded, path)
+	}
+	for _, path := range changes.UpdatedOpsFiles {
+		add(OpsFileUpdated, path)
+	}
+
+	for _, change := range changes.Stemcells {
+		add(StemcellUpdated, fmt.Sprintf("%s %s -> %s", change.Alias, stemcellName(change.Old), stemcellName(change.New)))
+	}
+
+	if !changes.CompiledReleases.IsEmpty() {
+		add(CompiledReleasesUpdated, fmt.Sprintf("compiled against %s", strings.Join(changes.CompiledReleases.NewStemcells, ", ")))
+	}
+
+	sort.SliceStable(reasons, func(i, j int) bool { return bumpOrder[reasons[i].Bump] > bumpOrder[reasons[j].Bump] })
+
+	return reasons
+}
+
+// Next returns the version after current for the largest bump of reasons,
+// and that bump. Without reasons the version is unchanged.
+func Next(current semver.Version, reasons []Reason) (semver.Version, string) {
+	bump := None
+	for _, reason := range reasons {
+		if bumpOrder[reason.Bump] > bumpOrder[bump] {
+			bump = reason.Bump
+		}
+	}
+
+	next := semver.Version{Major: current.Major, Minor: current.Minor, Patch: current.Patch}
+	switch bump {
+	case Major:
+		next = semver.Version{Major: current.Major + 1}
+	case Minor:
+		next = semver.Version{Major: current.Major, Minor: current.Minor + 1}
+	case Patch:
+		next.Patch++
+	}
+
+	return next, bump
+}
+
+func stemcellName(stemcell *bosh.Stemcell) string {
+	if stemcell == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%s/%s", stemcell.OS, stemcell.Version)
+}
@@ -0,0 +1,13 @@
+package versionbump_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestVersionbump(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Versionbump Suite")
+}
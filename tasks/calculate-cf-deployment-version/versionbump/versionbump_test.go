@@ -0,0 +1,117 @@
+package versionbump_test
+
+import (
+	"github.com/blang/semver"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/task-libs/deploymentdiff"
+	"github.com/cloudfoundry/runtime-ci/tasks/calculate-cf-deployment-version/versionbump"
+)
+
+var _ = Describe("Versionbump", func() {
+	current := semver.MustParse("45.2.1")
+
+	stemcellChange := deploymentdiff.StemcellChange{
+		Alias: "default",
+		Old:   &bosh.Stemcell{OS: "ubuntu-jammy", Version: "1.10"},
+		New:   &bosh.Stemcell{OS: "ubuntu-jammy", Version: "1.11"},
+	}
+
+	releaseChange := func(kind, name string) deploymentdiff.ReleaseChange {
+		return deploymentdiff.ReleaseChange{
+			Name:  name,
+			Kind:  kind,
+			Old:   &bosh.Release{Name: name, Version: "1.0.0"},
+			New:   &bosh.Release{Name: name, Version: "1.1.0"},
+			Files: []string{"cf-deployment.yml"},
+		}
+	}
+
+	Describe("Reasons and Next", func() {
+		It("makes a stemcell-only change a patch", func() {
+			reasons := versionbump.Reasons(deploymentdiff.Changes{
+				Stemcells: []deploymentdiff.StemcellChange{stemcellChange},
+				CompiledReleases: deploymentdiff.CompiledReleasesChange{
+					OldStemcells: []string{"ubuntu-jammy/1.10"},
+					NewStemcells: []string{"ubuntu-jammy/1.11"},
+				},
+			}, versionbump.DefaultRules())
+
+			Expect(reasons).To(Equal([]versionbump.Reason{
+				{Kind: versionbump.StemcellUpdated, Subject: "default ubuntu-jammy/1.10 -> ubuntu-jammy/1.11", Bump: versionbump.Patch},
+				{Kind: versionbump.CompiledReleasesUpdated, Subject: "compiled against ubuntu-jammy/1.11", Bump: versionbump.Patch},
+			}))
+
+			next, bump := versionbump.Next(current, reasons)
+			Expect(next.String()).To(Equal("45.2.2"))
+			Expect(bump).To(Equal(versionbump.Patch))
+		})
+
+		It("makes a release bump a minor", func() {
+			reasons := versionbump.Reasons(deploymentdiff.Changes{
+				Releases:  []deploymentdiff.ReleaseChange{releaseChange(deploymentdiff.Upgraded, "capi")},
+				Stemcells: []deploymentdiff.StemcellChange{stemcellChange},
+			}, versionbump.DefaultRules())
+
+			Expect(reasons[0].String()).To(Equal("minor: release-upgraded capi (cf-deployment.yml) 1.0.0 -> 1.1.0"))
+
+			next, bump := versionbump.Next(current, reasons)
+			Expect(next.String()).To(Equal("45.3.0"))
+			Expect(bump).To(Equal(versionbump.Minor))
+		})
+
+		It("makes a removed release or ops file a major", func() {
+			reasons := versionbump.Reasons(deploymentdiff.Changes{
+				Releases:        []deploymentdiff.ReleaseChange{releaseChange(deploymentdiff.Added, "otel")},
+				RemovedOpsFiles: []string{"operations/scale-to-one-az.yml"},
+			}, versionbump.DefaultRules())
+
+			Expect(reasons).To(Equal([]versionbump.Reason{
+				{Kind: versionbump.OpsFileRemoved, Subject: "operations/scale-to-one-az.yml", Bump: versionbump.Major},
+				{Kind: versionbump.ReleaseAdded, Subject: "otel (cf-deployment.yml) 1.1.0", Bump: versionbump.Minor},
+			}))
+
+			next, bump := versionbump.Next(current, reasons)
+			Expect(next.String()).To(Equal("46.0.0"))
+			Expect(bump).To(Equal(versionbump.Major))
+		})
+
+		It("keeps the version without changes", func() {
+			reasons := versionbump.Reasons(deploymentdiff.Changes{}, versionbump.DefaultRules())
+			Expect(reasons).To(BeEmpty())
+
+			next, bump := versionbump.Next(current, reasons)
+			Expect(next.String()).To(Equal("45.2.1"))
+			Expect(bump).To(Equal(versionbump.None))
+		})
+	})
+
+	Describe("ParseRules", func() {
+		It("overrides the default rules", func() {
+			rules, err := versionbump.ParseRules([]byte("ops-file-updated: minor\nstemcell-updated: none\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(rules[versionbump.OpsFileUpdated]).To(Equal(versionbump.Minor))
+			Expect(rules[versionbump.StemcellUpdated]).To(Equal(versionbump.None))
+			Expect(rules[versionbump.ReleaseRemoved]).To(Equal(versionbump.Major))
+		})
+
+		It("accepts empty rules", func() {
+			rules, err := versionbump.ParseRules(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rules).To(Equal(versionbump.DefaultRules()))
+		})
+
+		It("rejects unknown kinds of change", func() {
+			_, err := versionbump.ParseRules([]byte("release-renamed: major"))
+			Expect(err).To(MatchError(`unknown kind of change "release-renamed" in version rules`))
+		})
+
+		It("rejects unknown bumps", func() {
+			_, err := versionbump.ParseRules([]byte("release-added: huge"))
+			Expect(err).To(MatchError(`unknown bump "huge" for release-added in version rules`))
+		})
+	})
+})
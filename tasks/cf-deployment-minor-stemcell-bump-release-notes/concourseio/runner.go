@@ -6,8 +6,16 @@ import (
 	"path/filepath"
 
 	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/task-libs/concourse"
 )
 
+// Task declares the inputs and outputs that
+// cf-deployment-minor-stemcell-bump-release-notes expects.
+var Task = concourse.Task{
+	Inputs:  []string{"cf-deployment-main", "release-version", "stemcell"},
+	Outputs: []string{"cf-deployment-minor-stemcell-bump-release-notes"},
+}
+
 type Runner struct {
 	In  Inputs
 	Out Outputs
@@ -24,17 +32,21 @@ type Outputs struct {
 }
 
 func NewRunner(buildDir string) (Runner, error) {
-	inputs, err := setupInputs(buildDir)
+	dirs, err := Task.Setup(buildDir)
 	if err != nil {
 		return Runner{}, err
 	}
 
-	outputs, err := setupOutputs(buildDir)
-	if err != nil {
-		return Runner{}, err
-	}
-
-	return Runner{In: inputs, Out: outputs}, nil
+	return Runner{
+		In: Inputs{
+			CFDeploymentDir:   dirs.Dir("cf-deployment-main"),
+			ReleaseVersionDir: dirs.Dir("release-version"),
+			StemcellDir:       dirs.Dir("stemcell"),
+		},
+		Out: Outputs{
+			ReleaseNotesDir: dirs.Dir("cf-deployment-minor-stemcell-bump-release-notes"),
+		},
+	}, nil
 }
 
 func (r Runner) ReadStemcellInfoFromManifest(stemcellAlias string) (bosh.Stemcell, error) {
@@ -98,41 +110,3 @@ func (r Runner) GenerateReleaseName() error {
 
 	return nil
 }
-
-func setupInputs(buildDir string) (Inputs, error) {
-	cfDeploymentDir, err := buildSubDir(buildDir, "cf-deployment-main")
-	if err != nil {
-		return Inputs{}, err
-	}
-
-	releaseVersionDir, err := buildSubDir(buildDir, "release-version")
-	if err != nil {
-		return Inputs{}, err
-	}
-
-	stemcellDir, err := buildSubDir(buildDir, "stemcell")
-	if err != nil {
-		return Inputs{}, err
-	}
-
-	return Inputs{cfDeploymentDir, releaseVersionDir, stemcellDir}, nil
-}
-
-func setupOutputs(buildDir string) (Outputs, error) {
-	ReleaseNotesDir, err := buildSubDir(buildDir, "cf-deployment-minor-stemcell-bump-release-notes")
-	if err != nil {
-		return Outputs{}, err
-	}
-
-	return Outputs{ReleaseNotesDir}, nil
-}
-
-func buildSubDir(buildDir, subDir string) (string, error) {
-	dir := filepath.Join(buildDir, subDir)
-	_, err := os.Stat(dir)
-	if os.IsNotExist(err) {
-		return "", fmt.Errorf("missing sub directory '%s' in build directory '%s'", subDir, buildDir)
-	}
-
-	return dir, nil
-}
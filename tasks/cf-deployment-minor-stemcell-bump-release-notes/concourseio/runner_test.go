@@ -28,6 +28,12 @@ var _ = Describe("Runner", func() {
 		Expect(os.RemoveAll(buildDir)).To(Succeed())
 	})
 
+	Describe("Task", func() {
+		It("matches task.yml", func() {
+			Expect(concourseio.Task.Validate("../task.yml")).To(Succeed())
+		})
+	})
+
 	Describe("NewRunner", func() {
 		var (
 			expectedCFDeploymentDir   string
@@ -9,6 +9,12 @@ import (
 )
 
 func main() {
+	err := concourseio.Task.Validate("task.yml")
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
 	buildDir := os.Args[1]
 	runner, err := concourseio.NewRunner(buildDir)
 	if err != nil {
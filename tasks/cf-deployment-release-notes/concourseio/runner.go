@@ -0,0 +1,81 @@
+package concourseio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/concourse"
+	"github.com/cloudfoundry/runtime-ci/task-libs/deploymentdiff"
+	"github.com/cloudfoundry/runtime-ci/tasks/cf-deployment-release-notes/releasenotes"
+)
+
+// Task declares the inputs and outputs that cf-deployment-release-notes
+// expects.
+var Task = concourse.Task{
+	Inputs:  []string{"cf-deployment-main", "cf-deployment-release-candidate", "release-version"},
+	Outputs: []string{"cf-deployment-release-notes"},
+}
+
+type Runner struct {
+	In  Inputs
+	Out Outputs
+}
+
+type Inputs struct {
+	MainDir             string
+	ReleaseCandidateDir string
+	ReleaseVersionDir   string
+}
+
+type Outputs struct {
+	ReleaseNotesDir string
+}
+
+func NewRunner(buildDir string) (Runner, error) {
+	dirs, err := Task.Setup(buildDir)
+	if err != nil {
+		return Runner{}, err
+	}
+
+	return Runner{
+		In: Inputs{
+			MainDir:             dirs.Dir("cf-deployment-main"),
+			ReleaseCandidateDir: dirs.Dir("cf-deployment-release-candidate"),
+			ReleaseVersionDir:   dirs.Dir("release-version"),
+		},
+		Out: Outputs{
+			ReleaseNotesDir: dirs.Dir("cf-deployment-release-notes"),
+		},
+	}, nil
+}
+
+// GenerateReleaseNotes writes the differences between cf-deployment-main and
+// the release candidate to body.txt.
+func (r Runner) GenerateReleaseNotes() error {
+	changes, err := deploymentdiff.Diff(r.In.MainDir, r.In.ReleaseCandidateDir)
+	if err != nil {
+		return fmt.Errorf("failed to compare cf-deployment-main with the release candidate: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(r.Out.ReleaseNotesDir, "body.txt"), []byte(releasenotes.Render(changes)), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write release notes file: %w", err)
+	}
+
+	return nil
+}
+
+func (r Runner) GenerateReleaseName() error {
+	releaseVersion, err := concourse.ReadVersion(r.In.ReleaseVersionDir)
+	if err != nil {
+		return fmt.Errorf("failed to read release version: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(r.Out.ReleaseNotesDir, "name.txt"), []byte(fmt.Sprintf("v%s", releaseVersion)), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write release name file: %w", err)
+	}
+
+	return nil
+}
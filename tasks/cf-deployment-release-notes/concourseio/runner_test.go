@@ -0,0 +1,123 @@
+package concourseio_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/runtime-ci/tasks/cf-deployment-release-notes/concourseio"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Runner", func() {
+	var (
+		buildDir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		buildDir, err = os.MkdirTemp("", "concourseio-rootdir-")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(buildDir)).To(Succeed())
+	})
+
+	Describe("Task", func() {
+		It("matches task.yml", func() {
+			Expect(concourseio.Task.Validate("../task.yml")).To(Succeed())
+		})
+	})
+
+	Describe("NewRunner", func() {
+		Context("when all directories exist", func() {
+			BeforeEach(func() {
+				for _, dir := range []string{"cf-deployment-main", "cf-deployment-release-candidate", "release-version", "cf-deployment-release-notes"} {
+					Expect(os.Mkdir(filepath.Join(buildDir, dir), 0777)).To(Succeed())
+				}
+			})
+
+			It("will instantiate the runner", func() {
+				runner, err := concourseio.NewRunner(buildDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(runner).To(Equal(concourseio.Runner{
+					In: concourseio.Inputs{
+						MainDir:             filepath.Join(buildDir, "cf-deployment-main"),
+						ReleaseCandidateDir: filepath.Join(buildDir, "cf-deployment-release-candidate"),
+						ReleaseVersionDir:   filepath.Join(buildDir, "release-version"),
+					},
+					Out: concourseio.Outputs{
+						ReleaseNotesDir: filepath.Join(buildDir, "cf-deployment-release-notes"),
+					},
+				}))
+			})
+		})
+
+		Context("when the release candidate is missing", func() {
+			BeforeEach(func() {
+				Expect(os.Mkdir(filepath.Join(buildDir, "cf-deployment-main"), 0777)).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				_, err := concourseio.NewRunner(buildDir)
+				Expect(err).To(MatchError(fmt.Sprintf("missing sub directory 'cf-deployment-release-candidate' in build directory '%s'", buildDir)))
+			})
+		})
+	})
+
+	Describe("GenerateReleaseNotes", func() {
+		var runner concourseio.Runner
+
+		BeforeEach(func() {
+			runner = concourseio.Runner{
+				In: concourseio.Inputs{
+					MainDir:             filepath.Join(buildDir, "cf-deployment-main"),
+					ReleaseCandidateDir: filepath.Join(buildDir, "cf-deployment-release-candidate"),
+				},
+				Out: concourseio.Outputs{ReleaseNotesDir: buildDir},
+			}
+
+			for version, dir := range map[string]string{"1.0.0": runner.In.MainDir, "1.1.0": runner.In.ReleaseCandidateDir} {
+				Expect(os.Mkdir(dir, 0777)).To(Succeed())
+				manifest := fmt.Sprintf("releases:\n- name: capi\n  version: %s\n", version)
+				Expect(os.WriteFile(filepath.Join(dir, "cf-deployment.yml"), []byte(manifest), 0644)).To(Succeed())
+			}
+		})
+
+		It("writes the release notes to body.txt", func() {
+			Expect(runner.GenerateReleaseNotes()).To(Succeed())
+
+			body, err := os.ReadFile(filepath.Join(buildDir, "body.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(ContainSubstring("| capi | 1.0.0 | 1.1.0 | |\n"))
+		})
+
+		Context("when a manifest is missing", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(filepath.Join(runner.In.ReleaseCandidateDir, "cf-deployment.yml"))).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(runner.GenerateReleaseNotes()).To(MatchError(ContainSubstring("failed to compare cf-deployment-main with the release candidate")))
+			})
+		})
+	})
+
+	Describe("GenerateReleaseName", func() {
+		It("writes the release version to name.txt", func() {
+			Expect(os.WriteFile(filepath.Join(buildDir, "version"), []byte("45.6.0\n"), 0644)).To(Succeed())
+			runner := concourseio.Runner{
+				In:  concourseio.Inputs{ReleaseVersionDir: buildDir},
+				Out: concourseio.Outputs{ReleaseNotesDir: buildDir},
+			}
+
+			Expect(runner.GenerateReleaseName()).To(Succeed())
+
+			name, err := os.ReadFile(filepath.Join(buildDir, "name.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(name)).To(Equal("v45.6.0"))
+		})
+	})
+})
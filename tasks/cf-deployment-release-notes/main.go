@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/cloudfoundry/runtime-ci/tasks/cf-deployment-release-notes/concourseio"
+)
+
+func main() {
+	err := concourseio.Task.Validate("task.yml")
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
+	buildDir := os.Args[1]
+	runner, err := concourseio.NewRunner(buildDir)
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Generating release notes...")
+	err = runner.GenerateReleaseNotes()
+	if err != nil {
+		log.Fatalf("Failed to generate release notes: %s", err)
+	}
+
+	fmt.Println("Generating release name...")
+	err = runner.GenerateReleaseName()
+	if err != nil {
+		log.Fatalf("Failed to generate release name: %s", err)
+	}
+}
@@ -0,0 +1,13 @@
+package releasenotes_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestReleasenotes(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Releasenotes Suite")
+}
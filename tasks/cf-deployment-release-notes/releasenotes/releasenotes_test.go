@@ -0,0 +1,183 @@
+package releasenotes_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/deploymentdiff"
+	"github.com/cloudfoundry/runtime-ci/tasks/cf-deployment-release-notes/releasenotes"
+)
+
+var _ = Describe("Releasenotes", func() {
+	var (
+		mainDir      string
+		candidateDir string
+	)
+
+	writeFile := func(dir, path, content string) {
+		path = filepath.Join(dir, path)
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		mainDir, err = os.MkdirTemp("", "cf-deployment-main-")
+		Expect(err).NotTo(HaveOccurred())
+		candidateDir, err = os.MkdirTemp("", "cf-deployment-release-candidate-")
+		Expect(err).NotTo(HaveOccurred())
+
+		writeFile(mainDir, "cf-deployment.yml", `---
+releases:
+- name: capi
+  version: 1.2.3
+  url: https://bosh.io/d/github.com/cloudfoundry/capi-release?v=1.2.3
+- name: uaa
+  version: 4.5.6
+  url: https://bosh.io/d/github.com/cloudfoundry/uaa-release?v=4.5.6
+- name: garden
+  version: 1.0.0
+  url: https://github.com/cloudfoundry/garden-runc-release/releases/download/v1.0.0/garden-runc-1.0.0.tgz
+stemcells:
+- alias: default
+  os: ubuntu-jammy
+  version: "1.10"
+`)
+		writeFile(candidateDir, "cf-deployment.yml", `---
+releases:
+- name: capi
+  version: 1.3.0
+  url: https://bosh.io/d/github.com/cloudfoundry/capi-release?v=1.3.0
+- name: uaa
+  version: 4.5.6
+  url: https://bosh.io/d/github.com/cloudfoundry/uaa-release?v=4.5.6
+- name: garden
+  version: 0.9.0
+  url: https://github.com/cloudfoundry/garden-runc-release/releases/download/v0.9.0/garden-runc-0.9.0.tgz
+stemcells:
+- alias: default
+  os: ubuntu-jammy
+  version: "1.11"
+`)
+
+		writeFile(mainDir, "operations/use-postgres.yml", `---
+- type: replace
+  path: /releases/name=postgres?
+  value:
+    name: postgres
+    version: "40"
+    url: https://bosh.io/d/github.com/cloudfoundry/postgres-release?v=40
+`)
+		writeFile(candidateDir, "operations/use-postgres.yml", `---
+- type: replace
+  path: /releases/name=postgres?
+  value:
+    name: postgres
+    version: "41"
+    url: https://bosh.io/d/github.com/cloudfoundry/postgres-release?v=41
+`)
+		writeFile(candidateDir, "operations/experimental/add-otel.yml", `---
+- type: replace
+  path: /releases/-
+  value:
+    name: otel
+    version: 0.1.0
+    url: https://bosh.io/d/github.com/cloudfoundry/otel-collector-release?v=0.1.0
+`)
+		writeFile(mainDir, "operations/scale-to-one-az.yml", `---
+- type: replace
+  path: /instance_groups/name=api/azs
+  value: [z1]
+`)
+		writeFile(mainDir, "operations/example-vars-files/vars.yml", "key: value\n")
+		writeFile(candidateDir, "operations/example-vars-files/vars.yml", "key: value\n")
+
+		writeFile(mainDir, "operations/use-compiled-releases.yml", `---
+- type: replace
+  path: /releases/name=capi
+  value:
+    name: capi
+    version: 1.2.3
+    sha1: aaa
+    stemcell:
+      os: ubuntu-jammy
+      version: "1.10"
+- type: replace
+  path: /releases/name=uaa
+  value:
+    name: uaa
+    version: 4.5.6
+    sha1: bbb
+    stemcell:
+      os: ubuntu-jammy
+      version: "1.10"
+`)
+		writeFile(candidateDir, "operations/use-compiled-releases.yml", `---
+- type: replace
+  path: /releases/name=capi
+  value:
+    name: capi
+    version: 1.3.0
+    sha1: ccc
+    stemcell:
+      os: ubuntu-jammy
+      version: "1.11"
+`)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(mainDir)).To(Succeed())
+		Expect(os.RemoveAll(candidateDir)).To(Succeed())
+	})
+
+	Describe("Render", func() {
+		It("renders the notes as markdown", func() {
+			changes, err := deploymentdiff.Diff(mainDir, candidateDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(releasenotes.Render(changes)).To(Equal(`## Notices
+
+## Manifest Updates
+
+## Ops-files
+### New Ops-files
+- operations/experimental/add-otel.yml
+### Removed Ops-files
+- operations/scale-to-one-az.yml
+### Updated Ops-files
+- operations/use-postgres.yml
+
+## Stemcell Updates
+| Stemcell | Old Version | New Version |
+| -------- | ----------- | ----------- |
+| default | ubuntu-jammy 1.10 | ubuntu-jammy 1.11 |
+
+## Compiled Releases
+- Compiled against: ubuntu-jammy/1.11 (was ubuntu-jammy/1.10)
+- Removed: uaa
+- Recompiled: capi
+
+## Other Updates
+
+## Release Updates
+_Warning: The Release Notes column only highlights noteworthy updates for each release bump. However, it is not exhaustive and we recommend you visit the actual release notes below before every upgrade._
+| Release | Old Version | New Version | Release Notes |
+| ------- | ----------- | ----------- | ------------- |
+| capi | [1.2.3](https://bosh.io/releases/github.com/cloudfoundry/capi-release?version=1.2.3) | [1.3.0](https://bosh.io/releases/github.com/cloudfoundry/capi-release?version=1.3.0) | |
+| garden (downgraded) | [1.0.0](https://github.com/cloudfoundry/garden-runc-release/releases/tag/v1.0.0) | [0.9.0](https://github.com/cloudfoundry/garden-runc-release/releases/tag/v0.9.0) | |
+| postgres | [40](https://bosh.io/releases/github.com/cloudfoundry/postgres-release?version=40) | [41](https://bosh.io/releases/github.com/cloudfoundry/postgres-release?version=41) | |
+### New Releases
+- otel [0.1.0](https://bosh.io/releases/github.com/cloudfoundry/otel-collector-release?version=0.1.0) (operations/experimental/add-otel.yml)
+`))
+		})
+	})
+
+	Describe("ReleasePage", func() {
+		It("returns no page for other URLs", func() {
+			Expect(releasenotes.ReleasePage("https://storage.googleapis.com/compiled/capi.tgz", "1.0")).To(BeEmpty())
+		})
+	})
+})
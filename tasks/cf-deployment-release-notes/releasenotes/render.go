@@ -0,0 +1,148 @@
+// Package releasenotes renders the differences between cf-deployment-main
+// and a release candidate as the markdown body of a GitHub release.
+package releasenotes
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/task-libs/deploymentdiff"
+)
+
+// Render renders the changes in the layout of the cf-deployment GitHub
+// releases. The Notices, Manifest Updates and Other Updates sections are left
+// for the release manager to fill in.
+func Render(n deploymentdiff.Changes) string {
+	var b strings.Builder
+
+	b.WriteString("## Notices\n\n")
+	b.WriteString("## Manifest Updates\n\n")
+
+	b.WriteString("## Ops-files\n")
+	writeList(&b, "New Ops-files", n.NewOpsFiles)
+	writeList(&b, "Removed Ops-files", n.RemovedOpsFiles)
+	writeList(&b, "Updated Ops-files", n.UpdatedOpsFiles)
+	b.WriteString("\n")
+
+	if len(n.Stemcells) > 0 {
+		b.WriteString("## Stemcell Updates\n")
+		b.WriteString("| Stemcell | Old Version | New Version |\n")
+		b.WriteString("| -------- | ----------- | ----------- |\n")
+		for _, change := range n.Stemcells {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", change.Alias, stemcellName(change.Old), stemcellName(change.New))
+		}
+		b.WriteString("\n")
+	}
+
+	if !n.CompiledReleases.IsEmpty() {
+		c := n.CompiledReleases
+		b.WriteString("## Compiled Releases\n")
+		fmt.Fprintf(&b, "- Compiled against: %s (was %s)\n", joinOrNone(c.NewStemcells), joinOrNone(c.OldStemcells))
+		writeItem(&b, "Added", c.Added)
+		writeItem(&b, "Removed", c.Removed)
+		writeItem(&b, "Recompiled", c.Recompiled)
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Other Updates\n\n")
+
+	b.WriteString("## Release Updates\n")
+	b.WriteString("_Warning: The Release Notes column only highlights noteworthy updates for each release bump. However, it is not exhaustive and we recommend you visit the actual release notes below before every upgrade._\n")
+	b.WriteString("| Release | Old Version | New Version | Release Notes |\n")
+	b.WriteString("| ------- | ----------- | ----------- | ------------- |\n")
+	for _, change := range n.Releases {
+		if change.Kind == deploymentdiff.Added || change.Kind == deploymentdiff.Removed {
+			continue
+		}
+		name := change.Name
+		if change.Kind == deploymentdiff.Downgraded {
+			name += " (downgraded)"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | |\n", name, releaseVersion(change.Old), releaseVersion(change.New))
+	}
+
+	var added, removed []string
+	for _, change := range n.Releases {
+		switch change.Kind {
+		case deploymentdiff.Added:
+			added = append(added, fmt.Sprintf("%s %s (%s)", change.Name, releaseVersion(change.New), strings.Join(change.Files, ", ")))
+		case deploymentdiff.Removed:
+			removed = append(removed, fmt.Sprintf("%s %s (%s)", change.Name, releaseVersion(change.Old), strings.Join(change.Files, ", ")))
+		}
+	}
+	writeList(&b, "New Releases", added)
+	writeList(&b, "Removed Releases", removed)
+
+	return b.String()
+}
+
+func writeList(b *strings.Builder, heading string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "### %s\n", heading)
+	for _, item := range items {
+		fmt.Fprintf(b, "- %s\n", item)
+	}
+}
+
+func writeItem(b *strings.Builder, label string, names []string) {
+	if len(names) > 0 {
+		fmt.Fprintf(b, "- %s: %s\n", label, strings.Join(names, ", "))
+	}
+}
+
+func joinOrNone(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
+
+func stemcellName(stemcell *bosh.Stemcell) string {
+	if stemcell == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s %s", stemcell.OS, stemcell.Version)
+}
+
+func releaseVersion(release *bosh.Release) string {
+	if release == nil {
+		return ""
+	}
+
+	page := ReleasePage(release.URL, release.Version)
+	if page == "" {
+		return release.Version
+	}
+	return fmt.Sprintf("[%s](%s)", release.Version, page)
+}
+
+// ReleasePage returns the page of a release version for a bosh.io or GitHub
+// release URL, or "" for any other URL.
+func ReleasePage(releaseURL, version string) string {
+	u, err := url.Parse(releaseURL)
+	if err != nil {
+		return ""
+	}
+
+	switch u.Host {
+	case "bosh.io":
+		repo, ok := strings.CutPrefix(u.Path, "/d/")
+		if !ok {
+			return ""
+		}
+		return fmt.Sprintf("https://bosh.io/releases/%s?version=%s", repo, url.QueryEscape(version))
+	case "github.com":
+		parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+		if len(parts) < 5 || parts[2] != "releases" || parts[3] != "download" {
+			return ""
+		}
+		return fmt.Sprintf("https://github.com/%s/%s/releases/tag/%s", parts[0], parts[1], parts[4])
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Println("usage: main.go <manifest> <compiled-releases-ops-file>")
+		os.Exit(1)
+	}
+
+	manifestContent, err := os.ReadFile(os.Args[1])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	manifest, err := bosh.NewManifestFromFile(manifestContent)
+	if err != nil {
+		fmt.Printf("failed to parse %s: %s\n", os.Args[1], err)
+		os.Exit(1)
+	}
+
+	opsFileContent, err := os.ReadFile(os.Args[2])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	compiled, err := bosh.ParseReleaseOps(opsFileContent)
+	if err != nil {
+		fmt.Printf("failed to parse %s: %s\n", os.Args[2], err)
+		os.Exit(1)
+	}
+
+	gaps := bosh.CompiledReleaseCoverage(manifest, compiled)
+	for _, gap := range gaps {
+		fmt.Println(gap)
+	}
+
+	if len(gaps) > 0 {
+		fmt.Printf("%s does not cover %s: %d gap(s)\n", os.Args[2], os.Args[1], len(gaps))
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s covers every release of %s\n", os.Args[2], os.Args[1])
+}
@@ -1,60 +1,41 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
-	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/tasks/check-stemcell-versions/stemcellcheck"
 )
 
 func main() {
 	if len(os.Args) < 3 {
-		log.Fatalf("Usage: %s <buildDir> <branchToCompare>", os.Args[0])
+		log.Fatalf("Usage: %s <buildDir> <branchToCompare>...", os.Args[0])
 	}
 
 	buildDir := os.Args[1]
-	branchToCompare := os.Args[2]
 
-	content, err := os.ReadFile(filepath.Join(buildDir, "cf-deployment-main", "cf-deployment.yml"))
+	mainBranch, err := stemcellcheck.LoadBranch("main", filepath.Join(buildDir, "cf-deployment-main"))
 	if err != nil {
-		log.Fatalf("Failed to read main branch cf-deployment.yml: %s", err)
+		log.Fatal(err)
 	}
 
-	mainManifest, err := bosh.NewManifestFromFile(content)
-	if err != nil {
-		log.Fatalf("Failed to unmarshal main branch cf-deployment.yml: %s", err)
-	}
-
-	content, err = os.ReadFile(filepath.Join(buildDir, "cf-deployment-"+branchToCompare, "cf-deployment.yml"))
-	if err != nil {
-		log.Fatalf("Failed to read %s branch cf-deployment.yml: %s", branchToCompare, err)
-	}
-
-	branchToCompareManifest, err := bosh.NewManifestFromFile(content)
-	if err != nil {
-		log.Fatalf("Failed to unmarshal %s branch cf-deployment.yml: %s", branchToCompare, err)
-	}
-
-	mainStemcell := mainManifest.Stemcells[0]
-	branchToCompareStemcell := branchToCompareManifest.Stemcells[0]
-
-	if mainStemcell.OS != branchToCompareStemcell.OS {
-		log.Printf("%s branch stemcell OS (%s) is different to the main branch stemcell OS (%s). Proceeding.",
-			branchToCompare, branchToCompareStemcell.OS, mainStemcell.OS)
-		os.Exit(0)
+	var branches []stemcellcheck.Branch
+	for _, name := range os.Args[2:] {
+		branch, err := stemcellcheck.LoadBranch(name, filepath.Join(buildDir, "cf-deployment-"+name))
+		if err != nil {
+			log.Fatal(err)
+		}
+		branches = append(branches, branch)
 	}
 
-	result, err := branchToCompareStemcell.CompareVersion(mainStemcell)
-	if err != nil {
-		log.Fatalf("Failed to compare stemcell versions: %s", err)
-	}
+	report := stemcellcheck.Check(mainBranch, branches...)
+	fmt.Print(report)
 
-	if result == -1 {
-		log.Fatalf("%s branch stemcell version (%s) is behind the main branch stemcell version (%s). Aborting.",
-			branchToCompare, branchToCompareStemcell.Version, mainStemcell.Version)
+	if report.Failed() {
+		log.Fatal("Stemcells are behind the main branch or inconsistent. Aborting.")
 	}
 
-	log.Printf("%s branch stemcell version (%s) is ahead of, or equal to, the main branch stemcell version (%s). Proceeding.",
-		branchToCompare, branchToCompareStemcell.Version, mainStemcell.Version)
+	log.Print("Stemcells are consistent and not behind the main branch. Proceeding.")
 }
@@ -0,0 +1,443 @@
+// Package stemcellcheck compares the stemcells that cf-deployment branches
+// declare: every manifest stemcell alias, the stemcells pinned by ops files,
+// and the stemcells that use-compiled-releases.yml was compiled against.
+package stemcellcheck
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+)
+
+const (
+	manifestPath         = "cf-deployment.yml"
+	compiledReleasesPath = "operations/use-compiled-releases.yml"
+)
+
+// Row statuses.
+const (
+	StatusOK           = "ok"
+	StatusRegression   = "regression"
+	StatusInconsistent = "inconsistent"
+)
+
+// Pin is a stemcell declared somewhere in a branch. Key identifies the same
+// pin across branches.
+type Pin struct {
+	Key      string
+	Source   string
+	Stemcell bosh.Stemcell
+	// Releases are the compiled releases whose newest stemcell of the OS is
+	// Stemcell, for pins from use-compiled-releases.yml.
+	Releases []bosh.Release
+}
+
+// Branch is the stemcells declared by one cf-deployment branch.
+type Branch struct {
+	Name              string
+	ManifestStemcells []bosh.Stemcell
+	Pins              []Pin
+}
+
+// LoadBranch reads the stemcells of the cf-deployment checkout in dir.
+func LoadBranch(name, dir string) (Branch, error) {
+	branch := Branch{Name: name}
+
+	content, err := os.ReadFile(filepath.Join(dir, manifestPath))
+	if err != nil {
+		return branch, fmt.Errorf("failed to read %s branch %s: %w", name, manifestPath, err)
+	}
+
+	manifest, err := bosh.NewManifestFromFile(content)
+	if err != nil {
+		return branch, fmt.Errorf("failed to unmarshal %s branch %s: %w", name, manifestPath, err)
+	}
+
+	if len(manifest.Stemcells) == 0 {
+		return branch, fmt.Errorf("%s branch %s has no stemcells", name, manifestPath)
+	}
+
+	branch.ManifestStemcells = manifest.Stemcells
+	for _, stemcell := range manifest.Stemcells {
+		branch.Pins = append(branch.Pins, Pin{
+			Key:      fmt.Sprintf("%s alias=%s", manifestPath, stemcell.Alias),
+			Source:   fmt.Sprintf("%s (%s)", manifestPath, stemcell.Alias),
+			Stemcell: stemcell,
+		})
+	}
+
+	aliasOS := map[string]string{}
+	for _, stemcell := range manifest.Stemcells {
+		aliasOS[stemcell.Alias] = stemcell.OS
+	}
+
+	var opsFiles []string
+	err = filepath.WalkDir(filepath.Join(dir, "operations"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".yml" {
+			opsFiles = append(opsFiles, path)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return branch, err
+	}
+
+	for _, path := range opsFiles {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return branch, err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return branch, err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel == compiledReleasesPath {
+			pins, err := compiledReleasePins(content)
+			if err != nil {
+				return branch, fmt.Errorf("failed to parse %s branch %s: %w", name, rel, err)
+			}
+			branch.Pins = append(branch.Pins, pins...)
+			continue
+		}
+
+		branch.Pins = append(branch.Pins, opsFilePins(rel, content, aliasOS)...)
+	}
+
+	return branch, nil
+}
+
+type op struct {
+	Type  string
+	Path  string
+	Value yaml.Node
+}
+
+// opsFilePins returns the stemcells that an ops file sets, either whole or
+// by their os and version fields. Files that are not a list of ops, e.g.
+// example vars files, have none.
+func opsFilePins(file string, content []byte, aliasOS map[string]string) []Pin {
+	var ops []op
+	if yaml.Unmarshal(content, &ops) != nil {
+		return nil
+	}
+
+	var (
+		pins    []Pin
+		byAlias = map[string]*bosh.Stemcell{}
+		aliases []string
+	)
+	stemcellFor := func(alias string) *bosh.Stemcell {
+		if _, ok := byAlias[alias]; !ok {
+			byAlias[alias] = &bosh.Stemcell{Alias: alias}
+			aliases = append(aliases, alias)
+		}
+		return byAlias[alias]
+	}
+
+	for _, o := range ops {
+		path, ok := strings.CutPrefix(o.Path, "/stemcells/")
+		if o.Type != "replace" || !ok {
+			continue
+		}
+
+		if path == "-" {
+			var stemcell bosh.Stemcell
+			if o.Value.Decode(&stemcell) == nil && stemcell.OS != "" {
+				pins = append(pins, Pin{
+					Key:      fmt.Sprintf("%s os=%s", file, stemcell.OS),
+					Source:   file,
+					Stemcell: stemcell,
+				})
+			}
+			continue
+		}
+
+		selector, field, _ := strings.Cut(path, "/")
+		alias, ok := strings.CutPrefix(strings.TrimSuffix(selector, "?"), "alias=")
+		if !ok {
+			continue
+		}
+
+		switch field {
+		case "":
+			var value bosh.Stemcell
+			if o.Value.Decode(&value) == nil {
+				stemcell := stemcellFor(alias)
+				stemcell.OS, stemcell.Version = value.OS, value.Version
+			}
+		case "os":
+			stemcellFor(alias).OS = o.Value.Value
+		case "version":
+			stemcellFor(alias).Version = o.Value.Value
+		}
+	}
+
+	for _, alias := range aliases {
+		stemcell := *byAlias[alias]
+		if stemcell.OS == "" {
+			stemcell.OS = aliasOS[alias]
+		}
+		if stemcell.Version == "" {
+			continue
+		}
+
+		pins = append(pins, Pin{
+			Key:      fmt.Sprintf("%s alias=%s", file, alias),
+			Source:   fmt.Sprintf("%s (%s)", file, alias),
+			Stemcell: stemcell,
+		})
+	}
+
+	return pins
+}
+
+// compiledReleasePins returns a pin per stemcell that use-compiled-releases.yml
+// was compiled against. A release that lists several versions of an OS in
+// exported_from counts towards the newest one.
+func compiledReleasePins(content []byte) ([]Pin, error) {
+	releases, err := bosh.ParseReleaseOps(content)
+	if err != nil {
+		return nil, err
+	}
+
+	byStemcell := map[bosh.Stemcell][]bosh.Release{}
+	var stemcells []bosh.Stemcell
+	for _, release := range releases {
+		for _, stemcell := range newestPerOS(release.CompiledStemcells()) {
+			if _, ok := byStemcell[stemcell]; !ok {
+				stemcells = append(stemcells, stemcell)
+			}
+			byStemcell[stemcell] = append(byStemcell[stemcell], release)
+		}
+	}
+
+	var pins []Pin
+	for _, stemcell := range stemcells {
+		pins = append(pins, Pin{
+			Key:      fmt.Sprintf("%s os=%s", compiledReleasesPath, stemcell.OS),
+			Source:   compiledReleasesPath,
+			Stemcell: stemcell,
+			Releases: byStemcell[stemcell],
+		})
+	}
+
+	return pins, nil
+}
+
+func newestPerOS(stemcells []bosh.Stemcell) []bosh.Stemcell {
+	var (
+		newest []bosh.Stemcell
+		index  = map[string]int{}
+	)
+	for _, stemcell := range stemcells {
+		stemcell.Alias = ""
+
+		i, ok := index[stemcell.OS]
+		if !ok {
+			index[stemcell.OS] = len(newest)
+			newest = append(newest, stemcell)
+			continue
+		}
+
+		if result, err := stemcell.CompareVersion(newest[i]); err == nil && result > 0 {
+			newest[i] = stemcell
+		}
+	}
+	return newest
+}
+
+// Row is a line of the report.
+type Row struct {
+	Branch   string
+	Source   string
+	Stemcell string
+	Status   string
+	Detail   string
+}
+
+// Report is the result of Check.
+type Report []Row
+
+// Failed reports whether any row is a regression or an inconsistency.
+func (r Report) Failed() bool {
+	for _, row := range r {
+		if row.Status != StatusOK {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the report as a table.
+func (r Report) String() string {
+	buf := new(bytes.Buffer)
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "BRANCH\tSOURCE\tSTEMCELL\tSTATUS\tDETAIL")
+	for _, row := range r {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", row.Branch, row.Source, row.Stemcell, row.Status, row.Detail)
+	}
+	w.Flush() //nolint:errcheck
+
+	return buf.String()
+}
+
+// Check reports every pin of every branch. Each branch must be consistent with
+// its own manifest, and the other branches must not be behind main.
+func Check(main Branch, others ...Branch) Report {
+	var report Report
+
+	for _, branch := range append([]Branch{main}, others...) {
+		for _, pin := range branch.Pins {
+			row := Row{
+				Branch:   branch.Name,
+				Source:   pin.Source,
+				Stemcell: fmt.Sprintf("%s/%s", pin.Stemcell.OS, pin.Stemcell.Version),
+				Status:   StatusOK,
+			}
+
+			if isFloating(pin.Stemcell.Version) {
+				row.Detail = "not pinned"
+			} else if detail := inconsistency(branch, pin); detail != "" {
+				row.Status, row.Detail = StatusInconsistent, detail
+			} else if branch.Name != main.Name {
+				detail, err := regression(main, pin)
+				if err != nil {
+					row.Status, row.Detail = StatusInconsistent, err.Error()
+				} else if detail != "" {
+					row.Status, row.Detail = StatusRegression, detail
+				}
+			}
+
+			report = append(report, row)
+		}
+
+		if branch.Name == main.Name {
+			continue
+		}
+
+		for _, pin := range main.Pins {
+			if strings.HasPrefix(pin.Key, manifestPath+" ") && !hasPin(branch, pin.Key) {
+				report = append(report, Row{
+					Branch:   branch.Name,
+					Source:   pin.Source,
+					Stemcell: "-",
+					Status:   StatusRegression,
+					Detail:   fmt.Sprintf("stemcell alias %q is missing; %s has %s/%s", pin.Stemcell.Alias, main.Name, pin.Stemcell.OS, pin.Stemcell.Version),
+				})
+			}
+		}
+	}
+
+	return report
+}
+
+// inconsistency explains why a pin disagrees with the manifest of its branch,
+// or returns "".
+func inconsistency(branch Branch, pin Pin) string {
+	if strings.HasPrefix(pin.Key, manifestPath+" ") {
+		return ""
+	}
+
+	if pin.Source == compiledReleasesPath {
+		var mismatched []string
+		for _, release := range pin.Releases {
+			if !slices.ContainsFunc(branch.ManifestStemcells, release.IsCompiledFor) {
+				mismatched = append(mismatched, release.Name)
+			}
+		}
+		if len(mismatched) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%s compiled against a stemcell the manifest does not declare (%s)",
+			strings.Join(mismatched, ", "), manifestStemcellNames(branch))
+	}
+
+	for _, stemcell := range branch.ManifestStemcells {
+		if stemcell.OS == pin.Stemcell.OS && stemcell.Version != pin.Stemcell.Version {
+			return fmt.Sprintf("manifest declares %s/%s", stemcell.OS, stemcell.Version)
+		}
+	}
+	return ""
+}
+
+// regression explains why a pin is behind the same pin on main, or returns
+// "". It returns an error when the pin cannot be ordered against main's, such
+// as when one is a FIPS stemcell and the other is not.
+func regression(main Branch, pin Pin) (string, error) {
+	var newest *bosh.Stemcell
+	for _, mainPin := range main.Pins {
+		if mainPin.Key != pin.Key || isFloating(mainPin.Stemcell.Version) {
+			continue
+		}
+
+		stemcell := mainPin.Stemcell
+		if newest == nil {
+			newest = &stemcell
+			continue
+		}
+		if result, err := compare(stemcell, *newest); err == nil && result > 0 {
+			newest = &stemcell
+		}
+	}
+
+	if newest == nil {
+		return "", nil
+	}
+
+	result, err := compare(pin.Stemcell, *newest)
+	if err != nil {
+		return "", fmt.Errorf("cannot compare with %s (%s/%s): %w", main.Name, newest.OS, newest.Version, err)
+	}
+	if result < 0 {
+		return fmt.Sprintf("behind %s (%s/%s)", main.Name, newest.OS, newest.Version), nil
+	}
+	return "", nil
+}
+
+// isFloating reports whether a version is resolved at deploy time rather
+// than pinned.
+func isFloating(version string) bool {
+	return version == "latest" || strings.HasPrefix(version, "((")
+}
+
+func compare(stemcell, base bosh.Stemcell) (int, error) {
+	if stemcell.OS != base.OS {
+		return bosh.CompareStemcellOS(stemcell.OS, base.OS)
+	}
+	return stemcell.CompareVersion(base)
+}
+
+func hasPin(branch Branch, key string) bool {
+	for _, pin := range branch.Pins {
+		if pin.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func manifestStemcellNames(branch Branch) string {
+	var names []string
+	for _, stemcell := range branch.ManifestStemcells {
+		names = append(names, fmt.Sprintf("%s/%s", stemcell.OS, stemcell.Version))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
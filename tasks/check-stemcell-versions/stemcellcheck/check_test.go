@@ -0,0 +1,237 @@
+package stemcellcheck_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/tasks/check-stemcell-versions/stemcellcheck"
+)
+
+var _ = Describe("Stemcellcheck", func() {
+	Describe("LoadBranch", func() {
+		var dir string
+
+		writeFile := func(path, content string) {
+			path = filepath.Join(dir, path)
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "cf-deployment-")
+			Expect(err).NotTo(HaveOccurred())
+
+			writeFile("cf-deployment.yml", `---
+stemcells:
+- alias: default
+  os: ubuntu-jammy
+  version: "1.10"
+`)
+			writeFile("operations/windows-cell.yml", `---
+- type: replace
+  path: /stemcells/-
+  value:
+    alias: windows2019
+    os: windows2019
+    version: "2019.80"
+`)
+			writeFile("operations/pin-jammy.yml", `---
+- type: replace
+  path: /stemcells/alias=default/version
+  value: "1.9"
+`)
+			writeFile("operations/example-vars-files/vars.yml", "key: value\n")
+			writeFile("operations/use-compiled-releases.yml", `---
+- type: replace
+  path: /releases/name=capi
+  value:
+    name: capi
+    version: 1.0.0
+    stemcell:
+      os: ubuntu-jammy
+      version: "1.10"
+- type: replace
+  path: /releases/name=uaa
+  value:
+    name: uaa
+    version: 2.0.0
+    exported_from:
+    - os: ubuntu-jammy
+      version: "1.9"
+    - os: ubuntu-jammy
+      version: "1.10"
+`)
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("collects every stemcell of the branch", func() {
+			branch, err := stemcellcheck.LoadBranch("main", dir)
+			Expect(err).NotTo(HaveOccurred())
+
+			var pins [][]string
+			for _, pin := range branch.Pins {
+				pins = append(pins, []string{pin.Key, pin.Stemcell.OS, pin.Stemcell.Version})
+			}
+			Expect(pins).To(Equal([][]string{
+				{"cf-deployment.yml alias=default", "ubuntu-jammy", "1.10"},
+				{"operations/pin-jammy.yml alias=default", "ubuntu-jammy", "1.9"},
+				{"operations/use-compiled-releases.yml os=ubuntu-jammy", "ubuntu-jammy", "1.10"},
+				{"operations/windows-cell.yml os=windows2019", "windows2019", "2019.80"},
+			}))
+			Expect(branch.Pins[2].Releases).To(HaveLen(2))
+		})
+
+		Context("when the manifest is missing", func() {
+			It("returns an error", func() {
+				_, err := stemcellcheck.LoadBranch("develop", filepath.Join(dir, "missing"))
+				Expect(err).To(MatchError(ContainSubstring("failed to read develop branch cf-deployment.yml")))
+			})
+		})
+	})
+
+	Describe("Check", func() {
+		var main, candidate stemcellcheck.Branch
+
+		manifestPin := func(alias, os, version string) stemcellcheck.Pin {
+			return stemcellcheck.Pin{
+				Key:      "cf-deployment.yml alias=" + alias,
+				Source:   "cf-deployment.yml (" + alias + ")",
+				Stemcell: bosh.Stemcell{Alias: alias, OS: os, Version: version},
+			}
+		}
+
+		compiledPin := func(os, version string, releases ...bosh.Release) stemcellcheck.Pin {
+			return stemcellcheck.Pin{
+				Key:      "operations/use-compiled-releases.yml os=" + os,
+				Source:   "operations/use-compiled-releases.yml",
+				Stemcell: bosh.Stemcell{OS: os, Version: version},
+				Releases: releases,
+			}
+		}
+
+		BeforeEach(func() {
+			main = stemcellcheck.Branch{
+				Name: "main",
+				ManifestStemcells: []bosh.Stemcell{
+					{Alias: "default", OS: "ubuntu-jammy", Version: "1.10"},
+					{Alias: "noble", OS: "ubuntu-noble", Version: "1.5"},
+				},
+			}
+			main.Pins = []stemcellcheck.Pin{
+				manifestPin("default", "ubuntu-jammy", "1.10"),
+				manifestPin("noble", "ubuntu-noble", "1.5"),
+				compiledPin("ubuntu-jammy", "1.10", bosh.Release{Name: "capi", Stemcell: bosh.Stemcell{OS: "ubuntu-jammy", Version: "1.10"}}),
+			}
+
+			candidate = stemcellcheck.Branch{
+				Name:              "release-candidate",
+				ManifestStemcells: main.ManifestStemcells,
+				Pins:              main.Pins,
+			}
+		})
+
+		It("passes when the branches agree", func() {
+			report := stemcellcheck.Check(main, candidate)
+			Expect(report.Failed()).To(BeFalse())
+			Expect(report).To(HaveLen(6))
+		})
+
+		It("reports aliases that are behind main", func() {
+			candidate.Pins = []stemcellcheck.Pin{
+				manifestPin("default", "ubuntu-jammy", "1.9"),
+				manifestPin("noble", "ubuntu-noble", "1.6"),
+			}
+
+			report := stemcellcheck.Check(main, candidate)
+			Expect(report.Failed()).To(BeTrue())
+			Expect(report[3:]).To(Equal(stemcellcheck.Report{
+				{Branch: "release-candidate", Source: "cf-deployment.yml (default)", Stemcell: "ubuntu-jammy/1.9", Status: stemcellcheck.StatusRegression, Detail: "behind main (ubuntu-jammy/1.10)"},
+				{Branch: "release-candidate", Source: "cf-deployment.yml (noble)", Stemcell: "ubuntu-noble/1.6", Status: stemcellcheck.StatusOK},
+			}))
+		})
+
+		It("does not stop at an OS change", func() {
+			candidate.Pins = []stemcellcheck.Pin{
+				manifestPin("default", "ubuntu-noble", "1.0"),
+				manifestPin("noble", "ubuntu-noble", "1.4"),
+			}
+
+			report := stemcellcheck.Check(main, candidate)
+			Expect(report[3].Status).To(Equal(stemcellcheck.StatusOK))
+			Expect(report[4].Status).To(Equal(stemcellcheck.StatusRegression))
+		})
+
+		It("reports pins on another stemcell line than main as inconsistent", func() {
+			candidate.Pins = []stemcellcheck.Pin{
+				manifestPin("default", "ubuntu-jammy-fips", "1.10"),
+				manifestPin("noble", "ubuntu-noble", "1.5"),
+			}
+
+			report := stemcellcheck.Check(main, candidate)
+			Expect(report.Failed()).To(BeTrue())
+			Expect(report[3]).To(Equal(stemcellcheck.Row{
+				Branch:   "release-candidate",
+				Source:   "cf-deployment.yml (default)",
+				Stemcell: "ubuntu-jammy-fips/1.10",
+				Status:   stemcellcheck.StatusInconsistent,
+				Detail:   `cannot compare with main (ubuntu-jammy/1.10): stemcell OS "ubuntu-jammy-fips" is not in the same line as "ubuntu-jammy"`,
+			}))
+			Expect(report[4].Status).To(Equal(stemcellcheck.StatusOK))
+		})
+
+		It("reports aliases missing from a branch", func() {
+			candidate.Pins = candidate.Pins[:1]
+
+			report := stemcellcheck.Check(main, candidate)
+			Expect(report[len(report)-1]).To(Equal(stemcellcheck.Row{
+				Branch:   "release-candidate",
+				Source:   "cf-deployment.yml (noble)",
+				Stemcell: "-",
+				Status:   stemcellcheck.StatusRegression,
+				Detail:   `stemcell alias "noble" is missing; main has ubuntu-noble/1.5`,
+			}))
+		})
+
+		It("reports compiled releases built against a stemcell the manifest does not declare", func() {
+			main.Pins[2] = compiledPin("ubuntu-jammy", "1.9", bosh.Release{Name: "capi", Stemcell: bosh.Stemcell{OS: "ubuntu-jammy", Version: "1.9"}})
+
+			report := stemcellcheck.Check(main)
+			Expect(report.Failed()).To(BeTrue())
+			Expect(report[2].Status).To(Equal(stemcellcheck.StatusInconsistent))
+			Expect(report[2].Detail).To(Equal("capi compiled against a stemcell the manifest does not declare (ubuntu-jammy/1.10, ubuntu-noble/1.5)"))
+		})
+
+		It("reports ops files that pin another version of a manifest stemcell", func() {
+			main.Pins = append(main.Pins, stemcellcheck.Pin{
+				Key:      "operations/pin.yml alias=default",
+				Source:   "operations/pin.yml (default)",
+				Stemcell: bosh.Stemcell{Alias: "default", OS: "ubuntu-jammy", Version: "1.9"},
+			}, stemcellcheck.Pin{
+				Key:      "operations/latest.yml alias=default",
+				Source:   "operations/latest.yml (default)",
+				Stemcell: bosh.Stemcell{Alias: "default", OS: "ubuntu-jammy", Version: "latest"},
+			})
+
+			report := stemcellcheck.Check(main)
+			Expect(report[3].Status).To(Equal(stemcellcheck.StatusInconsistent))
+			Expect(report[3].Detail).To(Equal("manifest declares ubuntu-jammy/1.10"))
+			Expect(report[4].Status).To(Equal(stemcellcheck.StatusOK))
+		})
+
+		It("renders a table", func() {
+			Expect(stemcellcheck.Check(main).String()).To(Equal(
+				"BRANCH  SOURCE                                STEMCELL           STATUS  DETAIL\n" +
+					"main    cf-deployment.yml (default)           ubuntu-jammy/1.10  ok      \n" +
+					"main    cf-deployment.yml (noble)             ubuntu-noble/1.5   ok      \n" +
+					"main    operations/use-compiled-releases.yml  ubuntu-jammy/1.10  ok      \n"))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package stemcellcheck_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestStemcellcheck(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Stemcellcheck Suite")
+}
@@ -0,0 +1,148 @@
+// Package compilation deploys a compilation deployment for each release,
+// several at a time, and reports how each deploy went. It can also select
+// only the releases that have no compiled release for a stemcell yet.
+package compilation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+)
+
+type Status string
+
+const (
+	StatusDeployed   Status = "deployed"
+	StatusFailed     Status = "failed"
+	StatusNotStarted Status = "not started"
+)
+
+// Result is the outcome of the compilation deployment of a release.
+type Result struct {
+	Release  bosh.Release
+	Status   Status
+	TaskID   int
+	Duration time.Duration
+	Err      error
+}
+
+// Report is the result of DeployAll, in the order of the releases.
+type Report []Result
+
+// Failed reports whether any release was not deployed.
+func (r Report) Failed() bool {
+	for _, result := range r {
+		if result.Status != StatusDeployed {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the report as a table.
+func (r Report) String() string {
+	buf := new(bytes.Buffer)
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "RELEASE\tSTATUS\tTASK\tDURATION\tERROR")
+	for _, result := range r {
+		task, duration, detail := "-", "-", ""
+		if result.TaskID != 0 {
+			task = strconv.Itoa(result.TaskID)
+		}
+		if result.Status != StatusNotStarted {
+			duration = result.Duration.Round(time.Second).String()
+		}
+		if result.Err != nil {
+			detail, _, _ = strings.Cut(result.Err.Error(), "\n")
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", result.Release.Name, result.Status, task, duration, detail)
+	}
+	w.Flush() //nolint:errcheck
+
+	return buf.String()
+}
+
+// Workers returns how many releases to deploy at a time: requested, bounded
+// by the compilation workers of the Director's cloud-config when it sets
+// them.
+func Workers(requested, compilationWorkers int) int {
+	workers := max(requested, 1)
+	if compilationWorkers > 0 {
+		workers = min(workers, compilationWorkers)
+	}
+	return workers
+}
+
+// Deployer deploys releases on a fixed number of workers. Unless KeepGoing
+// is set, no more deploys are started after one fails; the deploys already
+// running are left to finish.
+type Deployer struct {
+	Workers   int
+	KeepGoing bool
+	Deploy    func(context.Context, bosh.Release) (bosh.DeployResult, error)
+}
+
+// DeployAll deploys every release and reports the outcome of each. Once ctx
+// is done, no more deploys are started.
+func (d Deployer) DeployAll(ctx context.Context, releases []bosh.Release) Report {
+	workers := max(d.Workers, 1)
+
+	type indexedResult struct {
+		index  int
+		result Result
+	}
+
+	var failed atomic.Bool
+	jobs := make(chan int)
+	results := make(chan indexedResult)
+
+	for range workers {
+		go func() {
+			for i := range jobs {
+				result := Result{Release: releases[i], Status: StatusNotStarted}
+				if ctx.Err() == nil && (d.KeepGoing || !failed.Load()) {
+					result = d.deploy(ctx, releases[i])
+					if result.Status == StatusFailed {
+						failed.Store(true)
+					}
+				}
+				results <- indexedResult{index: i, result: result}
+			}
+		}()
+	}
+
+	go func() {
+		for i := range releases {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	report := make(Report, len(releases))
+	for range releases {
+		r := <-results
+		report[r.index] = r.result
+	}
+
+	return report
+}
+
+func (d Deployer) deploy(ctx context.Context, release bosh.Release) Result {
+	started := time.Now()
+	deployed, err := d.Deploy(ctx, release)
+
+	result := Result{Release: release, Status: StatusDeployed, TaskID: deployed.TaskID, Duration: time.Since(started), Err: err}
+	if err != nil {
+		result.Status = StatusFailed
+	}
+	return result
+}
@@ -0,0 +1,13 @@
+package compilation_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCompilation(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Compilation Suite")
+}
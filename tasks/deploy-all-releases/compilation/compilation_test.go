@@ -0,0 +1,131 @@
+package compilation_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/tasks/deploy-all-releases/compilation"
+)
+
+var _ = Describe("Compilation", func() {
+	var releases []bosh.Release
+
+	BeforeEach(func() {
+		releases = []bosh.Release{{Name: "release-a"}, {Name: "release-b"}, {Name: "release-c"}}
+	})
+
+	Describe("Workers", func() {
+		It("bounds the requested workers by the compilation workers", func() {
+			Expect(compilation.Workers(8, 3)).To(Equal(3))
+			Expect(compilation.Workers(2, 3)).To(Equal(2))
+		})
+
+		It("uses the requested workers when the cloud-config sets none", func() {
+			Expect(compilation.Workers(8, 0)).To(Equal(8))
+		})
+	})
+
+	Describe("DeployAll", func() {
+		It("runs no more deploys at a time than there are workers", func() {
+			var running, peak atomic.Int32
+			deployer := compilation.Deployer{
+				Workers: 2,
+				Deploy: func(context.Context, bosh.Release) (bosh.DeployResult, error) {
+					n := running.Add(1)
+					for {
+						p := peak.Load()
+						if n <= p || peak.CompareAndSwap(p, n) {
+							break
+						}
+					}
+					time.Sleep(20 * time.Millisecond)
+					running.Add(-1)
+					return bosh.DeployResult{TaskID: 1}, nil
+				},
+			}
+
+			report := deployer.DeployAll(context.Background(), releases)
+			Expect(report).To(HaveLen(3))
+			Expect(peak.Load()).To(Equal(int32(2)))
+			Expect(report.Failed()).To(BeFalse())
+		})
+
+		It("stops starting deploys after a failure", func() {
+			deployer := compilation.Deployer{
+				Workers: 1,
+				Deploy: func(_ context.Context, release bosh.Release) (bosh.DeployResult, error) {
+					if release.Name == "release-a" {
+						return bosh.DeployResult{}, errors.New("compilation failed")
+					}
+					return bosh.DeployResult{TaskID: 2}, nil
+				},
+			}
+
+			report := deployer.DeployAll(context.Background(), releases)
+			Expect(report.Failed()).To(BeTrue())
+			Expect(report[0].Status).To(Equal(compilation.StatusFailed))
+			Expect(report[0].Err).To(MatchError("compilation failed"))
+			Expect(report[1].Status).To(Equal(compilation.StatusNotStarted))
+			Expect(report[2].Status).To(Equal(compilation.StatusNotStarted))
+		})
+
+		It("keeps going after a failure when asked to", func() {
+			deployer := compilation.Deployer{
+				Workers:   1,
+				KeepGoing: true,
+				Deploy: func(_ context.Context, release bosh.Release) (bosh.DeployResult, error) {
+					if release.Name == "release-a" {
+						return bosh.DeployResult{}, errors.New("compilation failed")
+					}
+					return bosh.DeployResult{TaskID: 2}, nil
+				},
+			}
+
+			report := deployer.DeployAll(context.Background(), releases)
+			Expect(report.Failed()).To(BeTrue())
+			Expect(report[0].Status).To(Equal(compilation.StatusFailed))
+			Expect(report[1].Status).To(Equal(compilation.StatusDeployed))
+			Expect(report[1].TaskID).To(Equal(2))
+			Expect(report[2].Status).To(Equal(compilation.StatusDeployed))
+		})
+
+		It("starts no deploys once the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			deployer := compilation.Deployer{
+				Workers:   1,
+				KeepGoing: true,
+				Deploy: func(context.Context, bosh.Release) (bosh.DeployResult, error) {
+					cancel()
+					return bosh.DeployResult{}, context.Canceled
+				},
+			}
+
+			report := deployer.DeployAll(ctx, releases)
+			Expect(report[0].Status).To(Equal(compilation.StatusFailed))
+			Expect(report[1].Status).To(Equal(compilation.StatusNotStarted))
+			Expect(report[2].Status).To(Equal(compilation.StatusNotStarted))
+		})
+	})
+
+	Describe("Report", func() {
+		It("renders a status table", func() {
+			report := compilation.Report{
+				{Release: releases[0], Status: compilation.StatusDeployed, TaskID: 12, Duration: 65 * time.Second},
+				{Release: releases[1], Status: compilation.StatusFailed, Duration: 3 * time.Second, Err: errors.New("Error: compilation failed\nTask 13 error")},
+				{Release: releases[2], Status: compilation.StatusNotStarted},
+			}
+
+			Expect(report.String()).To(Equal(`RELEASE    STATUS       TASK  DURATION  ERROR
+release-a  deployed     12    1m5s      
+release-b  failed       -     3s        Error: compilation failed
+release-c  not started  -     -         
+`))
+		})
+	})
+})
@@ -0,0 +1,51 @@
+package compilation
+
+import (
+	"fmt"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+)
+
+// Select returns the releases that compiled, a compiled releases ops file,
+// has no entry for at the same version and compiled for stemcell, in the
+// order of releases, and the selection that led to them.
+func Select(releases, compiled []bosh.Release, stemcell bosh.Stemcell) ([]bosh.Release, bosh.Selection) {
+	selection := bosh.Selection{
+		StemcellOS:      stemcell.OS,
+		StemcellVersion: stemcell.Version,
+		Selected:        []bosh.SelectedRelease{},
+		Skipped:         []bosh.SelectedRelease{},
+	}
+
+	entries := map[string]bosh.Release{}
+	for _, release := range compiled {
+		entries[release.Name] = release
+	}
+
+	var selected []bosh.Release
+	for _, release := range releases {
+		entry, ok := entries[release.Name]
+
+		var reason string
+		switch {
+		case !ok:
+			reason = "no compiled release"
+		case entry.Version != release.Version:
+			reason = fmt.Sprintf("compiled release is version %s", entry.Version)
+		case !entry.IsCompiledFor(stemcell):
+			reason = fmt.Sprintf("no compiled release for %s/%s", stemcell.OS, stemcell.Version)
+		default:
+			selection.Skipped = append(selection.Skipped, bosh.SelectedRelease{
+				Name:    release.Name,
+				Version: release.Version,
+				Reason:  fmt.Sprintf("already compiled for %s/%s", stemcell.OS, stemcell.Version),
+			})
+			continue
+		}
+
+		selected = append(selected, release)
+		selection.Selected = append(selection.Selected, bosh.SelectedRelease{Name: release.Name, Version: release.Version, Reason: reason})
+	}
+
+	return selected, selection
+}
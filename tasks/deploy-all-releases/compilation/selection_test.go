@@ -0,0 +1,61 @@
+package compilation_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/tasks/deploy-all-releases/compilation"
+)
+
+var _ = Describe("Select", func() {
+	var (
+		releases []bosh.Release
+		stemcell bosh.Stemcell
+	)
+
+	BeforeEach(func() {
+		releases = []bosh.Release{
+			{Name: "release-a", Version: "1.0"},
+			{Name: "release-b", Version: "2.0"},
+			{Name: "release-c", Version: "3.0"},
+			{Name: "release-d", Version: "4.0"},
+		}
+		stemcell = bosh.Stemcell{OS: "ubuntu-jammy", Version: "1.2"}
+	})
+
+	It("selects the releases without a compiled release for the version and stemcell", func() {
+		compiled := []bosh.Release{
+			{Name: "release-a", Version: "1.0", Stemcell: bosh.Stemcell{OS: "ubuntu-jammy", Version: "1.2"}},
+			{Name: "release-b", Version: "1.9", Stemcell: bosh.Stemcell{OS: "ubuntu-jammy", Version: "1.2"}},
+			{Name: "release-c", Version: "3.0", Stemcell: bosh.Stemcell{OS: "ubuntu-jammy", Version: "1.1"}},
+		}
+
+		selected, selection := compilation.Select(releases, compiled, stemcell)
+		Expect(selected).To(Equal(releases[1:]))
+		Expect(selection).To(Equal(bosh.Selection{
+			StemcellOS:      "ubuntu-jammy",
+			StemcellVersion: "1.2",
+			Selected: []bosh.SelectedRelease{
+				{Name: "release-b", Version: "2.0", Reason: "compiled release is version 1.9"},
+				{Name: "release-c", Version: "3.0", Reason: "no compiled release for ubuntu-jammy/1.2"},
+				{Name: "release-d", Version: "4.0", Reason: "no compiled release"},
+			},
+			Skipped: []bosh.SelectedRelease{
+				{Name: "release-a", Version: "1.0", Reason: "already compiled for ubuntu-jammy/1.2"},
+			},
+		}))
+	})
+
+	It("skips releases exported from several stemcells including this one", func() {
+		compiled := []bosh.Release{{Name: "release-a", Version: "1.0", ExportedFrom: []bosh.Stemcell{
+			{OS: "ubuntu-jammy", Version: "1.1"},
+			{OS: "ubuntu-jammy", Version: "1.2"},
+		}}}
+
+		selected, selection := compilation.Select(releases[:1], compiled, stemcell)
+		Expect(selected).To(BeEmpty())
+		Expect(selection.Selected).To(BeEmpty())
+		Expect(selection.Skipped).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,18 @@
+package main_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+func TestDeployAllReleases(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DeployAllReleases Suite")
+}
+
+var _ = AfterSuite(func() {
+	gexec.CleanupBuildArtifacts()
+})
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
-	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/command"
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli"
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshconfig"
+	"github.com/cloudfoundry/runtime-ci/task-libs/taskevents"
+	"github.com/cloudfoundry/runtime-ci/tasks/deploy-all-releases/compilation"
 	"github.com/spf13/pflag"
 )
 
@@ -20,9 +27,21 @@ var (
 	cfDeploymentDir string
 	releaseListDir  string
 	stemcellDir     string
+	deployTimeout   time.Duration
+	workers         int
+	keepGoing       bool
+	missingOnly     bool
+	compiledOpsFile string
+	selectionOutput string
 )
 
 func init() {
+	pflag.DurationVar(&deployTimeout, "timeout", 0, "time allowed for each deploy, 0 for no limit")
+	pflag.IntVar(&workers, "workers", 1, "number of releases to deploy at the same time, at most the Director's compilation workers")
+	pflag.BoolVar(&keepGoing, "keep-going", false, "keep deploying the remaining releases after a deploy fails")
+	pflag.BoolVar(&missingOnly, "missing-only", false, "only deploy releases the compiled releases ops file has no entry for on the stemcell")
+	pflag.StringVar(&compiledOpsFile, "compiled-releases", "operations/use-compiled-releases.yml", "compiled releases ops file, relative to cf-deployment, for --missing-only")
+	pflag.StringVar(&selectionOutput, "selection-output", "", "file to write the selected and skipped releases to, for --missing-only")
 	pflag.Parse()
 	rootDir := pflag.Arg(0)
 
@@ -32,7 +51,17 @@ func init() {
 }
 
 func main() {
-	boshCLI := new(command.BoshCLI)
+	if workers < 1 || (selectionOutput != "" && !missingOnly) {
+		fmt.Println("usage: main.go [--workers n] [--keep-going] [--timeout duration] [--missing-only [--compiled-releases path] [--selection-output file]] root-dir")
+		os.Exit(1)
+	}
+
+	// Concourse sends SIGTERM when a build is aborted. Stopping the context
+	// stops bosh and cancels the running deploy tasks.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	boshCLI := new(boshcli.CLI)
 
 	releaseListPath := filepath.Join(releaseListDir, "releases.yml")
 	var fileToRead string
@@ -70,17 +99,85 @@ func main() {
 		os.Exit(1)
 	}
 
-	for _, release := range releases {
-		newManifest := bosh.Manifest{
-			Releases:  []bosh.Release{release},
-			Stemcells: []bosh.Stemcell{stemcell},
-			Name:      fmt.Sprintf("%s-compilation", release.Name),
+	if missingOnly {
+		opsFilePath := filepath.Join(cfDeploymentDir, compiledOpsFile)
+		opsFile, err := os.ReadFile(opsFilePath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
 
-		fmt.Printf("Deploying %s...\n", release.Name)
+		compiled, err := bosh.ParseReleaseOps(opsFile)
+		if err != nil {
+			fmt.Printf("failed to parse %s: %s\n", opsFilePath, err)
+			os.Exit(1)
+		}
 
-		if err := newManifest.Deploy(boshCLI); err != nil {
-			fmt.Println(err)
+		var selection bosh.Selection
+		releases, selection = compilation.Select(releases, compiled, stemcell)
+		for _, skipped := range selection.Skipped {
+			fmt.Printf("Skipping %s\n", skipped)
+		}
+		fmt.Printf("Selected %d of %d releases missing from %s\n", len(selection.Selected), len(manifest.Releases), compiledOpsFile)
+
+		if selectionOutput != "" {
+			err = bosh.WriteSelection(selectionOutput, selection)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
 		}
 	}
+
+	cloudConfig, err := boshcli.NewClient(boshCLI).WithContext(ctx).CloudConfig()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	config, err := boshconfig.Load(boshconfig.CloudConfig, cloudConfig)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	compilationWorkers, err := config.CompilationWorkers()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	deployer := compilation.Deployer{
+		Workers:   compilation.Workers(workers, compilationWorkers),
+		KeepGoing: keepGoing,
+		Deploy: func(ctx context.Context, release bosh.Release) (bosh.DeployResult, error) {
+			newManifest := bosh.Manifest{
+				Releases:  []bosh.Release{release},
+				Stemcells: []bosh.Stemcell{stemcell},
+				Name:      fmt.Sprintf("%s-compilation", release.Name),
+			}
+
+			fmt.Printf("Deploying %s...\n", release.Name)
+
+			// Deployments run in parallel, so each one prefixes its progress
+			// with its name.
+			progress := taskevents.NewReporter(os.Stdout, newManifest.Name)
+			result, err := newManifest.Deploy(ctx, boshCLI, bosh.DeployOptions{Timeout: deployTimeout, Progress: progress})
+			if err != nil {
+				fmt.Printf("Failed to deploy %s: %s\n", release.Name, err)
+				return result, err
+			}
+
+			fmt.Printf("Deployed %s in task %d (%s)\n", release.Name, result.TaskID, result.Duration)
+			return result, nil
+		},
+	}
+
+	fmt.Printf("Deploying %d releases, %d at a time...\n", len(releases), deployer.Workers)
+	report := deployer.DeployAll(ctx, releases)
+	fmt.Printf("\n%s", report)
+
+	if report.Failed() {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,201 @@
+package main_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/director/fakedirector"
+)
+
+var _ = Describe("deploy-all-releases", func() {
+	var (
+		fake    *fakedirector.Director
+		binDir  string
+		rootDir string
+		task    string
+	)
+
+	BeforeEach(func() {
+		var err error
+		task, err = gexec.Build("github.com/cloudfoundry/runtime-ci/tasks/deploy-all-releases")
+		Expect(err).NotTo(HaveOccurred())
+
+		binDir, err = os.MkdirTemp("", "bin-")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakedirector.InstallFakeBOSH(binDir)).To(Succeed())
+
+		rootDir, err = os.MkdirTemp("", "deploy-all-releases-")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.Mkdir(filepath.Join(rootDir, "cf-deployment"), 0777)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(rootDir, "cf-deployment", "cf-deployment.yml"), []byte(`---
+name: cf
+releases:
+- name: release-a
+  version: "1.0"
+- name: release-b
+  version: "2.0"
+stemcells:
+- alias: default
+  os: ubuntu-jammy
+  version: "1.2"
+`), 0644)).To(Succeed())
+
+		Expect(os.Mkdir(filepath.Join(rootDir, "stemcell"), 0777)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(rootDir, "stemcell", "version"), []byte("1.2"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(rootDir, "stemcell", "url"), []byte("https://example.com/bosh-stemcell-1.2-warden-boshlite-ubuntu-jammy-go_agent.tgz"), 0644)).To(Succeed())
+
+		fake = fakedirector.New().Start()
+		fake.UpdateCloudConfig("compilation:\n  workers: 5\n  network: default\n")
+		fake.UploadStemcell("bosh-warden-boshlite-ubuntu-jammy-go_agent", "ubuntu-jammy", "1.2")
+		fake.UploadRelease("release-a", "1.0")
+		fake.UploadRelease("release-b", "2.0")
+	})
+
+	AfterEach(func() {
+		fake.Close()
+		Expect(os.RemoveAll(binDir)).To(Succeed())
+		Expect(os.RemoveAll(rootDir)).To(Succeed())
+	})
+
+	run := func(args ...string) *gexec.Session {
+		cmd := exec.Command(task, append(args, rootDir)...)
+		cmd.Dir = rootDir
+		cmd.Env = append(os.Environ(), fake.Env()...)
+		cmd.Env = append(cmd.Env, "PATH="+binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, 30*time.Second).Should(gexec.Exit())
+		return session
+	}
+
+	It("deploys a compilation deployment per release", func() {
+		session := run("--workers", "2")
+		Expect(session.ExitCode()).To(Equal(0))
+		Expect(session.Out).To(gbytes.Say(`Deploying 2 releases, 2 at a time...`))
+		Expect(session.Out).To(gbytes.Say(`RELEASE\s+STATUS\s+TASK\s+DURATION\s+ERROR\n`))
+
+		Expect(fake.Deployments()).To(Equal([]string{"release-a-compilation", "release-b-compilation"}))
+		Expect(string(fake.Manifest("release-a-compilation"))).To(ContainSubstring("canaries: 1"))
+	})
+
+	It("prefixes the progress of each deployment with its name", func() {
+		session := run("--workers", "2")
+		Expect(session.ExitCode()).To(Equal(0))
+
+		output := string(session.Out.Contents())
+		Expect(output).To(MatchRegexp(`\[release-a-compilation\] \S+ \| Compiling packages \(1/1\): release-a-package/`))
+		Expect(output).To(MatchRegexp(`\[release-b-compilation\] \S+ \| Compiling packages \(1/1\): release-b-package/`))
+		Expect(output).To(ContainSubstring("[release-a-compilation] Stage timings:"))
+		Expect(output).To(ContainSubstring("[release-b-compilation] Stage timings:"))
+	})
+
+	It("deploys no more releases at a time than the Director has compilation workers", func() {
+		fake.UpdateCloudConfig("compilation:\n  workers: 1\n")
+
+		session := run("--workers", "8")
+		Expect(session.ExitCode()).To(Equal(0))
+		Expect(session.Out).To(gbytes.Say(`Deploying 2 releases, 1 at a time...`))
+	})
+
+	It("stops after a failed deploy and exits non-zero", func() {
+		fake.FailTasksTimes("create deployment", "compilation failed", 1)
+
+		session := run("--workers", "1")
+		Expect(session.ExitCode()).To(Equal(1))
+		Expect(session.Out).To(gbytes.Say(`release-a\s+failed\s+1\s+\S+\s+.*compilation failed`))
+		Expect(session.Out).To(gbytes.Say(`release-b\s+not started\s+-\s+-`))
+
+		Expect(fake.Deployments()).To(BeEmpty())
+	})
+
+	It("deploys the remaining releases after a failure with --keep-going", func() {
+		fake.FailTasksTimes("create deployment", "compilation failed", 1)
+
+		session := run("--workers", "1", "--keep-going")
+		Expect(session.ExitCode()).To(Equal(1))
+		Expect(session.Out).To(gbytes.Say(`release-a\s+failed`))
+		Expect(session.Out).To(gbytes.Say(`release-b\s+deployed\s+2\s`))
+
+		Expect(fake.Deployments()).To(Equal([]string{"release-b-compilation"}))
+	})
+
+	It("cancels deploys that run out of time", func() {
+		fake.HangTasks("create deployment")
+
+		session := run("--workers", "1", "--keep-going", "--timeout", "1s")
+		Expect(session.ExitCode()).To(Equal(1))
+		Expect(session.Out).To(gbytes.Say(`bosh deploy: timed out after 1s \(cancelled Director task 1\)`))
+		Expect(session.Out).To(gbytes.Say(`Deploying release-b...`))
+		Expect(session.Out).To(gbytes.Say(`bosh deploy: timed out after 1s \(cancelled Director task 2\)`))
+
+		for _, t := range fake.Tasks() {
+			Expect(t.State).To(Equal("cancelled"))
+		}
+		Expect(fake.Deployments()).To(BeEmpty())
+	})
+
+	It("only deploys the releases missing from the compiled releases ops file with --missing-only", func() {
+		Expect(os.Mkdir(filepath.Join(rootDir, "cf-deployment", "operations"), 0777)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(rootDir, "cf-deployment", "operations", "use-compiled-releases.yml"), []byte(`---
+- type: replace
+  path: /releases/name=release-a
+  value:
+    name: release-a
+    version: "1.0"
+    url: https://example.com/release-a-1.0-ubuntu-jammy-1.2.tgz
+    sha1: abc
+    stemcell:
+      os: ubuntu-jammy
+      version: "1.2"
+- type: replace
+  path: /releases/name=release-b
+  value:
+    name: release-b
+    version: "2.0"
+    url: https://example.com/release-b-2.0-ubuntu-jammy-1.1.tgz
+    sha1: def
+    stemcell:
+      os: ubuntu-jammy
+      version: "1.1"
+`), 0644)).To(Succeed())
+		selectionPath := filepath.Join(rootDir, "selection.json")
+
+		session := run("--missing-only", "--selection-output", selectionPath)
+		Expect(session.ExitCode()).To(Equal(0))
+		Expect(session.Out).To(gbytes.Say(`Skipping release-a/1.0: already compiled for ubuntu-jammy/1.2`))
+		Expect(session.Out).To(gbytes.Say(`Selected 1 of 2 releases missing from operations/use-compiled-releases.yml`))
+		Expect(session.Out).To(gbytes.Say(`Deploying 1 releases, 1 at a time...`))
+
+		Expect(fake.Deployments()).To(Equal([]string{"release-b-compilation"}))
+
+		selection, err := os.ReadFile(selectionPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selection).To(MatchJSON(`{
+  "stemcell_os": "ubuntu-jammy",
+  "stemcell_version": "1.2",
+  "selected": [{"name": "release-b", "version": "2.0", "reason": "no compiled release for ubuntu-jammy/1.2"}],
+  "skipped": [{"name": "release-a", "version": "1.0", "reason": "already compiled for ubuntu-jammy/1.2"}]
+}`))
+	})
+
+	It("rejects --selection-output without --missing-only", func() {
+		session := run("--selection-output", filepath.Join(rootDir, "selection.json"))
+		Expect(session.ExitCode()).To(Equal(1))
+		Expect(session.Out).To(gbytes.Say("usage:"))
+	})
+
+	It("rejects an invalid number of workers", func() {
+		session := run("--workers", "0")
+		Expect(session.ExitCode()).To(Equal(1))
+		Expect(session.Out).To(gbytes.Say("usage:"))
+	})
+})
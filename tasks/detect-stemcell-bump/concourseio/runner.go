@@ -1,6 +1,8 @@
 package concourseio
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,66 +10,102 @@ import (
 	"strings"
 
 	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/task-libs/concourse"
 )
 
+// additionalStemcellInputs are stemcells of other OS lines, e.g. while a
+// manifest has aliases for two OS lines.
+var additionalStemcellInputs = []string{"additional-stemcell-1", "additional-stemcell-2", "additional-stemcell-3"}
+
+// Task declares the inputs and outputs that detect-stemcell-bump expects.
+var Task = concourse.Task{
+	Inputs:         []string{"cf-deployment", "stemcell"},
+	OptionalInputs: additionalStemcellInputs,
+	Outputs:        []string{"stemcell-bump-type"},
+}
+
 type Runner struct {
-	stemcell         bosh.Stemcell
-	manifestStemcell bosh.Stemcell
-	bumpType         string
+	stemcells         []bosh.Stemcell
+	manifestStemcells []bosh.Stemcell
+	bumps             []bosh.StemcellBump
 
 	In  Inputs
 	Out Outputs
 }
 
 type Inputs struct {
-	cfDeploymentDir string
-	stemcellDir     string
+	cfDeploymentDir        string
+	stemcellDir            string
+	additionalStemcellDirs []string
 }
 
 type Outputs struct {
 	bumpTypeDir string
 }
 
+// Result is written to stemcell-bump-type/result.json.
+type Result struct {
+	BumpType  string              `json:"bump_type"`
+	Stemcells []bosh.StemcellBump `json:"stemcells"`
+}
+
 func NewRunner(buildDir string) (Runner, error) {
-	inputs, err := setupInputs(buildDir)
+	dirs, err := Task.Setup(buildDir)
 	if err != nil {
 		return Runner{}, err
 	}
 
-	outputs, err := setupOutputs(buildDir)
-	if err != nil {
-		return Runner{}, err
+	var additionalStemcellDirs []string
+	for _, input := range additionalStemcellInputs {
+		if dirs.Has(input) {
+			additionalStemcellDirs = append(additionalStemcellDirs, dirs.Dir(input))
+		}
 	}
 
-	return Runner{In: inputs, Out: outputs}, nil
+	return Runner{
+		In: Inputs{
+			cfDeploymentDir:        dirs.Dir("cf-deployment"),
+			stemcellDir:            dirs.Dir("stemcell"),
+			additionalStemcellDirs: additionalStemcellDirs,
+		},
+		Out: Outputs{
+			bumpTypeDir: dirs.Dir("stemcell-bump-type"),
+		},
+	}, nil
 }
 
+// ReadStemcell reads the stemcell input and any additional stemcell inputs.
 func (r *Runner) ReadStemcell() error {
-	var err error
-
-	fmt.Printf("Reading stemcell from %s...\n", r.In.stemcellDir)
-	r.stemcell.Version, err = readFile(filepath.Join(r.In.stemcellDir, "version"))
-	if err != nil {
-		return err
+	r.stemcells = nil
+	for _, dir := range append([]string{r.In.stemcellDir}, r.In.additionalStemcellDirs...) {
+		fmt.Printf("Reading stemcell from %s...\n", dir)
+		version, err := concourse.ReadVersion(dir)
+		if err != nil {
+			return err
+		}
+
+		url, err := concourse.ReadURL(dir)
+		if err != nil {
+			return err
+		}
+
+		stemcellOS, err := parseOSfromURL(url)
+		if err != nil {
+			return err
+		}
+
+		stemcell := bosh.Stemcell{OS: stemcellOS, Version: version}
+
+		fmt.Printf("Found Stemcell {OS: %q, Version: %q}\n", stemcell.OS, stemcell.Version)
+		r.stemcells = append(r.stemcells, stemcell)
 	}
 
-	url, err := readFile(filepath.Join(r.In.stemcellDir, "url"))
-	if err != nil {
-		return err
-	}
-
-	r.stemcell.OS, err = parseOSfromURL(url)
-	if err != nil {
-		return err
-	}
-
-	fmt.Printf("Found Stemcell {OS: %q, Version: %q}\n", r.stemcell.OS, r.stemcell.Version)
 	return nil
 }
 
 func (r *Runner) ReadCFDeploymentStemcell() error {
-	fmt.Printf("Reading manifest stemcell from %s...\n", r.In.stemcellDir)
-	contents, err := readFile(filepath.Join(r.In.cfDeploymentDir, "cf-deployment.yml"))
+	fmt.Printf("Reading manifest stemcells from %s...\n", r.In.cfDeploymentDir)
+	contents, err := concourse.ReadFile(r.In.cfDeploymentDir, "cf-deployment.yml")
 	if err != nil {
 		return err
 	}
@@ -77,75 +115,65 @@ func (r *Runner) ReadCFDeploymentStemcell() error {
 		return err
 	}
 
-	r.manifestStemcell = manifest.Stemcells[0]
+	if len(manifest.Stemcells) == 0 {
+		return fmt.Errorf("cf-deployment.yml has no stemcells")
+	}
 
-	fmt.Printf("Found manifest Stemcell {OS: %q, Version: %q}\n", r.manifestStemcell.OS, r.manifestStemcell.Version)
+	r.manifestStemcells = manifest.Stemcells
+	for _, stemcell := range r.manifestStemcells {
+		fmt.Printf("Found manifest Stemcell {Alias: %q, OS: %q, Version: %q}\n", stemcell.Alias, stemcell.OS, stemcell.Version)
+	}
 	return nil
 }
 
+// DetectStemcellBump evaluates every manifest stemcell alias against the new
+// stemcells. An alias without a newer stemcell is not an error; its bump type
+// is "none".
 func (r *Runner) DetectStemcellBump() error {
-	fmt.Printf("Detecting stemcell bump from manifest stemcell %s to new stemcell %s\n", r.manifestStemcell, r.stemcell)
-	bumpType, err := r.stemcell.DetectBumpTypeFrom(r.manifestStemcell)
+	bumps, err := bosh.StemcellBumps(r.manifestStemcells, r.stemcells)
 	if err != nil {
 		return err
 	}
 
-	r.bumpType = bumpType
-	fmt.Printf("Found bump type %q\n", r.bumpType)
-	return nil
-}
-
-func (r *Runner) WriteStemcellBumpTypeToFile() error {
-	bumpTypeFilePath := filepath.Join(r.Out.bumpTypeDir, "result")
-	fmt.Printf("Writing bump type %q to %s...\n", r.bumpType, bumpTypeFilePath)
-	err := os.WriteFile(bumpTypeFilePath, []byte(r.bumpType), 0644)
-	if err != nil {
-		return err
+	r.bumps = bumps
+	for _, bump := range r.bumps {
+		fmt.Printf("Found bump type %q for stemcell alias %q\n", bump.BumpType, bump.Alias)
 	}
 	return nil
 }
 
-func readFile(path string) (string, error) {
-	content, err := os.ReadFile(path)
-	if os.IsNotExist(err) {
-		pathDir := filepath.Base(filepath.Dir(path))
-		return "", fmt.Errorf("missing files: '%s'", filepath.Join(pathDir, filepath.Base(path)))
-	}
-
-	return string(content), err
-}
-
-func setupInputs(buildDir string) (Inputs, error) {
-	cfDeploymentDir, err := buildSubDir(buildDir, "cf-deployment")
-	if err != nil {
-		return Inputs{}, err
+// WriteStemcellBumpTypeToFile writes the bump of every alias to result.json
+// and the largest bump type to result. result keeps its original contract of
+// only ever holding "major" or "minor", so a patch bump is written to it as
+// minor. When no alias has a forward bump, result is not written and an
+// error is returned, as the task did before it evaluated every alias.
+func (r *Runner) WriteStemcellBumpTypeToFile() error {
+	result := Result{BumpType: bosh.LargestBump(r.bumps), Stemcells: r.bumps}
+	if result.Stemcells == nil {
+		result.Stemcells = []bosh.StemcellBump{}
 	}
 
-	stemcellDir, err := buildSubDir(buildDir, "stemcell")
+	content, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
-		return Inputs{}, err
+		return err
 	}
 
-	return Inputs{cfDeploymentDir, stemcellDir}, nil
-}
-
-func setupOutputs(buildDir string) (Outputs, error) {
-	stemcellBumpTypeDir, err := buildSubDir(buildDir, "stemcell-bump-type")
+	err = os.WriteFile(filepath.Join(r.Out.bumpTypeDir, "result.json"), append(content, '\n'), 0644)
 	if err != nil {
-		return Outputs{}, err
+		return err
 	}
 
-	return Outputs{stemcellBumpTypeDir}, nil
-}
-
-func buildSubDir(buildDir, subDir string) (string, error) {
-	dir := filepath.Join(buildDir, subDir)
-	_, err := os.Stat(dir)
-	if os.IsNotExist(err) {
-		return "", fmt.Errorf("missing sub directory '%s' in build directory '%s'", subDir, buildDir)
+	bumpType := result.BumpType
+	switch bumpType {
+	case bosh.BumpNone:
+		return errors.New("no stemcell alias has a forward bump")
+	case bosh.BumpPatch:
+		bumpType = bosh.BumpMinor
 	}
 
-	return dir, nil
+	bumpTypeFilePath := filepath.Join(r.Out.bumpTypeDir, "result")
+	fmt.Printf("Writing bump type %q to %s...\n", bumpType, bumpTypeFilePath)
+	return os.WriteFile(bumpTypeFilePath, []byte(bumpType), 0644)
 }
 
 func parseOSfromURL(url string) (string, error) {
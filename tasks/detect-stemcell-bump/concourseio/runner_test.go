@@ -26,6 +26,12 @@ var _ = Describe("Runner", func() {
 		Expect(os.RemoveAll(buildDir)).To(Succeed())
 	})
 
+	Describe("Task", func() {
+		It("matches task.yml", func() {
+			Expect(Task.Validate("../task.yml")).To(Succeed())
+		})
+	})
+
 	Describe("NewRunner", func() {
 		var (
 			actualRunner Runner
@@ -137,7 +143,7 @@ var _ = Describe("Runner", func() {
 			It("sets the stemcell OS and version", func() {
 				Expect(actualErr).ToNot(HaveOccurred())
 
-				Expect(runner.stemcell).To(Equal(bosh.Stemcell{OS: "ubuntu-some-os", Version: "some-version"}))
+				Expect(runner.stemcells).To(Equal([]bosh.Stemcell{{OS: "ubuntu-some-os", Version: "some-version"}}))
 			})
 		})
 
@@ -207,7 +213,7 @@ var _ = Describe("Runner", func() {
 			It("sets the stemcell OS and version", func() {
 				Expect(actualErr).ToNot(HaveOccurred())
 
-				Expect(runner.manifestStemcell).To(Equal(bosh.Stemcell{OS: "ubuntu-some-os", Version: "some-version-in-manifest"}))
+				Expect(runner.manifestStemcells).To(Equal([]bosh.Stemcell{{OS: "ubuntu-some-os", Version: "some-version-in-manifest"}}))
 			})
 		})
 
@@ -247,23 +253,71 @@ var _ = Describe("Runner", func() {
 
 		Context("when the new stemcell is a forward bump", func() {
 			BeforeEach(func() {
-				runner.manifestStemcell = bosh.Stemcell{OS: "some-ubuntu", Version: "456.40"}
-				runner.stemcell = bosh.Stemcell{OS: "some-ubuntu", Version: "457.0"}
+				runner.manifestStemcells = []bosh.Stemcell{{Alias: "default", OS: "some-ubuntu", Version: "456.40"}}
+				runner.stemcells = []bosh.Stemcell{{OS: "some-ubuntu", Version: "457.0"}}
 			})
 
 			It("returns the appropriate bump type", func() {
 				Expect(actualErr).ToNot(HaveOccurred())
-				Expect(runner.bumpType).To(Equal("major"))
+				Expect(runner.bumps).To(HaveLen(1))
+				Expect(runner.bumps[0].BumpType).To(Equal("major"))
+				Expect(runner.bumps[0].Alias).To(Equal("default"))
 			})
 		})
 
 		Context("when the new stemcell is NOT a forward bump", func() {
 			BeforeEach(func() {
-				runner.manifestStemcell = bosh.Stemcell{OS: "some-ubuntu", Version: "500.0"}
-				runner.stemcell = bosh.Stemcell{OS: "some-ubuntu", Version: "400.0"}
+				runner.manifestStemcells = []bosh.Stemcell{{Alias: "default", OS: "some-ubuntu", Version: "500.0"}}
+				runner.stemcells = []bosh.Stemcell{{OS: "some-ubuntu", Version: "400.0"}}
+			})
+
+			It("reports no bump", func() {
+				Expect(actualErr).ToNot(HaveOccurred())
+				Expect(runner.bumps[0].BumpType).To(Equal("none"))
+			})
+		})
+
+		Context("when there are several aliases and stemcells", func() {
+			BeforeEach(func() {
+				runner.manifestStemcells = []bosh.Stemcell{
+					{Alias: "default", OS: "ubuntu-jammy", Version: "1.10"},
+					{Alias: "noble", OS: "ubuntu-noble", Version: "1.5"},
+				}
+				runner.stemcells = []bosh.Stemcell{
+					{OS: "ubuntu-jammy", Version: "1.10.1"},
+					{OS: "ubuntu-noble", Version: "2.0"},
+				}
+			})
+
+			It("evaluates every alias", func() {
+				Expect(actualErr).ToNot(HaveOccurred())
+				Expect(runner.bumps).To(HaveLen(2))
+				Expect(runner.bumps[0].BumpType).To(Equal("patch"))
+				Expect(runner.bumps[1].BumpType).To(Equal("major"))
+			})
+		})
+
+		Context("when two stemcell inputs are for the same OS", func() {
+			BeforeEach(func() {
+				runner.manifestStemcells = []bosh.Stemcell{{Alias: "default", OS: "ubuntu-jammy", Version: "1.10"}}
+				runner.stemcells = []bosh.Stemcell{
+					{OS: "ubuntu-jammy", Version: "1.11"},
+					{OS: "ubuntu-jammy", Version: "2.0"},
+				}
+			})
+
+			It("returns an error", func() {
+				Expect(actualErr).To(MatchError("more than one new stemcell is for ubuntu-jammy"))
+			})
+		})
+
+		Context("when a stemcell version cannot be parsed", func() {
+			BeforeEach(func() {
+				runner.manifestStemcells = []bosh.Stemcell{{Alias: "default", OS: "some-ubuntu", Version: "500.0"}}
+				runner.stemcells = []bosh.Stemcell{{OS: "some-ubuntu", Version: "latest"}}
 			})
 
-			It("returns a non-forward bump error", func() {
+			It("returns an error", func() {
 				Expect(actualErr).To(HaveOccurred())
 			})
 		})
@@ -283,11 +337,14 @@ var _ = Describe("Runner", func() {
 		BeforeEach(func() {
 			expectedBumpTypeDir = filepath.Join(buildDir, "stemcell-bump-type")
 			Expect(os.Mkdir(expectedBumpTypeDir, 0777)).To(Succeed())
-			runner.Out = Outputs{expectedBumpTypeDir}
+			runner = Runner{Out: Outputs{expectedBumpTypeDir}}
 		})
 
-		It("writes the bump type to the `bump-type` file", func() {
-			runner.bumpType = "major"
+		It("writes the largest bump type to the `result` file", func() {
+			runner.bumps = []bosh.StemcellBump{
+				{Alias: "default", BumpType: "minor"},
+				{Alias: "noble", BumpType: "major"},
+			}
 			actualErr = runner.WriteStemcellBumpTypeToFile()
 			Expect(actualErr).ToNot(HaveOccurred())
 
@@ -296,5 +353,58 @@ var _ = Describe("Runner", func() {
 
 			Expect(string(actualResultContent)).To(Equal("major"))
 		})
+
+		It("writes every bump to the `result.json` file", func() {
+			runner.bumps = []bosh.StemcellBump{{
+				Alias:    "default",
+				OS:       "ubuntu-jammy",
+				Old:      bosh.StemcellVersion{OS: "ubuntu-jammy", Version: "1.10"},
+				New:      &bosh.StemcellVersion{OS: "ubuntu-jammy", Version: "1.11"},
+				BumpType: "minor",
+			}}
+			actualErr = runner.WriteStemcellBumpTypeToFile()
+			Expect(actualErr).ToNot(HaveOccurred())
+
+			actualResultContent, err := os.ReadFile(filepath.Join(expectedBumpTypeDir, "result.json"))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(actualResultContent).To(MatchJSON(`{
+				"bump_type": "minor",
+				"stemcells": [{
+					"alias": "default",
+					"os": "ubuntu-jammy",
+					"old": {"os": "ubuntu-jammy", "version": "1.10"},
+					"new": {"os": "ubuntu-jammy", "version": "1.11"},
+					"bump_type": "minor"
+				}]
+			}`))
+		})
+
+		It("writes a patch bump to the `result` file as minor", func() {
+			runner.bumps = []bosh.StemcellBump{{Alias: "default", BumpType: "patch"}}
+			actualErr = runner.WriteStemcellBumpTypeToFile()
+			Expect(actualErr).ToNot(HaveOccurred())
+
+			actualResultContent, err := os.ReadFile(filepath.Join(expectedBumpTypeDir, "result"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(actualResultContent)).To(Equal("minor"))
+
+			actualResultContent, err = os.ReadFile(filepath.Join(expectedBumpTypeDir, "result.json"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(actualResultContent).To(ContainSubstring(`"bump_type": "patch"`))
+		})
+
+		Context("when there are no bumps", func() {
+			It("writes none to `result.json` only and returns an error", func() {
+				Expect(actualErr).To(MatchError("no stemcell alias has a forward bump"))
+
+				_, err := os.Stat(filepath.Join(expectedBumpTypeDir, "result"))
+				Expect(err).To(MatchError(os.ErrNotExist))
+
+				actualResultContent, err := os.ReadFile(filepath.Join(expectedBumpTypeDir, "result.json"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(actualResultContent).To(MatchJSON(`{"bump_type": "none", "stemcells": []}`))
+			})
+		})
 	})
 })
@@ -1,103 +1,193 @@
 package deployment
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
+	"gopkg.in/yaml.v3"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli"
+	"github.com/cloudfoundry/runtime-ci/task-libs/taskevents"
 	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/stemcell"
 )
 
 // Deployment is a bosh json representation of a bosh deployment
 type Deployment struct {
-	Name     string
-	Releases []Release
-	Stemcell stemcell.Stemcell
+	Name      string
+	Releases  []Release
+	Stemcells []stemcell.Stemcell
 }
 
 // Release is a bosh json representation of a bosh release
 type Release struct {
 	Name    string
 	Version string
+
+	// Stemcells are the stemcells of the instances that use the release.
+	Stemcells []stemcell.Stemcell
 }
 
 func (r Release) String() string {
 	return fmt.Sprint(r.Name, "/", r.Version)
 }
 
-//go:generate counterfeiter BoshCLI
-
-type BoshCLI interface {
-	Cmd(name string, args ...string) (io.Reader, error)
-}
-
-func List(boshCLI BoshCLI, stemcells []stemcell.Stemcell) ([]Deployment, error) {
+// List returns the deployments on the Director with the releases that match
+// filter.
+func List(ctx context.Context, boshCLI boshcli.BoshCLI, stemcells []stemcell.Stemcell, filter Filter) ([]Deployment, error) {
 	fmt.Println("Generating list of deployments...")
-	r, err := boshCLI.Cmd("deployments", "--json")
+	client := boshcli.NewClient(boshCLI).WithContext(ctx)
+	boshDeployments, err := client.Deployments()
 	if err != nil {
 		return nil, err
 	}
 
-	return parseDeploymentsOutput(r, stemcells)
+	return parseDeployments(boshDeployments, stemcells, filter, client.Manifest)
 }
 
-func parseDeploymentsOutput(r io.Reader, stemcells []stemcell.Stemcell) ([]Deployment, error) {
-	var output struct {
-		Tables []struct {
-			Rows []struct {
-				Name      string
-				Releases  string `json:"release_s"`
-				Stemcells string `json:"stemcell_s"`
-			}
+// parseDeployments resolves the releases and stemcells of each deployment.
+// manifest is only called for deployments with more than one stemcell, to
+// find which stemcell the instances using each release run on.
+func parseDeployments(boshDeployments []boshcli.Deployment, stemcells []stemcell.Stemcell, filter Filter, manifest func(string) ([]byte, error)) ([]Deployment, error) {
+	var outputDeployments []Deployment
+	for _, boshDeployment := range boshDeployments {
+		outputDeployment, err := parseDeployment(boshDeployment, stemcells, filter, manifest)
+		if err != nil {
+			return nil, fmt.Errorf("deployment %s: %w", boshDeployment.Name, err)
 		}
+
+		outputDeployments = append(outputDeployments, outputDeployment)
 	}
 
-	err := json.NewDecoder(r).Decode(&output)
-	if err != nil {
-		return nil, err
+	return outputDeployments, nil
+}
+
+func parseDeployment(boshDeployment boshcli.Deployment, stemcells []stemcell.Stemcell, filter Filter, manifest func(string) ([]byte, error)) (Deployment, error) {
+	outputDeployment := Deployment{Name: boshDeployment.Name}
+
+	for _, cell := range boshDeployment.Stemcells {
+		name, version, err := splitNameVersion(cell)
+		if err != nil {
+			return Deployment{}, fmt.Errorf("malformed stemcell: %w", err)
+		}
+
+		// lookup stemcell OS from list
+		os, err := getStemcellOS(name, stemcells)
+		if err != nil {
+			return Deployment{}, err
+		}
+
+		outputDeployment.Stemcells = append(outputDeployment.Stemcells, stemcell.Stemcell{Name: name, OS: os, Version: version})
 	}
 
-	var outputDeployments []Deployment
-	for _, table := range output.Tables {
-		for _, row := range table.Rows {
-			deploymentStemcells := strings.Split(row.Stemcells, "\n")
+	deploymentReleases := []Release{}
+	for _, cell := range boshDeployment.Releases {
+		name, version, err := splitNameVersion(cell)
+		if err != nil {
+			return Deployment{}, fmt.Errorf("malformed release: %w", err)
+		}
 
-			if len(deploymentStemcells) != 1 {
-				panic("only allows 1 stemcell")
-			}
+		if filter.Match(name) {
+			deploymentReleases = append(deploymentReleases, Release{Name: name, Version: version})
+		}
+	}
 
-			stemcellInfo := strings.Split(deploymentStemcells[0], "/")
+	if len(deploymentReleases) > 0 {
+		var usage map[string][]stemcell.Stemcell
+		switch len(outputDeployment.Stemcells) {
+		case 0:
+			return Deployment{}, fmt.Errorf("no stemcells to export releases against")
+		case 1:
+		default:
+			content, err := manifest(boshDeployment.Name)
+			if err != nil {
+				return Deployment{}, err
+			}
 
-			// lookup stemcell OS from list
-			os, err := getStemcellOS(stemcellInfo[0], stemcells)
+			usage, err = releaseStemcells(content, outputDeployment.Stemcells)
 			if err != nil {
-				return nil, err
+				return Deployment{}, err
 			}
+		}
 
-			releases := strings.Split(row.Releases, "\n")
-			deploymentReleases := []Release{}
+		for i, release := range deploymentReleases {
+			// Releases used only by addons, or by no instance group, are
+			// exported against every stemcell of the deployment.
+			deploymentReleases[i].Stemcells = usage[release.Name]
+			if len(deploymentReleases[i].Stemcells) == 0 {
+				deploymentReleases[i].Stemcells = outputDeployment.Stemcells
+			}
+		}
+	}
 
-			for _, release := range releases {
-				releaseInfo := strings.Split(release, "/")
+	outputDeployment.Releases = deploymentReleases
+	return outputDeployment, nil
+}
 
-				if releaseInfo[0] != "bosh-dns" {
-					deploymentReleases = append(deploymentReleases, Release{Name: releaseInfo[0], Version: releaseInfo[1]})
-				}
+// releaseStemcells returns the stemcells each release's instance groups run
+// on, in the order the instance groups appear in the manifest.
+func releaseStemcells(content []byte, deployed []stemcell.Stemcell) (map[string][]stemcell.Stemcell, error) {
+	var manifest struct {
+		Stemcells []struct {
+			Alias   string
+			Name    string
+			OS      string
+			Version string
+		}
+		InstanceGroups []struct {
+			Name     string
+			Stemcell string
+			Jobs     []struct {
+				Release string
 			}
+		} `yaml:"instance_groups"`
+	}
+	err := yaml.Unmarshal(content, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
 
-			outputDeployments = append(outputDeployments, Deployment{
-				Name:     row.Name,
-				Releases: deploymentReleases,
-				Stemcell: stemcell.Stemcell{
-					OS:      os,
-					Version: stemcellInfo[1],
-				},
-			})
+	aliases := map[string]stemcell.Stemcell{}
+	for _, manifestStemcell := range manifest.Stemcells {
+		i := slices.IndexFunc(deployed, func(s stemcell.Stemcell) bool {
+			return (s.OS == manifestStemcell.OS || s.Name == manifestStemcell.Name) &&
+				(s.Version == manifestStemcell.Version || manifestStemcell.Version == "latest")
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("manifest stemcell %s is not deployed", manifestStemcell.Alias)
 		}
+		aliases[manifestStemcell.Alias] = deployed[i]
 	}
 
-	return outputDeployments, nil
+	usage := map[string][]stemcell.Stemcell{}
+	for _, instanceGroup := range manifest.InstanceGroups {
+		instanceStemcell, ok := aliases[instanceGroup.Stemcell]
+		if !ok {
+			return nil, fmt.Errorf("instance group %s uses unknown stemcell alias %q", instanceGroup.Name, instanceGroup.Stemcell)
+		}
+
+		for _, job := range instanceGroup.Jobs {
+			if !slices.Contains(usage[job.Release], instanceStemcell) {
+				usage[job.Release] = append(usage[job.Release], instanceStemcell)
+			}
+		}
+	}
+
+	return usage, nil
+}
+
+// splitNameVersion splits a "name/version" cell of `bosh deployments`.
+func splitNameVersion(cell string) (string, string, error) {
+	name, version, ok := strings.Cut(cell, "/")
+	if !ok || name == "" || version == "" {
+		return "", "", fmt.Errorf("expected name/version, got %q", cell)
+	}
+	return name, version, nil
 }
 
 func getStemcellOS(stemcellName string, stemcells []stemcell.Stemcell) (string, error) {
@@ -109,13 +199,84 @@ func getStemcellOS(stemcellName string, stemcells []stemcell.Stemcell) (string,
 	return "", fmt.Errorf("no matching stemcell name for %s", stemcellName)
 }
 
-func ExportRelease(boshCLI BoshCLI, release Release, stemcell stemcell.Stemcell, deployment Deployment) error {
+// ExportRelease exports a release unless index, when it is not nil, already
+// has it. See ExportReleaseWithProgress.
+func ExportRelease(ctx context.Context, boshCLI boshcli.BoshCLI, index *Index, release Release, stemcell stemcell.Stemcell, deployment Deployment) (bool, error) {
+	return ExportReleaseWithProgress(ctx, boshCLI, index, release, stemcell, deployment, nil)
+}
+
+// ExportReleaseWithProgress exports a release like ExportRelease and, when
+// progress is not nil, writes the export task's events to it while the export
+// runs. When index is not nil, a release it already has is skipped, and a new
+// tarball is written to index.Dir and recorded in the index. It reports
+// whether the export was skipped. When ctx is done, bosh is stopped and the
+// export task is cancelled on the Director.
+func ExportReleaseWithProgress(ctx context.Context, boshCLI boshcli.BoshCLI, index *Index, release Release, stemcell stemcell.Stemcell, deployment Deployment, progress io.Writer) (bool, error) {
+	var args []string
+	var staging string
+	if index != nil {
+		tarball, ok, err := index.Lookup(release, stemcell)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			fmt.Printf("Skipping %s for %s, already exported as %s\n", release.String(), stemcell.String(), tarball)
+			return true, nil
+		}
+
+		// Each export downloads into a directory of its own, so that the
+		// tarball it wrote is known while other exports write to index.Dir.
+		staging, err = os.MkdirTemp(index.Dir, ".export-")
+		if err != nil {
+			return false, err
+		}
+		defer os.RemoveAll(staging) //nolint:errcheck
+
+		args = append(args, "--dir", staging)
+	}
+
 	fmt.Printf("Exporting %s for %s from %s...\n", release.String(), stemcell.String(), deployment.Name)
-	_, err := boshCLI.Cmd("export-release", "-d", deployment.Name, "--json", release.String(), stemcell.String())
+	started := time.Now()
+
+	client := boshcli.NewClient(boshCLI).WithContext(ctx)
+	if progress != nil {
+		reporter := taskevents.NewReporter(progress, release.String())
+		defer reporter.Summary()
+		client = client.WithProgress(reporter)
+	}
+
+	_, err := client.ExportRelease(deployment.Name, release.String(), stemcell.String(), args...)
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	if index != nil {
+		tarball, err := moveTarball(staging, index.Dir)
+		if err != nil {
+			return false, err
+		}
+
+		err = index.Record(release, stemcell, tarball, time.Since(started))
+		if err != nil {
+			return false, err
+		}
 	}
 
 	fmt.Printf("Finished exporting %s\n", release.String())
-	return nil
+	return false, nil
+}
+
+// moveTarball moves the only tarball in staging to dir and returns its new
+// path.
+func moveTarball(staging, dir string) (string, error) {
+	tarballs, err := filepath.Glob(filepath.Join(staging, "*.tgz"))
+	if err != nil {
+		return "", err
+	}
+	if len(tarballs) != 1 {
+		return "", fmt.Errorf("expected bosh to export 1 tarball, found %d", len(tarballs))
+	}
+
+	tarball := filepath.Join(dir, filepath.Base(tarballs[0]))
+	return tarball, os.Rename(tarballs[0], tarball)
 }
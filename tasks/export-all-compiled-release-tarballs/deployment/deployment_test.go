@@ -2,21 +2,26 @@ package deployment_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli/boshclifakes"
 	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/deployment"
-	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/deployment/deploymentfakes"
 	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/stemcell"
 )
 
 var _ = Describe("List", func() {
 	var (
-		fakeCLI *deploymentfakes.FakeBoshCLI
+		fakeCLI *boshclifakes.FakeBoshCLI
+		filter  deployment.Filter
 
 		returnedReader io.Reader
 		returnedError  error
@@ -25,22 +30,29 @@ var _ = Describe("List", func() {
 		actualErr         error
 	)
 
+	stemcells := []stemcell.Stemcell{
+		{Name: "stemcell-name", OS: "stemcell-os", Version: "1.2"},
+		{Name: "other-stemcell-name", OS: "other-stemcell-os", Version: "3.4"},
+	}
+	deployedStemcells := []stemcell.Stemcell{stemcells[0]}
+
 	BeforeEach(func() {
-		fakeCLI = new(deploymentfakes.FakeBoshCLI)
+		fakeCLI = new(boshclifakes.FakeBoshCLI)
+		filter = deployment.Filter{Exclude: []string{"bosh-dns"}}
 
 		returnedReader = new(bytes.Buffer)
 		returnedError = nil
 	})
 
 	JustBeforeEach(func() {
-		fakeCLI.CmdReturns(returnedReader, returnedError)
+		fakeCLI.CmdReturnsOnCall(0, returnedReader, returnedError)
 
-		actualDeployments, actualErr = deployment.List(fakeCLI, []stemcell.Stemcell{{Name: "stemcell-name", OS: "stemcell-os", Version: "1.2"}})
+		actualDeployments, actualErr = deployment.List(context.Background(), fakeCLI, stemcells, filter)
 	})
 
 	It("should call `bosh deployments --json`", func() {
 		Expect(fakeCLI.CmdCallCount()).To(Equal(1), "expected bosh cli calls")
-		name, args := fakeCLI.CmdArgsForCall(0)
+		_, name, args := fakeCLI.CmdArgsForCall(0)
 
 		Expect(name).To(Equal("deployments"), "expected command name")
 		Expect(args).To(ConsistOf("--json"), "expected command args")
@@ -92,16 +104,16 @@ var _ = Describe("List", func() {
 					deployment.Deployment{
 						Name: "cf-compilation-release-a",
 						Releases: []deployment.Release{
-							{Name: "release-a", Version: "0.1.0"},
+							{Name: "release-a", Version: "0.1.0", Stemcells: deployedStemcells},
 						},
-						Stemcell: stemcell.Stemcell{OS: "stemcell-os", Version: "1.2"},
+						Stemcells: deployedStemcells,
 					},
 					deployment.Deployment{
 						Name: "cf-compilation-release-b",
 						Releases: []deployment.Release{
-							{Name: "release-b", Version: "2.0.0"},
+							{Name: "release-b", Version: "2.0.0", Stemcells: deployedStemcells},
 						},
-						Stemcell: stemcell.Stemcell{OS: "stemcell-os", Version: "1.2"},
+						Stemcells: deployedStemcells,
 					},
 				))
 			})
@@ -146,10 +158,10 @@ var _ = Describe("List", func() {
 					deployment.Deployment{
 						Name: "cf-compilation-releases",
 						Releases: []deployment.Release{
-							{Name: "release-a", Version: "0.1.0"},
-							{Name: "release-b", Version: "2.0.0"},
+							{Name: "release-a", Version: "0.1.0", Stemcells: deployedStemcells},
+							{Name: "release-b", Version: "2.0.0", Stemcells: deployedStemcells},
 						},
-						Stemcell: stemcell.Stemcell{OS: "stemcell-os", Version: "1.2"},
+						Stemcells: deployedStemcells,
 					},
 				))
 			})
@@ -194,16 +206,102 @@ var _ = Describe("List", func() {
 					deployment.Deployment{
 						Name: "cf-compilation-releases",
 						Releases: []deployment.Release{
-							{Name: "release-a", Version: "0.1.0"},
-							{Name: "release-b", Version: "2.0.0"},
+							{Name: "release-a", Version: "0.1.0", Stemcells: deployedStemcells},
+							{Name: "release-b", Version: "2.0.0", Stemcells: deployedStemcells},
 						},
-						Stemcell: stemcell.Stemcell{OS: "stemcell-os", Version: "1.2"},
+						Stemcells: deployedStemcells,
 					},
 				))
 			})
 		})
 	})
 
+	Context("when a deployment has more than one stemcell", func() {
+		BeforeEach(func() {
+			returnedReader = strings.NewReader(`{"Tables":[{"Content":"deployments","Rows":[{
+				"name": "cf-compilation-releases",
+				"release_s": "release-a/0.1.0\nrelease-b/2.0.0\nrelease-c/3.0.0",
+				"stemcell_s": "stemcell-name/1.2\nother-stemcell-name/3.4",
+				"team_s": ""
+			}]}]}`)
+			fakeCLI.CmdReturnsOnCall(1, strings.NewReader(`{"Blocks":["name: cf-compilation-releases\nstemcells:\n- alias: default\n  os: stemcell-os\n  version: \"1.2\"\n- alias: other\n  os: other-stemcell-os\n  version: latest\ninstance_groups:\n- name: a\n  stemcell: default\n  jobs:\n  - release: release-a\n  - release: release-b\n- name: b\n  stemcell: other\n  jobs:\n  - release: release-b\n"]}`), nil)
+		})
+
+		It("exports each release against the stemcells of the instances that use it", func() {
+			Expect(actualErr).ToNot(HaveOccurred())
+
+			_, name, args := fakeCLI.CmdArgsForCall(1)
+			Expect(name).To(Equal("manifest"))
+			Expect(args).To(Equal([]string{"-d", "cf-compilation-releases", "--json"}))
+
+			Expect(actualDeployments).To(Equal([]deployment.Deployment{{
+				Name: "cf-compilation-releases",
+				Releases: []deployment.Release{
+					{Name: "release-a", Version: "0.1.0", Stemcells: stemcells[:1]},
+					{Name: "release-b", Version: "2.0.0", Stemcells: stemcells},
+					{Name: "release-c", Version: "3.0.0", Stemcells: stemcells},
+				},
+				Stemcells: stemcells,
+			}}))
+		})
+
+		Context("when an instance group uses an unknown stemcell alias", func() {
+			BeforeEach(func() {
+				fakeCLI.CmdReturnsOnCall(1, strings.NewReader(`{"Blocks":["stemcells:\n- alias: default\n  os: stemcell-os\n  version: \"1.2\"\ninstance_groups:\n- name: a\n  stemcell: missing\n"]}`), nil)
+			})
+
+			It("returns an error", func() {
+				Expect(actualErr).To(MatchError(`deployment cf-compilation-releases: instance group a uses unknown stemcell alias "missing"`))
+			})
+		})
+	})
+
+	Context("when a release cell is malformed", func() {
+		BeforeEach(func() {
+			returnedReader = strings.NewReader(`{"Tables":[{"Content":"deployments","Rows":[{
+				"name": "cf-compilation-releases",
+				"release_s": "release-a",
+				"stemcell_s": "stemcell-name/1.2"
+			}]}]}`)
+		})
+
+		It("returns an error instead of panicking", func() {
+			Expect(actualErr).To(MatchError(`deployment cf-compilation-releases: malformed release: expected name/version, got "release-a"`))
+		})
+	})
+
+	Context("when a deployment has releases but no stemcells", func() {
+		BeforeEach(func() {
+			returnedReader = strings.NewReader(`{"Tables":[{"Content":"deployments","Rows":[{
+				"name": "cf-compilation-releases",
+				"release_s": "release-a/0.1.0",
+				"stemcell_s": ""
+			}]}]}`)
+		})
+
+		It("returns an error", func() {
+			Expect(actualErr).To(MatchError("deployment cf-compilation-releases: no stemcells to export releases against"))
+		})
+	})
+
+	Context("when include and exclude patterns are given", func() {
+		BeforeEach(func() {
+			filter = deployment.Filter{Include: []string{"release-*"}, Exclude: []string{"release-b"}}
+			returnedReader = strings.NewReader(`{"Tables":[{"Content":"deployments","Rows":[{
+				"name": "cf-compilation-releases",
+				"release_s": "release-a/0.1.0\nrelease-b/2.0.0\nbosh-dns/0.3.0",
+				"stemcell_s": "stemcell-name/1.2"
+			}]}]}`)
+		})
+
+		It("returns only the matching releases", func() {
+			Expect(actualErr).ToNot(HaveOccurred())
+			Expect(actualDeployments[0].Releases).To(Equal([]deployment.Release{
+				{Name: "release-a", Version: "0.1.0", Stemcells: deployedStemcells},
+			}))
+		})
+	})
+
 	Context("when the `bosh deployments` fails", func() {
 		BeforeEach(func() {
 			returnedError = errors.New("some error")
@@ -218,28 +316,33 @@ var _ = Describe("List", func() {
 
 var _ = Describe("ExportRelease", func() {
 	var (
-		fakeCLI       *deploymentfakes.FakeBoshCLI
+		fakeCLI       *boshclifakes.FakeBoshCLI
 		deploymentArg deployment.Deployment
 		releaseArg    deployment.Release
 		stemcellArg   stemcell.Stemcell
 
+		index          *deployment.Index
 		returnedReader io.Reader
 		returnedError  error
 
+		skipped   bool
 		actualErr error
 	)
 
 	BeforeEach(func() {
-		fakeCLI = new(deploymentfakes.FakeBoshCLI)
+		fakeCLI = new(boshclifakes.FakeBoshCLI)
 
+		index = nil
 		returnedReader = new(bytes.Buffer)
 		returnedError = nil
 	})
 
 	JustBeforeEach(func() {
-		fakeCLI.CmdReturns(returnedReader, returnedError)
+		if fakeCLI.CmdStub == nil {
+			fakeCLI.CmdReturns(returnedReader, returnedError)
+		}
 
-		actualErr = deployment.ExportRelease(fakeCLI, releaseArg, stemcellArg, deploymentArg)
+		skipped, actualErr = deployment.ExportRelease(context.Background(), fakeCLI, index, releaseArg, stemcellArg, deploymentArg)
 	})
 
 	Context("when a valid release and os are passed in", func() {
@@ -252,7 +355,7 @@ var _ = Describe("ExportRelease", func() {
 
 		It("should call `bosh export-release --json`", func() {
 			Expect(fakeCLI.CmdCallCount()).To(Equal(1), "expected bosh cli calls")
-			name, args := fakeCLI.CmdArgsForCall(0)
+			_, name, args := fakeCLI.CmdArgsForCall(0)
 
 			Expect(name).To(Equal("export-release"), "expected command name")
 			Expect(args).To(Equal([]string{
@@ -267,6 +370,59 @@ var _ = Describe("ExportRelease", func() {
 		Context("when it successfully exports a release", func() {
 			It("successfully returns", func() {
 				Expect(actualErr).ToNot(HaveOccurred())
+				Expect(skipped).To(BeFalse())
+			})
+		})
+
+		Context("when the index already has the release", func() {
+			BeforeEach(func() {
+				dir := GinkgoT().TempDir()
+				tarball := "some-release-some-release-version-some-os-some-os-version-20260101-120000-000000001.tgz"
+				Expect(os.WriteFile(filepath.Join(dir, tarball), nil, 0644)).To(Succeed())
+
+				var err error
+				index, err = deployment.LoadIndex(dir)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("skips the export", func() {
+				Expect(actualErr).ToNot(HaveOccurred())
+				Expect(skipped).To(BeTrue())
+				Expect(fakeCLI.CmdCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the index does not have the release yet", func() {
+			var dir string
+
+			BeforeEach(func() {
+				dir = GinkgoT().TempDir()
+
+				var err error
+				index, err = deployment.LoadIndex(dir)
+				Expect(err).NotTo(HaveOccurred())
+
+				fakeCLI.CmdStub = func(_ context.Context, _ string, args ...string) (io.Reader, error) {
+					Expect(args[len(args)-2]).To(Equal("--dir"))
+					tarball := "some-release-some-release-version-some-os-some-os-version-20260101-120000-000000001.tgz"
+					return new(bytes.Buffer), os.WriteFile(filepath.Join(args[len(args)-1], tarball), []byte("compiled"), 0644)
+				}
+			})
+
+			It("moves the exported tarball to the index directory and records it", func() {
+				Expect(actualErr).ToNot(HaveOccurred())
+				Expect(skipped).To(BeFalse())
+
+				entries, err := os.ReadDir(dir)
+				Expect(err).NotTo(HaveOccurred())
+				var names []string
+				for _, entry := range entries {
+					names = append(names, entry.Name())
+				}
+				Expect(names).To(ConsistOf(
+					"some-release-some-release-version-some-os-some-os-version-20260101-120000-000000001.tgz",
+					bosh.ExportsFile,
+				))
 			})
 		})
 
@@ -281,3 +437,22 @@ var _ = Describe("ExportRelease", func() {
 		})
 	})
 })
+
+var _ = Describe("Filter", func() {
+	It("matches every release when it has no patterns", func() {
+		Expect(deployment.Filter{}.Match("bosh-dns")).To(BeTrue())
+	})
+
+	It("only matches selected releases when there is a selection", func() {
+		filter := deployment.Filter{Include: []string{"release-*"}, Selected: []string{"release-a", "bosh-dns"}}
+		Expect(filter.Match("release-a")).To(BeTrue())
+		Expect(filter.Match("release-b")).To(BeFalse())
+		Expect(filter.Match("bosh-dns")).To(BeFalse())
+		Expect(deployment.Filter{Selected: []string{}}.Match("release-a")).To(BeFalse())
+	})
+
+	It("rejects invalid patterns", func() {
+		_, err := deployment.NewFilter([]string{"release-["}, nil)
+		Expect(err).To(MatchError(ContainSubstring(`invalid release pattern "release-["`)))
+	})
+})
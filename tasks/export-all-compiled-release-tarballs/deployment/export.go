@@ -0,0 +1,221 @@
+package deployment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli"
+	"github.com/cloudfoundry/runtime-ci/task-libs/director"
+	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/stemcell"
+)
+
+// IsRetryable reports whether err is a transient Director error: a request
+// that did not reach the Director or got a gateway error back, or a task the
+// Director timed out.
+func IsRetryable(err error) bool {
+	var cliErr boshcli.Error
+	if errors.As(err, &cliErr) {
+		return cliErr.Unreachable || isGatewayStatus(cliErr.StatusCode) || cliErr.TaskState == "timeout"
+	}
+
+	var directorErr director.Error
+	if errors.As(err, &directorErr) {
+		return isGatewayStatus(directorErr.StatusCode)
+	}
+
+	return false
+}
+
+func isGatewayStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// Export is a release to export for a stemcell from a deployment.
+type Export struct {
+	Release    Release
+	Stemcell   stemcell.Stemcell
+	Deployment Deployment
+}
+
+func (e Export) String() string {
+	return fmt.Sprintf("%s for %s from %s", e.Release, e.Stemcell, e.Deployment.Name)
+}
+
+// Exports returns an Export for every stemcell of every release of every
+// deployment.
+func Exports(deployments []Deployment) []Export {
+	var exports []Export
+	for _, deployment := range deployments {
+		for _, release := range deployment.Releases {
+			for _, releaseStemcell := range release.Stemcells {
+				exports = append(exports, Export{Release: release, Stemcell: releaseStemcell, Deployment: deployment})
+			}
+		}
+	}
+	return exports
+}
+
+// ExportResult is the outcome of an Export. Skipped is set when the release
+// had already been exported.
+type ExportResult struct {
+	Export
+	Attempts int
+	Skipped  bool
+	Err      error
+}
+
+// Exporter runs exports on a fixed number of workers. An export that fails
+// with a retryable error is retried up to Attempts times in total, waiting
+// Backoff before the first retry and doubling the wait for each one after.
+// Each attempt is given at most Timeout, when it is set.
+type Exporter struct {
+	Workers  int
+	Attempts int
+	Backoff  time.Duration
+	Timeout  time.Duration
+	Export   func(context.Context, Export) (skipped bool, err error)
+	Log      io.Writer
+}
+
+// ExportAll runs every export and returns their results in the order of
+// exports. Once ctx is done, no more exports are started or retried, and the
+// exports not yet started fail with the cause of ctx.
+func (e Exporter) ExportAll(ctx context.Context, exports []Export) []ExportResult {
+	workers := max(e.Workers, 1)
+
+	type indexedResult struct {
+		index  int
+		result ExportResult
+	}
+
+	jobs := make(chan int)
+	results := make(chan indexedResult)
+
+	for range workers {
+		go func() {
+			for i := range jobs {
+				results <- indexedResult{index: i, result: e.run(ctx, exports[i])}
+			}
+		}()
+	}
+
+	go func() {
+		for i := range exports {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	ordered := make([]ExportResult, len(exports))
+	for range exports {
+		r := <-results
+		ordered[r.index] = r.result
+	}
+
+	return ordered
+}
+
+func (e Exporter) run(ctx context.Context, export Export) ExportResult {
+	attempts := max(e.Attempts, 1)
+	backoff := e.Backoff
+
+	result := ExportResult{Export: export}
+	for {
+		if ctx.Err() != nil {
+			if result.Err == nil {
+				result.Err = fmt.Errorf("not exported: %w", context.Cause(ctx))
+			}
+			return result
+		}
+
+		result.Attempts++
+		result.Skipped, result.Err = e.attempt(ctx, export)
+		if result.Err == nil || !IsRetryable(result.Err) || result.Attempts >= attempts {
+			return result
+		}
+
+		if e.Log != nil {
+			fmt.Fprintf(e.Log, "Retrying export of %s in %s (attempt %d of %d failed): %s\n", export, backoff, result.Attempts, attempts, result.Err)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff *= 2
+	}
+}
+
+func (e Exporter) attempt(ctx context.Context, export Export) (bool, error) {
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, e.Timeout, fmt.Errorf("timed out after %s", e.Timeout))
+		defer cancel()
+	}
+
+	return e.Export(ctx, export)
+}
+
+// Failed reports whether any of results failed.
+func Failed(results []ExportResult) bool {
+	for _, result := range results {
+		if result.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteSummary writes which exports succeeded and which failed.
+func WriteSummary(w io.Writer, results []ExportResult) {
+	var succeeded, skipped, failed []ExportResult
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			failed = append(failed, result)
+		case result.Skipped:
+			skipped = append(skipped, result)
+		default:
+			succeeded = append(succeeded, result)
+		}
+	}
+
+	fmt.Fprintf(w, "\nExported %d of %d releases", len(succeeded), len(results))
+	if len(skipped) > 0 {
+		fmt.Fprintf(w, ", %d already exported", len(skipped))
+	}
+	fmt.Fprintln(w)
+	if len(skipped) > 0 {
+		fmt.Fprintln(w, "Skipped:")
+		for _, result := range skipped {
+			fmt.Fprintf(w, "  %s\n", result.Export)
+		}
+	}
+	if len(succeeded) > 0 {
+		fmt.Fprintln(w, "Succeeded:")
+		for _, result := range succeeded {
+			fmt.Fprintf(w, "  %s%s\n", result.Export, attemptsNote(result.Attempts))
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Fprintln(w, "Failed:")
+		for _, result := range failed {
+			fmt.Fprintf(w, "  %s%s: %s\n", result.Export, attemptsNote(result.Attempts), result.Err)
+		}
+	}
+}
+
+func attemptsNote(attempts int) string {
+	if attempts <= 1 {
+		return ""
+	}
+	return fmt.Sprintf(" (after %d attempts)", attempts)
+}
@@ -0,0 +1,271 @@
+package deployment_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli"
+	"github.com/cloudfoundry/runtime-ci/task-libs/director"
+	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/deployment"
+	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/stemcell"
+)
+
+var _ = Describe("Exporter", func() {
+	var (
+		deployments []deployment.Deployment
+		exports     []deployment.Export
+	)
+
+	jammy := []stemcell.Stemcell{{Name: "bosh-jammy", OS: "ubuntu-jammy", Version: "1.2"}}
+
+	BeforeEach(func() {
+		deployments = []deployment.Deployment{
+			{
+				Name:      "compilation-a",
+				Releases:  []deployment.Release{{Name: "release-a", Version: "1.0", Stemcells: jammy}, {Name: "release-b", Version: "2.0", Stemcells: jammy}},
+				Stemcells: jammy,
+			},
+			{
+				Name:      "compilation-c",
+				Releases:  []deployment.Release{{Name: "release-c", Version: "3.0", Stemcells: jammy}},
+				Stemcells: jammy,
+			},
+		}
+		exports = deployment.Exports(deployments)
+	})
+
+	Describe("Exports", func() {
+		It("returns an export for every release of every deployment", func() {
+			Expect(exports).To(Equal([]deployment.Export{
+				{Release: deployments[0].Releases[0], Stemcell: jammy[0], Deployment: deployments[0]},
+				{Release: deployments[0].Releases[1], Stemcell: jammy[0], Deployment: deployments[0]},
+				{Release: deployments[1].Releases[0], Stemcell: jammy[0], Deployment: deployments[1]},
+			}))
+			Expect(exports[0].String()).To(Equal("release-a/1.0 for ubuntu-jammy/1.2 from compilation-a"))
+		})
+
+		It("returns an export for every stemcell of a release", func() {
+			noble := stemcell.Stemcell{Name: "bosh-noble", OS: "ubuntu-noble", Version: "3.4"}
+			release := deployment.Release{Name: "release-a", Version: "1.0", Stemcells: append([]stemcell.Stemcell{noble}, jammy...)}
+			multi := deployment.Deployment{Name: "compilation-multi", Releases: []deployment.Release{release}}
+
+			Expect(deployment.Exports([]deployment.Deployment{multi})).To(Equal([]deployment.Export{
+				{Release: release, Stemcell: noble, Deployment: multi},
+				{Release: release, Stemcell: jammy[0], Deployment: multi},
+			}))
+		})
+	})
+
+	Describe("ExportAll", func() {
+		It("runs no more exports at a time than there are workers", func() {
+			var running, peak atomic.Int32
+			exporter := deployment.Exporter{
+				Workers: 2,
+				Export: func(context.Context, deployment.Export) (bool, error) {
+					n := running.Add(1)
+					for {
+						p := peak.Load()
+						if n <= p || peak.CompareAndSwap(p, n) {
+							break
+						}
+					}
+					time.Sleep(20 * time.Millisecond)
+					running.Add(-1)
+					return false, nil
+				},
+			}
+
+			results := exporter.ExportAll(context.Background(), exports)
+			Expect(results).To(HaveLen(3))
+			Expect(peak.Load()).To(Equal(int32(2)))
+			Expect(deployment.Failed(results)).To(BeFalse())
+		})
+
+		It("returns the results in the order of the exports", func() {
+			exporter := deployment.Exporter{
+				Workers: 3,
+				Export: func(_ context.Context, export deployment.Export) (bool, error) {
+					if export.Release.Name == "release-b" {
+						return false, errors.New("compilation failed")
+					}
+					return export.Release.Name == "release-c", nil
+				},
+			}
+
+			results := exporter.ExportAll(context.Background(), exports)
+			Expect(results).To(Equal([]deployment.ExportResult{
+				{Export: exports[0], Attempts: 1},
+				{Export: exports[1], Attempts: 1, Err: errors.New("compilation failed")},
+				{Export: exports[2], Attempts: 1, Skipped: true},
+			}))
+			Expect(deployment.Failed(results)).To(BeTrue())
+		})
+
+		It("retries retryable errors with backoff", func() {
+			var (
+				mu       sync.Mutex
+				attempts []time.Time
+			)
+			log := new(strings.Builder)
+			exporter := deployment.Exporter{
+				Workers:  1,
+				Attempts: 3,
+				Backoff:  10 * time.Millisecond,
+				Log:      log,
+				Export: func(_ context.Context, export deployment.Export) (bool, error) {
+					if export.Release.Name != "release-a" {
+						return false, nil
+					}
+
+					mu.Lock()
+					defer mu.Unlock()
+					attempts = append(attempts, time.Now())
+					if len(attempts) < 3 {
+						return false, boshcli.Error{Message: "dial tcp 10.0.0.6:25555: connect: connection refused", Unreachable: true}
+					}
+					return false, nil
+				},
+			}
+
+			results := exporter.ExportAll(context.Background(), exports)
+			Expect(results[0]).To(Equal(deployment.ExportResult{Export: exports[0], Attempts: 3}))
+			Expect(attempts).To(HaveLen(3))
+			Expect(attempts[1].Sub(attempts[0])).To(BeNumerically(">=", 10*time.Millisecond))
+			Expect(attempts[2].Sub(attempts[1])).To(BeNumerically(">=", 20*time.Millisecond))
+			Expect(log.String()).To(ContainSubstring("Retrying export of release-a/1.0 for ubuntu-jammy/1.2 from compilation-a in 10ms (attempt 1 of 3 failed): dial tcp"))
+			Expect(log.String()).To(ContainSubstring("in 20ms (attempt 2 of 3 failed)"))
+		})
+
+		It("gives up after the last attempt", func() {
+			exporter := deployment.Exporter{
+				Workers:  1,
+				Attempts: 2,
+				Export: func(context.Context, deployment.Export) (bool, error) {
+					return false, boshcli.Error{Message: "503 Service Unavailable", StatusCode: http.StatusServiceUnavailable}
+				},
+			}
+
+			results := exporter.ExportAll(context.Background(), exports[:1])
+			Expect(results).To(Equal([]deployment.ExportResult{
+				{Export: exports[0], Attempts: 2, Err: boshcli.Error{Message: "503 Service Unavailable", StatusCode: http.StatusServiceUnavailable}},
+			}))
+		})
+
+		It("does not retry other errors", func() {
+			var calls atomic.Int32
+			exporter := deployment.Exporter{
+				Workers:  1,
+				Attempts: 3,
+				Export: func(context.Context, deployment.Export) (bool, error) {
+					calls.Add(1)
+					return false, errors.New("compilation failed")
+				},
+			}
+
+			exporter.ExportAll(context.Background(), exports[:1])
+			Expect(calls.Load()).To(Equal(int32(1)))
+		})
+
+		It("stops starting and retrying exports once the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			exporter := deployment.Exporter{
+				Workers:  1,
+				Attempts: 3,
+				Backoff:  time.Hour,
+				Export: func(context.Context, deployment.Export) (bool, error) {
+					cancel()
+					return false, boshcli.Error{Message: "503 Service Unavailable", StatusCode: http.StatusServiceUnavailable}
+				},
+			}
+
+			results := exporter.ExportAll(ctx, exports)
+			Expect(results).To(Equal([]deployment.ExportResult{
+				{Export: exports[0], Attempts: 1, Err: boshcli.Error{Message: "503 Service Unavailable", StatusCode: http.StatusServiceUnavailable}},
+				{Export: exports[1], Err: fmt.Errorf("not exported: %w", context.Canceled)},
+				{Export: exports[2], Err: fmt.Errorf("not exported: %w", context.Canceled)},
+			}))
+		})
+
+		It("gives each attempt at most the timeout", func() {
+			exporter := deployment.Exporter{
+				Workers:  1,
+				Attempts: 3,
+				Timeout:  10 * time.Millisecond,
+				Export: func(ctx context.Context, _ deployment.Export) (bool, error) {
+					<-ctx.Done()
+					return false, context.Cause(ctx)
+				},
+			}
+
+			results := exporter.ExportAll(context.Background(), exports[:1])
+			Expect(results).To(Equal([]deployment.ExportResult{
+				{Export: exports[0], Attempts: 1, Err: errors.New("timed out after 10ms")},
+			}))
+		})
+	})
+
+	Describe("WriteSummary", func() {
+		It("lists the exports that succeeded and failed", func() {
+			summary := new(strings.Builder)
+			deployment.WriteSummary(summary, []deployment.ExportResult{
+				{Export: exports[0], Attempts: 1},
+				{Export: exports[1], Attempts: 3, Err: errors.New("Failed to acquire lock")},
+				{Export: exports[2], Attempts: 2},
+			})
+
+			Expect(summary.String()).To(Equal(`
+Exported 2 of 3 releases
+Succeeded:
+  release-a/1.0 for ubuntu-jammy/1.2 from compilation-a
+  release-c/3.0 for ubuntu-jammy/1.2 from compilation-c (after 2 attempts)
+Failed:
+  release-b/2.0 for ubuntu-jammy/1.2 from compilation-a (after 3 attempts): Failed to acquire lock
+`))
+		})
+
+		It("lists the exports that were skipped", func() {
+			summary := new(strings.Builder)
+			deployment.WriteSummary(summary, []deployment.ExportResult{
+				{Export: exports[0], Attempts: 1, Skipped: true},
+				{Export: exports[1], Attempts: 1},
+			})
+
+			Expect(summary.String()).To(Equal(`
+Exported 1 of 2 releases, 1 already exported
+Skipped:
+  release-a/1.0 for ubuntu-jammy/1.2 from compilation-a
+Succeeded:
+  release-b/2.0 for ubuntu-jammy/1.2 from compilation-a
+`))
+		})
+	})
+})
+
+var _ = Describe("IsRetryable", func() {
+	DescribeTable("classifies Director errors",
+		func(err error, retryable bool) {
+			Expect(deployment.IsRetryable(err)).To(Equal(retryable))
+		},
+		Entry("unreachable Director", boshcli.Error{Message: "connection refused", Unreachable: true}, true),
+		Entry("gateway error", boshcli.Error{Message: "Bad Gateway", StatusCode: http.StatusBadGateway}, true),
+		Entry("timed out task", boshcli.Error{Message: "Timed out", TaskID: 12, TaskState: "timeout"}, true),
+		Entry("wrapped timed out task", fmt.Errorf("export: %w", boshcli.Error{TaskID: 12, TaskState: "timeout"}), true),
+		Entry("failed task", boshcli.Error{Message: "Error: compilation failed", TaskID: 12, TaskState: "error"}, false),
+		Entry("client error", boshcli.Error{Message: "Not found", StatusCode: http.StatusNotFound}, false),
+		Entry("Director API gateway error", director.Error{StatusCode: http.StatusServiceUnavailable}, true),
+		Entry("Director API client error", director.Error{StatusCode: http.StatusBadRequest}, false),
+		Entry("untyped error with a transient message", errors.New("502 Bad Gateway"), false),
+		Entry("nil", nil, false),
+	)
+})
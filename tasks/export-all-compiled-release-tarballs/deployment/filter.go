@@ -0,0 +1,49 @@
+package deployment
+
+import (
+	"fmt"
+	"path"
+	"slices"
+)
+
+// Filter selects releases by name. A release matches when it matches one of
+// the Include patterns, or Include is empty, and matches none of the Exclude
+// patterns. Patterns use path.Match syntax, such as "bosh-*". When Selected
+// is not nil, a release must also be one of its names.
+type Filter struct {
+	Include  []string
+	Exclude  []string
+	Selected []string
+}
+
+// NewFilter returns a Filter after checking that its patterns are valid.
+func NewFilter(include, exclude []string) (Filter, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return Filter{}, fmt.Errorf("invalid release pattern %q: %w", pattern, err)
+		}
+	}
+
+	return Filter{Include: include, Exclude: exclude}, nil
+}
+
+// Match reports whether the release called name is selected.
+func (f Filter) Match(name string) bool {
+	if f.Selected != nil && !slices.Contains(f.Selected, name) {
+		return false
+	}
+	if len(f.Include) > 0 && !matchAny(f.Include, name) {
+		return false
+	}
+	return !matchAny(f.Exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
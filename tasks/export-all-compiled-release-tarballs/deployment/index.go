@@ -0,0 +1,211 @@
+package deployment
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/stemcell"
+)
+
+// Index records the compiled release tarballs that have already been
+// exported, by release, version and stemcell. It is kept in Dir as the
+// exports manifest (bosh.ExportsFile), which lists both the tarballs exported
+// to Dir and the releases skipped because they were already exported, so
+// that the manifest covers every release of the run. It is safe for use by
+// several exports at once.
+type Index struct {
+	// Dir is the directory exports are written to.
+	Dir string
+
+	mu      sync.Mutex
+	exports []bosh.ExportedRelease
+	files   []exportedTarball
+}
+
+// exportedTarball is a tarball exported before the index knew about it:
+// either a file in Dir, or a line of a listing with its sha256.
+type exportedTarball struct {
+	name   string
+	path   string
+	sha256 string
+}
+
+// LoadIndex reads the exports manifest in dir along with the tarballs
+// already in dir. Each listing is a file naming one exported tarball per
+// line, after its sha256 as in the output of sha256sum, such as a listing of
+// the bucket the tarballs are uploaded to; only the base name of each
+// tarball is used.
+func LoadIndex(dir string, listings ...string) (*Index, error) {
+	index := &Index{Dir: dir}
+
+	exportsPath := filepath.Join(dir, bosh.ExportsFile)
+	if _, err := os.Stat(exportsPath); err == nil {
+		index.exports, err = bosh.ReadExports(exportsPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tarballs, err := filepath.Glob(filepath.Join(dir, "*.tgz"))
+	if err != nil {
+		return nil, err
+	}
+	for _, tarball := range tarballs {
+		index.files = append(index.files, exportedTarball{name: filepath.Base(tarball), path: tarball})
+	}
+
+	for _, listing := range listings {
+		files, err := readListing(listing)
+		if err != nil {
+			return nil, err
+		}
+		index.files = append(index.files, files...)
+	}
+
+	return index, nil
+}
+
+func readListing(listing string) ([]exportedTarball, error) {
+	f, err := os.Open(listing)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	var files []exportedTarball
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("listing %s: expected \"<sha256>  <tarball>\", got %q", listing, line)
+		}
+		files = append(files, exportedTarball{name: path.Base(fields[1]), sha256: fields[0]})
+	}
+
+	return files, scanner.Err()
+}
+
+// Lookup returns the tarball already exported for a release compiled
+// against a stemcell. A tarball found in Dir or a listing is added to the
+// exports manifest, which is saved.
+func (i *Index) Lookup(release Release, stemcell stemcell.Stemcell) (string, bool, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, export := range i.exports {
+		if isExportOf(export, release, stemcell) {
+			return export.Tarball, true, nil
+		}
+	}
+
+	pattern := tarballPattern(release, stemcell)
+	for _, file := range i.files {
+		if !pattern.MatchString(file.name) {
+			continue
+		}
+
+		sum := file.sha256
+		if sum == "" {
+			var err error
+			sum, err = sha256Sum(file.path)
+			if err != nil {
+				return "", false, err
+			}
+		}
+
+		i.exports = append(i.exports, bosh.ExportedRelease{
+			Release:         release.Name,
+			Version:         release.Version,
+			StemcellOS:      stemcell.OS,
+			StemcellVersion: stemcell.Version,
+			Tarball:         file.name,
+			SHA256:          sum,
+		})
+		return file.name, true, i.save()
+	}
+
+	return "", false, nil
+}
+
+// Record adds tarballPath, the tarball just exported to Dir for a release
+// compiled against a stemcell, to the exports manifest and saves it.
+// duration is how long the export took.
+func (i *Index) Record(release Release, stemcell stemcell.Stemcell, tarballPath string, duration time.Duration) error {
+	tarball := filepath.Base(tarballPath)
+	if !tarballPattern(release, stemcell).MatchString(tarball) {
+		return fmt.Errorf("tarball %s is not named for %s on %s", tarballPath, release, stemcell)
+	}
+
+	sum, err := sha256Sum(tarballPath)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.exports = slices.DeleteFunc(i.exports, func(e bosh.ExportedRelease) bool {
+		return isExportOf(e, release, stemcell)
+	})
+	i.exports = append(i.exports, bosh.ExportedRelease{
+		Release:         release.Name,
+		Version:         release.Version,
+		StemcellOS:      stemcell.OS,
+		StemcellVersion: stemcell.Version,
+		Tarball:         tarball,
+		SHA256:          sum,
+		DurationSeconds: duration.Round(time.Millisecond).Seconds(),
+	})
+
+	return i.save()
+}
+
+func (i *Index) save() error {
+	return bosh.WriteExports(filepath.Join(i.Dir, bosh.ExportsFile), i.exports)
+}
+
+func isExportOf(export bosh.ExportedRelease, release Release, stemcell stemcell.Stemcell) bool {
+	return export.Release == release.Name && export.Version == release.Version &&
+		export.StemcellOS == stemcell.OS && export.StemcellVersion == stemcell.Version
+}
+
+func sha256Sum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() //nolint:errcheck
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// tarballPattern matches the name of a tarball exported by
+// `bosh export-release` for the release and stemcell:
+// <release>-<version>-<os>-<stemcell version>-<date>-<time>-<nanoseconds>.tgz
+func tarballPattern(release Release, stemcell stemcell.Stemcell) *regexp.Regexp {
+	prefix := strings.Join([]string{release.Name, release.Version, stemcell.OS, stemcell.Version}, "-")
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(prefix) + `-\d{8}-\d{6}-\d+\.tgz$`)
+}
@@ -0,0 +1,157 @@
+package deployment_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/deployment"
+	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/stemcell"
+)
+
+var _ = Describe("Index", func() {
+	var (
+		dir      string
+		release  deployment.Release
+		jammy    stemcell.Stemcell
+		noble    stemcell.Stemcell
+		tarballA string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "index-")
+		Expect(err).NotTo(HaveOccurred())
+
+		release = deployment.Release{Name: "release-a", Version: "1.0"}
+		jammy = stemcell.Stemcell{OS: "ubuntu-jammy", Version: "1.2"}
+		noble = stemcell.Stemcell{OS: "ubuntu-noble", Version: "3.4"}
+		tarballA = "release-a-1.0-ubuntu-jammy-1.2-20260101-120000-000000001.tgz"
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("finds tarballs already in the directory and adds them to the exports manifest", func() {
+		Expect(os.WriteFile(filepath.Join(dir, tarballA), []byte("compiled"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "release-a-extra-1.0-ubuntu-noble-3.4-20260101-120000-000000001.tgz"), nil, 0644)).To(Succeed())
+
+		index, err := deployment.LoadIndex(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		tarball, ok, err := index.Lookup(release, jammy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(tarball).To(Equal(tarballA))
+
+		_, ok, err = index.Lookup(release, noble)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+
+		exports, err := bosh.ReadExports(filepath.Join(dir, bosh.ExportsFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exports).To(Equal([]bosh.ExportedRelease{{
+			Release:         "release-a",
+			Version:         "1.0",
+			StemcellOS:      "ubuntu-jammy",
+			StemcellVersion: "1.2",
+			Tarball:         tarballA,
+			SHA256:          "64ebd267717810f9524a58c6d7715bd9502b3c9235b291a7104389b372aeec4b",
+		}}))
+	})
+
+	It("finds tarballs in listings and adds them to the exports manifest", func() {
+		listing := filepath.Join(dir, "listing.txt")
+		Expect(os.WriteFile(listing, []byte("\nabc  compiled-releases/"+tarballA+"\n"), 0644)).To(Succeed())
+
+		index, err := deployment.LoadIndex(dir, listing)
+		Expect(err).NotTo(HaveOccurred())
+
+		tarball, ok, err := index.Lookup(release, jammy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(tarball).To(Equal(tarballA))
+
+		exports, err := bosh.ReadExports(filepath.Join(dir, bosh.ExportsFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exports).To(HaveLen(1))
+		Expect(exports[0].Tarball).To(Equal(tarballA))
+		Expect(exports[0].SHA256).To(Equal("abc"))
+	})
+
+	It("returns an error for a listing without sha256s", func() {
+		listing := filepath.Join(dir, "listing.txt")
+		Expect(os.WriteFile(listing, []byte(tarballA+"\n"), 0644)).To(Succeed())
+
+		_, err := deployment.LoadIndex(dir, listing)
+		Expect(err).To(MatchError(ContainSubstring(`expected "<sha256>  <tarball>"`)))
+	})
+
+	It("records new tarballs in the exports manifest", func() {
+		index, err := deployment.LoadIndex(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(dir, tarballA), []byte("compiled"), 0644)).To(Succeed())
+		Expect(index.Record(release, jammy, filepath.Join(dir, tarballA), 1500*time.Millisecond)).To(Succeed())
+
+		exports, err := bosh.ReadExports(filepath.Join(dir, bosh.ExportsFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exports).To(Equal([]bosh.ExportedRelease{{
+			Release:         "release-a",
+			Version:         "1.0",
+			StemcellOS:      "ubuntu-jammy",
+			StemcellVersion: "1.2",
+			Tarball:         tarballA,
+			SHA256:          "64ebd267717810f9524a58c6d7715bd9502b3c9235b291a7104389b372aeec4b",
+			DurationSeconds: 1.5,
+		}}))
+
+		Expect(os.Remove(filepath.Join(dir, tarballA))).To(Succeed())
+		reloaded, err := deployment.LoadIndex(dir)
+		Expect(err).NotTo(HaveOccurred())
+		tarball, ok, err := reloaded.Lookup(release, jammy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(tarball).To(Equal(tarballA))
+	})
+
+	It("returns an error when the new tarball is missing", func() {
+		index, err := deployment.LoadIndex(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(index.Record(release, jammy, filepath.Join(dir, tarballA), time.Second)).To(MatchError(ContainSubstring("no such file")))
+	})
+
+	It("returns an error when the tarball is for another release", func() {
+		index, err := deployment.LoadIndex(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(index.Record(release, noble, filepath.Join(dir, tarballA), time.Second)).To(MatchError(ContainSubstring("is not named for release-a/1.0 on ubuntu-noble/3.4")))
+	})
+
+	It("treats the releases in the exports manifest as exported", func() {
+		Expect(bosh.WriteExports(filepath.Join(dir, bosh.ExportsFile), []bosh.ExportedRelease{{
+			Release: "release-a", Version: "1.0", StemcellOS: "ubuntu-jammy", StemcellVersion: "1.2", Tarball: tarballA, SHA256: "abc",
+		}})).To(Succeed())
+
+		index, err := deployment.LoadIndex(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		tarball, ok, err := index.Lookup(release, jammy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(tarball).To(Equal(tarballA))
+	})
+
+	It("returns an error for an invalid exports manifest", func() {
+		Expect(os.WriteFile(filepath.Join(dir, bosh.ExportsFile), []byte("not json"), 0644)).To(Succeed())
+
+		_, err := deployment.LoadIndex(dir)
+		Expect(err).To(MatchError(ContainSubstring("failed to parse exports manifest")))
+	})
+})
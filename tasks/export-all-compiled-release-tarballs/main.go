@@ -1,53 +1,105 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"sync"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/command"
+	"github.com/spf13/pflag"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli"
 	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/deployment"
 	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/stemcell"
 )
 
 func main() {
-	boshCLI := new(command.BoshCLI)
+	var (
+		workers  int
+		attempts int
+		backoff  time.Duration
+		timeout  time.Duration
+		include  []string
+		exclude  []string
+		dir      string
+		listings []string
+		selected string
+	)
+	pflag.IntVar(&workers, "workers", 4, "number of releases to export at the same time")
+	pflag.IntVar(&attempts, "attempts", 3, "number of times to try an export that fails with a retryable Director error")
+	pflag.DurationVar(&backoff, "backoff", 10*time.Second, "wait before the first retry, doubled for each retry after")
+	pflag.DurationVar(&timeout, "timeout", 0, "time allowed for each export attempt, 0 for no limit")
+	pflag.StringSliceVar(&include, "include", nil, "only export releases matching these patterns")
+	pflag.StringSliceVar(&exclude, "exclude", []string{"bosh-dns"}, "do not export releases matching these patterns")
+	pflag.StringVar(&dir, "dir", ".", "directory to write tarballs and their exports manifest to")
+	pflag.StringSliceVar(&listings, "listing", nil, "files listing tarballs that are already exported, one \"<sha256>  <tarball>\" per line")
+	pflag.StringVar(&selected, "selection", "", "only export the releases selected in this selection file written by deploy-all-releases")
+	pflag.Parse()
 
-	stemcells, err := stemcell.List(boshCLI)
+	if workers < 1 || attempts < 1 || backoff < 0 || timeout < 0 {
+		fmt.Println("usage: main.go [--workers n] [--attempts n] [--backoff duration] [--timeout duration] [--include pattern,...] [--exclude pattern,...] [--dir dir] [--listing file,...] [--selection file]")
+		os.Exit(1)
+	}
+
+	filter, err := deployment.NewFilter(include, exclude)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	deployments, err := deployment.List(boshCLI, stemcells)
+	if selected != "" {
+		selection, err := bosh.ReadSelection(selected)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		filter.Selected = selection.SelectedNames()
+		fmt.Printf("Only exporting the %d releases selected in %s\n", len(filter.Selected), selected)
+	}
+
+	index, err := deployment.LoadIndex(dir, listings...)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	var errOccured bool
-	var wg sync.WaitGroup
+	// Concourse sends SIGTERM when a build is aborted. Stopping the context
+	// stops bosh and cancels the Director tasks of the running exports.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
-	for _, boshDeployment := range deployments {
-		boshStemcell := boshDeployment.Stemcell
+	boshCLI := new(boshcli.CLI)
 
-		for _, boshRelease := range boshDeployment.Releases {
-			wg.Add(1)
+	stemcells, err := stemcell.List(ctx, boshCLI)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-			go func(boshRelease deployment.Release, boshStemcell stemcell.Stemcell, boshDeployment deployment.Deployment, wg *sync.WaitGroup) {
-				err := deployment.ExportRelease(boshCLI, boshRelease, boshStemcell, boshDeployment)
-				if err != nil {
-					errOccured = true
-					fmt.Printf("Failed to export release: %s\n", err)
-				}
-				wg.Done()
-			}(boshRelease, boshStemcell, boshDeployment, &wg)
-		}
+	deployments, err := deployment.List(ctx, boshCLI, stemcells, filter)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	exporter := deployment.Exporter{
+		Workers:  workers,
+		Attempts: attempts,
+		Backoff:  backoff,
+		Timeout:  timeout,
+		Log:      os.Stdout,
+		Export: func(ctx context.Context, export deployment.Export) (bool, error) {
+			return deployment.ExportReleaseWithProgress(ctx, boshCLI, index, export.Release, export.Stemcell, export.Deployment, os.Stdout)
+		},
 	}
 
-	wg.Wait()
+	results := exporter.ExportAll(ctx, deployment.Exports(deployments))
+	deployment.WriteSummary(os.Stdout, results)
 
-	if errOccured {
+	if deployment.Failed(results) {
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,280 @@
+package main_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/task-libs/director/fakedirector"
+)
+
+var _ = Describe("export-all-compiled-release-tarballs", func() {
+	var (
+		fake    *fakedirector.Director
+		binDir  string
+		workDir string
+		task    string
+	)
+
+	BeforeEach(func() {
+		var err error
+		task, err = gexec.Build("github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs")
+		Expect(err).NotTo(HaveOccurred())
+
+		binDir, err = os.MkdirTemp("", "bin-")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakedirector.InstallFakeBOSH(binDir)).To(Succeed())
+
+		workDir, err = os.MkdirTemp("", "export-")
+		Expect(err).NotTo(HaveOccurred())
+
+		fake = fakedirector.New().Start()
+		fake.UploadStemcell("bosh-warden-boshlite-ubuntu-jammy-go_agent", "ubuntu-jammy", "1.2")
+		for _, release := range []string{"release-a", "release-b", "bosh-dns"} {
+			fake.UploadRelease(release, "1.0")
+		}
+
+		deploy := func(name string, releases ...string) {
+			manifest := "name: " + name + "\nreleases:\n"
+			for _, release := range releases {
+				manifest += "- name: " + release + "\n  version: \"1.0\"\n"
+			}
+			manifest += "stemcells:\n- alias: default\n  os: ubuntu-jammy\n  version: \"1.2\"\n"
+
+			manifestPath := filepath.Join(workDir, name+".yml")
+			Expect(os.WriteFile(manifestPath, []byte(manifest), 0644)).To(Succeed())
+
+			cmd := exec.Command(filepath.Join(binDir, "bosh"), "deploy", manifestPath, "-d", name, "-n", "--json")
+			cmd.Env = append(os.Environ(), fake.Env()...)
+			Expect(cmd.Run()).To(Succeed())
+			Expect(os.Remove(manifestPath)).To(Succeed())
+		}
+		deploy("release-a-compilation", "release-a", "bosh-dns")
+		deploy("release-b-compilation", "release-b")
+	})
+
+	AfterEach(func() {
+		fake.Close()
+		Expect(os.RemoveAll(binDir)).To(Succeed())
+		Expect(os.RemoveAll(workDir)).To(Succeed())
+	})
+
+	start := func(args ...string) *gexec.Session {
+		cmd := exec.Command(task, args...)
+		cmd.Dir = workDir
+		cmd.Env = append(os.Environ(), fake.Env()...)
+		cmd.Env = append(cmd.Env, "PATH="+binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return session
+	}
+
+	run := func(args ...string) *gexec.Session {
+		session := start(args...)
+		Eventually(session, 30*time.Second).Should(gexec.Exit())
+		return session
+	}
+
+	taskState := func(match string) func() string {
+		return func() string {
+			for _, t := range fake.Tasks() {
+				if strings.Contains(t.Description, match) {
+					return t.State
+				}
+			}
+			return ""
+		}
+	}
+
+	It("exports every compiled release except bosh-dns", func() {
+		session := run()
+		Expect(session.ExitCode()).To(Equal(0))
+		Expect(session.Out).To(gbytes.Say(`\[release-a/1.0\] Stage timings:`))
+
+		tarballs, err := filepath.Glob(filepath.Join(workDir, "*.tgz"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tarballs).To(HaveLen(2))
+		Expect(filepath.Base(tarballs[0])).To(HavePrefix("release-a-1.0-ubuntu-jammy-1.2-"))
+		Expect(filepath.Base(tarballs[1])).To(HavePrefix("release-b-1.0-ubuntu-jammy-1.2-"))
+
+		exports, err := bosh.ReadExports(filepath.Join(workDir, bosh.ExportsFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exports).To(HaveLen(2))
+		for i, export := range exports {
+			Expect(export.Tarball).To(Equal(filepath.Base(tarballs[i])))
+			Expect(export.SHA256).To(HaveLen(64))
+		}
+	})
+
+	It("exits non-zero when an export fails", func() {
+		fake.FailTasks("export release: release-b", "compilation failed")
+
+		session := run()
+		Expect(session.ExitCode()).To(Equal(1))
+		Expect(session.Out).To(gbytes.Say(`Exported 1 of 2 releases`))
+		Expect(session.Out).To(gbytes.Say(`Succeeded:\n  release-a/1.0 for ubuntu-jammy/1.2 from release-a-compilation\n`))
+		Expect(session.Out).To(gbytes.Say(`Failed:\n  release-b/1.0 for ubuntu-jammy/1.2 from release-b-compilation: .*compilation failed`))
+
+		tarballs, err := filepath.Glob(filepath.Join(workDir, "release-a-*.tgz"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tarballs).To(HaveLen(1))
+	})
+
+	It("retries exports that fail with a retryable Director error", func() {
+		fake.TimeOutTasksTimes("export release: release-b", 2)
+
+		session := run("--backoff", "10ms")
+		Expect(session.ExitCode()).To(Equal(0))
+		Expect(session.Out).To(gbytes.Say(`Retrying export of release-b/1.0 for ubuntu-jammy/1.2 from release-b-compilation in 10ms \(attempt 1 of 3 failed\)`))
+		Expect(session.Out).To(gbytes.Say(`Exported 2 of 2 releases`))
+		Expect(session.Out).To(gbytes.Say(`release-b/1.0 for ubuntu-jammy/1.2 from release-b-compilation \(after 3 attempts\)`))
+	})
+
+	It("does not retry exports that fail with other errors", func() {
+		fake.FailTasks("export release: release-b", "compilation failed")
+
+		session := run("--backoff", "10ms")
+		Expect(session.ExitCode()).To(Equal(1))
+		Expect(session.Out).NotTo(gbytes.Say("Retrying"))
+	})
+
+	It("only exports the remaining releases when rerun", func() {
+		fake.FailTasksTimes("export release: release-b", "compilation failed", 1)
+		Expect(run().ExitCode()).To(Equal(1))
+
+		exports, err := bosh.ReadExports(filepath.Join(workDir, bosh.ExportsFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exports).To(HaveLen(1))
+		Expect(exports[0].Tarball).To(HavePrefix("release-a-1.0-ubuntu-jammy-1.2-"))
+
+		session := run()
+		Expect(session.ExitCode()).To(Equal(0))
+		Expect(session.Out).To(gbytes.Say(`Skipping release-a/1.0 for ubuntu-jammy/1.2, already exported`))
+		Expect(session.Out).To(gbytes.Say(`Exported 1 of 2 releases, 1 already exported`))
+
+		tarballs, err := filepath.Glob(filepath.Join(workDir, "*.tgz"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tarballs).To(HaveLen(2))
+	})
+
+	It("skips releases named in a listing", func() {
+		listing := filepath.Join(binDir, "listing.txt")
+		Expect(os.WriteFile(listing, []byte("abc  release-b-1.0-ubuntu-jammy-1.2-20260101-120000-000000001.tgz\n"), 0644)).To(Succeed())
+
+		session := run("--listing", listing)
+		Expect(session.ExitCode()).To(Equal(0))
+		Expect(session.Out).To(gbytes.Say(`Exported 1 of 2 releases, 1 already exported`))
+
+		tarballs, err := filepath.Glob(filepath.Join(workDir, "release-b-*.tgz"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tarballs).To(BeEmpty())
+
+		exports, err := bosh.ReadExports(filepath.Join(workDir, bosh.ExportsFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exports).To(HaveLen(2))
+		Expect(exports[1]).To(Equal(bosh.ExportedRelease{
+			Release:         "release-b",
+			Version:         "1.0",
+			StemcellOS:      "ubuntu-jammy",
+			StemcellVersion: "1.2",
+			Tarball:         "release-b-1.0-ubuntu-jammy-1.2-20260101-120000-000000001.tgz",
+			SHA256:          "abc",
+		}))
+	})
+
+	It("only exports the releases of a selection", func() {
+		selection := filepath.Join(binDir, bosh.SelectionFile)
+		Expect(bosh.WriteSelection(selection, bosh.Selection{
+			StemcellOS:      "ubuntu-jammy",
+			StemcellVersion: "1.2",
+			Selected:        []bosh.SelectedRelease{{Name: "release-b", Version: "1.0", Reason: "no compiled release"}},
+			Skipped:         []bosh.SelectedRelease{{Name: "release-a", Version: "1.0", Reason: "already compiled for ubuntu-jammy/1.2"}},
+		})).To(Succeed())
+
+		session := run("--selection", selection)
+		Expect(session.ExitCode()).To(Equal(0))
+		Expect(session.Out).To(gbytes.Say(`Only exporting the 1 releases selected in .*selection.json`))
+		Expect(session.Out).To(gbytes.Say(`Exported 1 of 1 releases`))
+
+		tarballs, err := filepath.Glob(filepath.Join(workDir, "*.tgz"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tarballs).To(HaveLen(1))
+		Expect(filepath.Base(tarballs[0])).To(HavePrefix("release-b-1.0-ubuntu-jammy-1.2-"))
+	})
+
+	It("exports releases of multi-stemcell deployments against the stemcells their instances use", func() {
+		fake.UploadStemcell("bosh-warden-boshlite-ubuntu-noble-go_agent", "ubuntu-noble", "3.4")
+		fake.UploadRelease("release-c", "1.0")
+
+		manifest := `name: multi-compilation
+releases:
+- name: release-c
+  version: "1.0"
+stemcells:
+- alias: jammy
+  os: ubuntu-jammy
+  version: "1.2"
+- alias: noble
+  os: ubuntu-noble
+  version: "3.4"
+instance_groups:
+- name: c
+  stemcell: noble
+  jobs:
+  - name: c
+    release: release-c
+`
+		manifestPath := filepath.Join(workDir, "multi.yml")
+		Expect(os.WriteFile(manifestPath, []byte(manifest), 0644)).To(Succeed())
+		cmd := exec.Command(filepath.Join(binDir, "bosh"), "deploy", manifestPath, "-d", "multi-compilation", "-n", "--json")
+		cmd.Env = append(os.Environ(), fake.Env()...)
+		Expect(cmd.Run()).To(Succeed())
+		Expect(os.Remove(manifestPath)).To(Succeed())
+
+		session := run("--include", "release-c")
+		Expect(session.ExitCode()).To(Equal(0))
+		Expect(session.Out).To(gbytes.Say(`Exported 1 of 1 releases`))
+
+		tarballs, err := filepath.Glob(filepath.Join(workDir, "*.tgz"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tarballs).To(HaveLen(1))
+		Expect(filepath.Base(tarballs[0])).To(HavePrefix("release-c-1.0-ubuntu-noble-3.4-"))
+	})
+
+	It("cancels the running exports when it is terminated", func() {
+		fake.HangTasks("export release: release-b")
+
+		session := start("--workers", "1")
+		Eventually(taskState("export release: release-b"), 30*time.Second).Should(Equal("processing"))
+
+		session.Terminate()
+		Eventually(session, 30*time.Second).Should(gexec.Exit(1))
+		Expect(session.Out).To(gbytes.Say(`Failed:\n  release-b/1.0 for ubuntu-jammy/1.2 from release-b-compilation: bosh export-release: .+ \(cancelled Director task \d+\)`))
+		Expect(taskState("export release: release-b")()).To(Equal("cancelled"))
+	})
+
+	It("stops exports that run out of time", func() {
+		fake.HangTasks("export release: release-b")
+
+		session := run("--timeout", "1s")
+		Expect(session.ExitCode()).To(Equal(1))
+		Expect(session.Out).To(gbytes.Say(`Exported 1 of 2 releases`))
+		Expect(session.Out).To(gbytes.Say(`release-b/1.0 for ubuntu-jammy/1.2 from release-b-compilation: bosh export-release: timed out after 1s \(cancelled Director task \d+\)`))
+		Expect(taskState("export release: release-b")()).To(Equal("cancelled"))
+	})
+
+	It("rejects an invalid number of workers", func() {
+		session := run("--workers", "0")
+		Expect(session.ExitCode()).To(Equal(1))
+		Expect(session.Out).To(gbytes.Say("usage:"))
+	})
+})
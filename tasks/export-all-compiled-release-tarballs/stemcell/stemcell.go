@@ -1,10 +1,10 @@
 package stemcell
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"strings"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli"
 )
 
 // Stemcell is a bosh json representation of a bosh stemcell
@@ -18,41 +18,20 @@ func (s Stemcell) String() string {
 	return fmt.Sprint(s.OS, "/", s.Version)
 }
 
-//go:generate counterfeiter BoshCLI
-
-type BoshCLI interface {
-	Cmd(name string, args ...string) (io.Reader, error)
-}
-
-func List(boshCLI BoshCLI) ([]Stemcell, error) {
+func List(ctx context.Context, boshCLI boshcli.BoshCLI) ([]Stemcell, error) {
 	fmt.Println("Generating list of stemcells...")
-	r, err := boshCLI.Cmd("stemcells", "--json")
-	if err != nil {
-		return nil, err
-	}
-
-	return parseStemcellsOutput(r)
-}
-
-func parseStemcellsOutput(r io.Reader) ([]Stemcell, error) {
-	var output struct {
-		Tables []struct {
-			Rows []Stemcell
-		}
-	}
-
-	err := json.NewDecoder(r).Decode(&output)
+	boshStemcells, err := boshcli.NewClient(boshCLI).WithContext(ctx).Stemcells()
 	if err != nil {
 		return nil, err
 	}
 
 	var stemcells []Stemcell
-	for _, table := range output.Tables {
-		stemcells = append(stemcells, table.Rows...)
-	}
-
-	for i, stemcell := range stemcells {
-		stemcells[i].Version = strings.Trim(stemcell.Version, "*")
+	for _, boshStemcell := range boshStemcells {
+		stemcells = append(stemcells, Stemcell{
+			Name:    boshStemcell.Name,
+			OS:      boshStemcell.OS,
+			Version: boshStemcell.Version,
+		})
 	}
 
 	return stemcells, nil
@@ -2,6 +2,7 @@ package stemcell_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"strings"
@@ -9,13 +10,13 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	"github.com/cloudfoundry/runtime-ci/task-libs/boshcli/boshclifakes"
 	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/stemcell"
-	"github.com/cloudfoundry/runtime-ci/tasks/export-all-compiled-release-tarballs/stemcell/stemcellfakes"
 )
 
 var _ = Describe("List", func() {
 	var (
-		fakeCLI *stemcellfakes.FakeBoshCLI
+		fakeCLI *boshclifakes.FakeBoshCLI
 
 		returnedReader io.Reader
 		returnedError  error
@@ -25,7 +26,7 @@ var _ = Describe("List", func() {
 	)
 
 	BeforeEach(func() {
-		fakeCLI = new(stemcellfakes.FakeBoshCLI)
+		fakeCLI = new(boshclifakes.FakeBoshCLI)
 
 		returnedReader = new(bytes.Buffer)
 		returnedError = nil
@@ -34,12 +35,12 @@ var _ = Describe("List", func() {
 	JustBeforeEach(func() {
 		fakeCLI.CmdReturns(returnedReader, returnedError)
 
-		actualStemcells, actualErr = stemcell.List(fakeCLI)
+		actualStemcells, actualErr = stemcell.List(context.Background(), fakeCLI)
 	})
 
 	It("should call `bosh stemcells --json`", func() {
 		Expect(fakeCLI.CmdCallCount()).To(Equal(1), "expected bosh cli calls")
-		name, args := fakeCLI.CmdArgsForCall(0)
+		_, name, args := fakeCLI.CmdArgsForCall(0)
 
 		Expect(name).To(Equal("stemcells"), "expected command name")
 		Expect(args).To(ConsistOf("--json"), "expected command args")
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/spf13/pflag"
+)
+
+type fileFinding struct {
+	File string `json:"file"`
+	bosh.Finding
+}
+
+func main() {
+	var format string
+	pflag.StringVar(&format, "format", "json", "output format, json or text")
+	pflag.Parse()
+
+	if pflag.NArg() == 0 || (format != "json" && format != "text") {
+		fmt.Println("usage: main.go [--format json|text] <manifest>...")
+		os.Exit(1)
+	}
+
+	findings := []fileFinding{}
+	for _, path := range pflag.Args() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		manifestFindings, err := bosh.Lint(content)
+		if err != nil {
+			fmt.Printf("failed to lint %s: %s\n", path, err)
+			os.Exit(1)
+		}
+
+		for _, finding := range manifestFindings {
+			findings = append(findings, fileFinding{File: filepath.ToSlash(path), Finding: finding})
+		}
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(findings); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	case "text":
+		for _, finding := range findings {
+			fmt.Printf("%s:%s\n", finding.File, finding.Finding)
+		}
+	}
+
+	if len(findings) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/blang/semver"
+
+	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Println("usage: main.go <manifest> <version>")
+		os.Exit(1)
+	}
+
+	manifestPath := os.Args[1]
+
+	version, err := semver.ParseTolerant(os.Args[2])
+	if err != nil {
+		fmt.Printf("invalid version %q: %s\n", os.Args[2], err)
+		os.Exit(1)
+	}
+
+	manifest, err := os.ReadFile(manifestPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	stamped, err := bosh.StampManifestVersion(manifest, version)
+	if err != nil {
+		fmt.Printf("failed to stamp %s: %s\n", manifestPath, err)
+		os.Exit(1)
+	}
+
+	err = os.WriteFile(manifestPath, stamped, 0644)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -4,12 +4,18 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strings"
 
 	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/task-libs/concourse"
 )
 
+// Task declares the inputs and outputs that update-base-manifest-stemcell
+// expects.
+var Task = concourse.Task{
+	Inputs:  []string{"cf-deployment", "stemcell"},
+	Outputs: []string{"updated-cf-deployment"},
+}
+
 type Runner struct {
 	stemcell bosh.Stemcell
 
@@ -27,37 +33,32 @@ type Outputs struct {
 }
 
 func NewRunner(buildDir string) (Runner, error) {
-	inputs, err := setupInputs(buildDir)
-	if err != nil {
-		return Runner{}, err
-	}
-
-	outputs, err := setupOutputs(buildDir)
+	dirs, err := Task.Setup(buildDir)
 	if err != nil {
 		return Runner{}, err
 	}
 
-	return Runner{In: inputs, Out: outputs}, nil
+	return Runner{
+		In: Inputs{
+			cfDeploymentDir: dirs.Dir("cf-deployment"),
+			stemcellDir:     dirs.Dir("stemcell"),
+		},
+		Out: Outputs{
+			UpdatedCFDeploymentDir: dirs.Dir("updated-cf-deployment"),
+		},
+	}, nil
 }
 
 func (r *Runner) ReadStemcell() error {
-	version, err := readFile(filepath.Join(r.In.stemcellDir, "version"))
-	if err != nil {
-		return err
-	}
-	r.stemcell.Version = version
-
-	url, err := readFile(filepath.Join(r.In.stemcellDir, "url"))
-	if err != nil {
-		return err
-	}
-
-	r.stemcell.OS, err = parseOSfromURL(url)
-	if err != nil {
-		return err
-	}
+	var err error
+	r.stemcell, err = bosh.NewStemcellFromInput(r.In.stemcellDir)
+	return err
+}
 
-	return nil
+// ValidateStemcellOS checks the stemcell input before the manifest is
+// updated.
+func (r *Runner) ValidateStemcellOS() error {
+	return bosh.ValidateStemcellOSes(r.stemcell)
 }
 
 type UpdateFunc func([]byte, bosh.Stemcell) ([]byte, error)
@@ -93,61 +94,6 @@ func (r *Runner) WriteCommitMessage(commitMessagePath string) error {
 	return nil
 }
 
-func readFile(path string) (string, error) {
-	content, err := os.ReadFile(path)
-	if os.IsNotExist(err) {
-		pathDir := filepath.Base(filepath.Dir(path))
-		return "", fmt.Errorf("missing files: '%s'", filepath.Join(pathDir, filepath.Base(path)))
-	}
-
-	return string(content), err
-}
-
-func parseOSfromURL(url string) (string, error) {
-	versionRegex := regexp.MustCompile(`(ubuntu-[a-z]+(?:-[a-z]+)*?)(?:-go_agent)?\.tgz`)
-
-	allMatches := versionRegex.FindAllStringSubmatch(url, 1)
-
-	if len(allMatches) != 1 {
-		return "", fmt.Errorf("stemcell URL does not contain an ubuntu stemcell: %s", strings.Trim(url, "\n"))
-	}
-
-	osMatch := allMatches[0][1]
-	return osMatch, nil
-}
-
-func setupInputs(buildDir string) (Inputs, error) {
-	cfDeploymentDir, err := buildSubDir(buildDir, "cf-deployment")
-	if err != nil {
-		return Inputs{}, err
-	}
-	stemcellDir, err := buildSubDir(buildDir, "stemcell")
-	if err != nil {
-		return Inputs{}, err
-	}
-
-	return Inputs{cfDeploymentDir, stemcellDir}, nil
-}
-
-func setupOutputs(buildDir string) (Outputs, error) {
-	updatedCFDeploymentDir, err := buildSubDir(buildDir, "updated-cf-deployment")
-	if err != nil {
-		return Outputs{}, err
-	}
-
-	return Outputs{updatedCFDeploymentDir}, nil
-}
-
-func buildSubDir(buildDir, subDir string) (string, error) {
-	dir := filepath.Join(buildDir, subDir)
-	_, err := os.Stat(dir)
-	if os.IsNotExist(err) {
-		return "", fmt.Errorf("missing sub directory '%s' in build directory '%s'", subDir, buildDir)
-	}
-
-	return dir, nil
-}
-
 func manifestPath(cfDir string) string {
 	return filepath.Join(cfDir, "cf-deployment.yml")
 }
@@ -10,6 +10,12 @@ import (
 )
 
 func main() {
+	err := concourseio.Task.Validate("task.yml")
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
 	buildDir := os.Args[1]
 	runner, err := concourseio.NewRunner(buildDir)
 	if err != nil {
@@ -23,6 +29,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	err = runner.ValidateStemcellOS()
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
 	err = runner.UpdateManifest(bosh.UpdateStemcellSection)
 	if err != nil {
 		fmt.Print(err)
@@ -6,8 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
 	"github.com/cloudfoundry/runtime-ci/tasks/update-stemcell/concourseio"
@@ -15,12 +18,23 @@ import (
 )
 
 type OpsfileUpdater struct {
-	ops []Op
+	opsFiles []opsFile
 
 	compiledReleasesDir string
 	opsFileOutPath      string
 
 	releases []bosh.Release
+
+	compatibleVersions []string
+
+	merge bool
+	stale []bosh.Release
+}
+
+// opsFile is the compiled releases ops file generated for one stemcell.
+type opsFile struct {
+	path string
+	ops  []Op
 }
 
 type Op struct {
@@ -41,8 +55,42 @@ func NewOpsfileUpdater(compiledReleasesInDir string, opsFileOutPath string) *Ops
 	return &OpsfileUpdater{compiledReleasesDir: compiledReleasesInDir, opsFileOutPath: opsFileOutPath}
 }
 
+// WithCompatibleStemcellVersions lists other versions of the target stemcell
+// that the compiled releases can be used with. When any are given, releases
+// are written with exported_from instead of stemcell, and releases compiled
+// against one of these versions are accepted without recompiling.
+func (o *OpsfileUpdater) WithCompatibleStemcellVersions(versions ...string) *OpsfileUpdater {
+	o.compatibleVersions = versions
+	return o
+}
+
+// WithMerge makes Update keep the releases of the existing ops files that
+// were not compiled in this run, so that recompiling a subset of the releases
+// does not drop the others. Kept releases that are not compiled against the
+// new stemcell are reported by StaleReleases.
+func (o *OpsfileUpdater) WithMerge() *OpsfileUpdater {
+	o.merge = true
+	return o
+}
+
+// StaleReleases returns the releases kept from the existing ops files by the
+// last Update that are not compiled against its stemcells.
+func (o *OpsfileUpdater) StaleReleases() []bosh.Release {
+	return o.stale
+}
+
+// Load reads the compiled releases. When the compiled releases directory has
+// an exports manifest (bosh.ExportsFile) it is used as is, since it also lists
+// the releases an export run skipped; otherwise the release, version and
+// stemcell are parsed from each tarball's name.
 func (o *OpsfileUpdater) Load() error {
-	err := filepath.Walk(o.compiledReleasesDir, o.extractReleases())
+	exportsPath := filepath.Join(o.compiledReleasesDir, bosh.ExportsFile)
+	_, err := os.Stat(exportsPath)
+	if err == nil {
+		err = o.readExports(exportsPath)
+	} else {
+		err = filepath.Walk(o.compiledReleasesDir, o.extractReleases())
+	}
 	if err != nil {
 		return err
 	}
@@ -54,9 +102,22 @@ func (o *OpsfileUpdater) Load() error {
 	return nil
 }
 
-func (o *OpsfileUpdater) extractReleases() filepath.WalkFunc {
-	const compiledReleaseGCSPrefix = "https://storage.googleapis.com/cf-deployment-compiled-releases"
+const compiledReleaseGCSPrefix = "https://storage.googleapis.com/cf-deployment-compiled-releases"
+
+func (o *OpsfileUpdater) readExports(path string) error {
+	exports, err := bosh.ReadExports(path)
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		o.releases = append(o.releases, export.CompiledRelease(compiledReleaseGCSPrefix))
+	}
+
+	return nil
+}
 
+func (o *OpsfileUpdater) extractReleases() filepath.WalkFunc {
 	versionRegexString := `(.*)-([\d.]+)-(.*)-([\d.]+)-\d+-\d+-\d+.tgz`
 	versionRegex := regexp.MustCompile(versionRegexString)
 
@@ -69,7 +130,12 @@ func (o *OpsfileUpdater) extractReleases() filepath.WalkFunc {
 			return nil
 		}
 
+		// The directory can hold other files next to the tarballs, such as
+		// the index of export-all-compiled-release-tarballs.
 		tarballName := info.Name()
+		if filepath.Ext(tarballName) != ".tgz" {
+			return nil
+		}
 
 		allMatches := versionRegex.FindAllStringSubmatch(tarballName, 1)
 
@@ -99,14 +165,36 @@ func (o *OpsfileUpdater) extractReleases() filepath.WalkFunc {
 	}
 }
 
-func (o *OpsfileUpdater) Update(stemcell bosh.Stemcell) error {
+// Update generates an ops file per stemcell from the releases compiled
+// against it. The first stemcell's ops file is written to the configured
+// path, and every other one next to it with the stemcell OS as a suffix, e.g.
+// use-compiled-releases-ubuntu-noble.yml. Every release must be compiled
+// against one of the stemcells.
+func (o *OpsfileUpdater) Update(stemcells ...bosh.Stemcell) error {
 	if len(o.releases) == 0 {
 		return new(NoReleasesErr)
 	}
 
+	opsFiles := make([]opsFile, len(stemcells))
+	compatible := make([][]bosh.Stemcell, len(stemcells))
+	for i, stemcell := range stemcells {
+		opsFiles[i].path = o.opsFilePath(i, stemcell)
+		compatible[i] = o.compatibleStemcells(stemcell)
+	}
+
+	var mismatched []string
 	for _, release := range o.releases {
-		if release.Stemcell != stemcell {
-			return errors.New("stemcell mismatch")
+		i := slices.IndexFunc(compatible, func(stemcells []bosh.Stemcell) bool {
+			return isCompatible(stemcells, release.Stemcell)
+		})
+		if i < 0 {
+			mismatched = append(mismatched, fmt.Sprintf("%s (%s/%s)", path.Base(release.URL), release.Stemcell.OS, release.Stemcell.Version))
+			continue
+		}
+
+		if len(o.compatibleVersions) > 0 {
+			release.ExportedFrom = compatible[i]
+			release.Stemcell = bosh.Stemcell{}
 		}
 
 		op := Op{
@@ -115,28 +203,140 @@ func (o *OpsfileUpdater) Update(stemcell bosh.Stemcell) error {
 			Value: release,
 		}
 
-		o.ops = append(o.ops, op)
+		opsFiles[i].ops = append(opsFiles[i].ops, op)
 	}
 
+	if len(mismatched) > 0 {
+		var names []string
+		for _, stemcell := range stemcells {
+			names = append(names, fmt.Sprintf("%s/%s", stemcell.OS, stemcell.Version))
+		}
+		return fmt.Errorf("stemcell mismatch: %s not compiled against %s",
+			strings.Join(mismatched, ", "), strings.Join(names, " or "))
+	}
+
+	var stale []bosh.Release
+	if o.merge {
+		for i := range opsFiles {
+			kept, err := mergeExisting(&opsFiles[i], compatible[i])
+			if err != nil {
+				return err
+			}
+			stale = append(stale, kept...)
+		}
+	}
+
+	for i, f := range opsFiles {
+		if len(f.ops) == 0 {
+			return fmt.Errorf("no compiled releases found for stemcell %s/%s", stemcells[i].OS, stemcells[i].Version)
+		}
+	}
+
+	o.opsFiles = opsFiles
+	o.stale = stale
+
 	return nil
 }
 
+// mergeExisting merges the existing ops file at f.path into f. Releases that
+// f replaces keep their position, the other existing ops are kept as they
+// are, and releases new to the file are added at the end. It returns the kept
+// releases that are not compatible with the stemcell of the ops file.
+func mergeExisting(f *opsFile, compatible []bosh.Stemcell) ([]bosh.Release, error) {
+	content, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []Op
+	err = yaml.Unmarshal(content, &existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse existing ops file %s: %w", f.path, err)
+	}
+
+	replacements := map[string]Op{}
+	for _, op := range f.ops {
+		replacements[op.Path] = op
+	}
+
+	var (
+		ops   []Op
+		stale []bosh.Release
+	)
+	for _, op := range existing {
+		if replacement, ok := replacements[op.Path]; ok {
+			ops = append(ops, replacement)
+			delete(replacements, op.Path)
+			continue
+		}
+
+		if !slices.ContainsFunc(compatible, op.Value.IsCompiledFor) {
+			stale = append(stale, op.Value)
+		}
+		ops = append(ops, op)
+	}
+
+	for _, op := range f.ops {
+		if _, ok := replacements[op.Path]; ok {
+			ops = append(ops, op)
+		}
+	}
+
+	f.ops = ops
+
+	return stale, nil
+}
+
+func (o *OpsfileUpdater) opsFilePath(i int, stemcell bosh.Stemcell) string {
+	if i == 0 {
+		return o.opsFileOutPath
+	}
+
+	ext := filepath.Ext(o.opsFileOutPath)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(o.opsFileOutPath, ext), stemcell.OS, ext)
+}
+
+func (o *OpsfileUpdater) compatibleStemcells(stemcell bosh.Stemcell) []bosh.Stemcell {
+	stemcells := []bosh.Stemcell{stemcell}
+	for _, version := range o.compatibleVersions {
+		candidate := bosh.Stemcell{OS: stemcell.OS, Version: version}
+		if !isCompatible(stemcells, candidate) {
+			stemcells = append(stemcells, candidate)
+		}
+	}
+
+	return stemcells
+}
+
+func isCompatible(stemcells []bosh.Stemcell, stemcell bosh.Stemcell) bool {
+	return bosh.Release{ExportedFrom: stemcells}.IsCompiledFor(stemcell)
+}
+
 func (o OpsfileUpdater) Write() error {
-	if len(o.ops) == 0 {
+	if len(o.opsFiles) == 0 {
 		return new(NoReleasesErr)
 	}
 
-	buf := new(bytes.Buffer)
-	fmt.Fprintln(buf, "## GENERATED FILE. DO NOT EDIT")
-	fmt.Fprintln(buf, "---")
+	for _, f := range o.opsFiles {
+		buf := new(bytes.Buffer)
+		fmt.Fprintln(buf, "## GENERATED FILE. DO NOT EDIT")
+		fmt.Fprintln(buf, "---")
 
-	encoder := yaml.NewEncoder(buf)
-	encoder.SetIndent(2)
-	if err := encoder.Encode(o.ops); err != nil {
-		return err
+		encoder := yaml.NewEncoder(buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(f.ops); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(f.path, buf.Bytes(), 0755); err != nil {
+			return err
+		}
 	}
 
-	return os.WriteFile(o.opsFileOutPath, buf.Bytes(), 0755)
+	return nil
 }
 
 func computeSHA1Sum(filepath string) (string, error) {
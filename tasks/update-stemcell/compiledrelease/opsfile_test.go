@@ -82,6 +82,58 @@ var _ = Describe("OpsfileUpdater", func() {
 
 				Expect(opsfileUpdater.releases).To(ConsistOf(expectedReleases))
 			})
+
+			Context("when the directory has files that are not tarballs", func() {
+				BeforeEach(func() {
+					Expect(os.WriteFile(filepath.Join(buildDir, "exports-index.json"), []byte("{}"), 0777)).To(Succeed())
+				})
+
+				It("ignores them", func() {
+					Expect(actualError).NotTo(HaveOccurred())
+					Expect(opsfileUpdater.releases).To(HaveLen(2))
+				})
+			})
+		})
+
+		Context("when there is an exports manifest", func() {
+			BeforeEach(func() {
+				hyphenPath = "product-with-hyphens-1.2.3-some-stemcell-1.2-00000000-000000-000000000.tgz"
+
+				Expect(os.WriteFile(filepath.Join(buildDir, hyphenPath), []byte("hello world"), 0777)).To(Succeed())
+				Expect(bosh.WriteExports(filepath.Join(buildDir, bosh.ExportsFile), []bosh.ExportedRelease{{
+					Release:         "product-with-hyphens",
+					Version:         "1.2.3",
+					StemcellOS:      "some-stemcell",
+					StemcellVersion: "1.2",
+					Tarball:         hyphenPath,
+					SHA256:          "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+				}})).To(Succeed())
+			})
+
+			It("loads the releases from the manifest instead of the tarball names", func() {
+				Expect(actualError).NotTo(HaveOccurred())
+
+				Expect(opsfileUpdater.releases).To(Equal([]bosh.Release{{
+					Name: "product-with-hyphens",
+					SHA1: "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+					Stemcell: bosh.Stemcell{
+						OS:      "some-stemcell",
+						Version: "1.2",
+					},
+					Version: "1.2.3",
+					URL:     fmt.Sprintf("https://storage.googleapis.com/cf-deployment-compiled-releases/%s", hyphenPath),
+				}}))
+			})
+		})
+
+		Context("when the exports manifest is invalid", func() {
+			BeforeEach(func() {
+				Expect(os.WriteFile(filepath.Join(buildDir, bosh.ExportsFile), []byte("not json"), 0777)).To(Succeed())
+			})
+
+			It("will return an error", func() {
+				Expect(actualError).To(MatchError(ContainSubstring("failed to parse exports manifest")))
+			})
 		})
 
 		Context("when there are no releases", func() {
@@ -148,7 +200,9 @@ var _ = Describe("OpsfileUpdater", func() {
 			It("populates the ops in the opsfileUpdater", func() {
 				Expect(actualError).ToNot(HaveOccurred())
 
-				Expect(opsfileUpdater.ops).To(ConsistOf(
+				Expect(opsfileUpdater.opsFiles).To(HaveLen(1))
+				Expect(opsfileUpdater.opsFiles[0].path).To(Equal(opsfileOutPath))
+				Expect(opsfileUpdater.opsFiles[0].ops).To(ConsistOf(
 					Op{
 						Type: "replace",
 						Path: "/releases/name=some-buildpack",
@@ -189,7 +243,172 @@ var _ = Describe("OpsfileUpdater", func() {
 				})
 
 				It("will return an error", func() {
-					Expect(actualError).To(MatchError("stemcell mismatch"))
+					Expect(actualError).To(MatchError("stemcell mismatch: some-buildpack.com (some-stemcell/1.2), some-component.com (some-stemcell/1.2) not compiled against some-stemcell/3.4"))
+				})
+
+				Context("when the releases' stemcell is a compatible version", func() {
+					BeforeEach(func() {
+						opsfileUpdater.WithCompatibleStemcellVersions("1.2", "3.4")
+					})
+
+					It("lists every compatible stemcell in exported_from", func() {
+						Expect(actualError).ToNot(HaveOccurred())
+
+						Expect(opsfileUpdater.opsFiles[0].ops).To(HaveLen(2))
+						Expect(opsfileUpdater.opsFiles[0].ops[0].Value.Stemcell).To(BeZero())
+						Expect(opsfileUpdater.opsFiles[0].ops[0].Value.ExportedFrom).To(Equal([]bosh.Stemcell{
+							{OS: "some-stemcell", Version: "3.4"},
+							{OS: "some-stemcell", Version: "1.2"},
+						}))
+					})
+				})
+			})
+
+			Context("when merging with an existing ops file", func() {
+				BeforeEach(func() {
+					opsfileUpdater.WithMerge()
+
+					existing := `## GENERATED FILE. DO NOT EDIT
+---
+- type: replace
+  path: /releases/name=some-component
+  value:
+    name: some-component
+    sha1: old-sha
+    stemcell:
+      os: some-stemcell
+      version: "1.1"
+    url: some-url/old-component.com
+    version: 4.5.5
+- type: replace
+  path: /releases/name=some-current-release
+  value:
+    name: some-current-release
+    sha1: ccddee
+    stemcell:
+      os: some-stemcell
+      version: "1.2"
+    url: some-url/some-current-release.com
+    version: 7.8.9
+- type: replace
+  path: /releases/name=some-stale-release
+  value:
+    name: some-stale-release
+    sha1: 778899
+    stemcell:
+      os: some-stemcell
+      version: "1.1"
+    url: some-url/some-stale-release.com
+    version: 1.0.0
+`
+					Expect(os.WriteFile(opsfileOutPath, []byte(existing), 0644)).To(Succeed())
+				})
+
+				It("replaces the releases found in this run and keeps the rest", func() {
+					Expect(actualError).ToNot(HaveOccurred())
+
+					ops := opsfileUpdater.opsFiles[0].ops
+					Expect(ops).To(HaveLen(4))
+					Expect(ops[0].Value).To(Equal(opsfileUpdater.releases[1]))
+					Expect(ops[1].Path).To(Equal("/releases/name=some-current-release"))
+					Expect(ops[1].Value.SHA1).To(Equal("ccddee"))
+					Expect(ops[2].Path).To(Equal("/releases/name=some-stale-release"))
+					Expect(ops[3].Value).To(Equal(opsfileUpdater.releases[0]))
+				})
+
+				It("reports kept releases that do not match the new stemcell", func() {
+					Expect(actualError).ToNot(HaveOccurred())
+
+					Expect(opsfileUpdater.StaleReleases()).To(HaveLen(1))
+					Expect(opsfileUpdater.StaleReleases()[0].Name).To(Equal("some-stale-release"))
+				})
+
+				Context("when there is no existing ops file", func() {
+					BeforeEach(func() {
+						Expect(os.Remove(opsfileOutPath)).To(Succeed())
+					})
+
+					It("only includes the releases found in this run", func() {
+						Expect(actualError).ToNot(HaveOccurred())
+
+						Expect(opsfileUpdater.opsFiles[0].ops).To(HaveLen(2))
+						Expect(opsfileUpdater.StaleReleases()).To(BeEmpty())
+					})
+				})
+
+				Context("when the existing ops file is invalid", func() {
+					BeforeEach(func() {
+						Expect(os.WriteFile(opsfileOutPath, []byte("not: a list"), 0644)).To(Succeed())
+					})
+
+					It("returns an error", func() {
+						Expect(actualError).To(MatchError(ContainSubstring("failed to parse existing ops file " + opsfileOutPath)))
+					})
+				})
+			})
+
+			Context("when compatible versions do not include the releases' stemcell", func() {
+				BeforeEach(func() {
+					stemcellArg = bosh.Stemcell{OS: "some-stemcell", Version: "3.4"}
+					opsfileUpdater.WithCompatibleStemcellVersions("3.3")
+				})
+
+				It("will return an error", func() {
+					Expect(actualError).To(MatchError("stemcell mismatch: some-buildpack.com (some-stemcell/1.2), some-component.com (some-stemcell/1.2) not compiled against some-stemcell/3.4"))
+				})
+			})
+		})
+
+		Context("when there are several stemcells", func() {
+			var stemcellArgs []bosh.Stemcell
+
+			BeforeEach(func() {
+				opsfileUpdater.releases = []bosh.Release{
+					{Name: "capi", Stemcell: bosh.Stemcell{OS: "ubuntu-jammy", Version: "1.2"}, URL: "some-url/capi-1.0-ubuntu-jammy-1.2.tgz"},
+					{Name: "capi", Stemcell: bosh.Stemcell{OS: "ubuntu-noble", Version: "1.5"}, URL: "some-url/capi-1.0-ubuntu-noble-1.5.tgz"},
+					{Name: "uaa", Stemcell: bosh.Stemcell{OS: "ubuntu-noble", Version: "1.5"}, URL: "some-url/uaa-2.0-ubuntu-noble-1.5.tgz"},
+				}
+
+				stemcellArgs = []bosh.Stemcell{
+					{OS: "ubuntu-jammy", Version: "1.2"},
+					{OS: "ubuntu-noble", Version: "1.5"},
+				}
+			})
+
+			JustBeforeEach(func() {
+				actualError = opsfileUpdater.Update(stemcellArgs...)
+			})
+
+			It("generates an ops file per stemcell", func() {
+				Expect(actualError).ToNot(HaveOccurred())
+
+				Expect(opsfileUpdater.opsFiles).To(HaveLen(2))
+				Expect(opsfileUpdater.opsFiles[0].path).To(Equal(opsfileOutPath))
+				Expect(opsfileUpdater.opsFiles[0].ops).To(HaveLen(1))
+				Expect(opsfileUpdater.opsFiles[0].ops[0].Value.Stemcell.OS).To(Equal("ubuntu-jammy"))
+
+				Expect(opsfileUpdater.opsFiles[1].path).To(Equal(filepath.Join(buildDir, "ops-file-ubuntu-noble.yml")))
+				Expect(opsfileUpdater.opsFiles[1].ops).To(HaveLen(2))
+				Expect(opsfileUpdater.opsFiles[1].ops[1].Path).To(Equal("/releases/name=uaa"))
+			})
+
+			Context("when a tarball matches none of the stemcells", func() {
+				BeforeEach(func() {
+					stemcellArgs[1].Version = "1.6"
+				})
+
+				It("names the offending tarballs", func() {
+					Expect(actualError).To(MatchError("stemcell mismatch: capi-1.0-ubuntu-noble-1.5.tgz (ubuntu-noble/1.5), uaa-2.0-ubuntu-noble-1.5.tgz (ubuntu-noble/1.5) not compiled against ubuntu-jammy/1.2 or ubuntu-noble/1.6"))
+				})
+			})
+
+			Context("when a stemcell has no compiled releases", func() {
+				BeforeEach(func() {
+					stemcellArgs = append(stemcellArgs, bosh.Stemcell{OS: "ubuntu-resolute", Version: "1.0"})
+				})
+
+				It("returns an error", func() {
+					Expect(actualError).To(MatchError("no compiled releases found for stemcell ubuntu-resolute/1.0"))
 				})
 			})
 		})
@@ -221,7 +440,7 @@ var _ = Describe("OpsfileUpdater", func() {
 
 		Context("when the opsfileUpdater has a filled array of ops", func() {
 			BeforeEach(func() {
-				opsfileUpdater.ops = []Op{
+				opsfileUpdater.opsFiles = []opsFile{{path: opsfileOutPath, ops: []Op{
 					{
 						Type: "replace",
 						Path: "/releases/name=some-buildpack",
@@ -250,7 +469,7 @@ var _ = Describe("OpsfileUpdater", func() {
 							URL:     "some-url/some-component.com",
 						},
 					},
-				}
+				}}}
 			})
 
 			It("generates the opsfileUpdater for compiled releases with the updated stemcell", func() {
@@ -280,6 +499,69 @@ var _ = Describe("OpsfileUpdater", func() {
       version: "1.2"
     url: some-url/some-component.com
     version: 4.5.6
+`))
+			})
+		})
+
+		Context("when there are ops files for several stemcells", func() {
+			var nobleOpsfileOutPath string
+
+			BeforeEach(func() {
+				nobleOpsfileOutPath = filepath.Join(buildDir, "ops-file-ubuntu-noble.yml")
+				opsfileUpdater.opsFiles = []opsFile{
+					{path: opsfileOutPath, ops: []Op{{Type: "replace", Path: "/releases/name=capi", Value: bosh.Release{Name: "capi"}}}},
+					{path: nobleOpsfileOutPath, ops: []Op{{Type: "replace", Path: "/releases/name=uaa", Value: bosh.Release{Name: "uaa"}}}},
+				}
+			})
+
+			It("writes every ops file", func() {
+				Expect(actualError).NotTo(HaveOccurred())
+
+				Expect(os.ReadFile(opsfileOutPath)).To(ContainSubstring("/releases/name=capi"))
+				Expect(os.ReadFile(nobleOpsfileOutPath)).To(ContainSubstring("/releases/name=uaa"))
+			})
+		})
+
+		Context("when a release lists exported_from stemcells", func() {
+			BeforeEach(func() {
+				opsfileUpdater.opsFiles = []opsFile{{path: opsfileOutPath, ops: []Op{
+					{
+						Type: "replace",
+						Path: "/releases/name=some-buildpack",
+						Value: bosh.Release{
+							Name: "some-buildpack",
+							SHA1: "123456",
+							ExportedFrom: []bosh.Stemcell{
+								{OS: "some-stemcell", Version: "1.3"},
+								{OS: "some-stemcell", Version: "1.2"},
+							},
+							Version: "1.2.3",
+							URL:     "some-url/some-buildpack.com",
+						},
+					},
+				}}}
+			})
+
+			It("writes exported_from instead of stemcell", func() {
+				Expect(actualError).NotTo(HaveOccurred())
+
+				actualContents, err := os.ReadFile(opsfileOutPath)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(string(actualContents)).To(Equal(`## GENERATED FILE. DO NOT EDIT
+---
+- type: replace
+  path: /releases/name=some-buildpack
+  value:
+    name: some-buildpack
+    sha1: "123456"
+    exported_from:
+      - os: some-stemcell
+        version: "1.3"
+      - os: some-stemcell
+        version: "1.2"
+    url: some-url/some-buildpack.com
+    version: 1.2.3
 `))
 			})
 		})
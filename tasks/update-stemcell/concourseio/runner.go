@@ -4,23 +4,37 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
+	"github.com/cloudfoundry/runtime-ci/task-libs/concourse"
 )
 
+// additionalStemcellInputs are stemcells of other OS lines that compiled
+// releases are also provided for, e.g. while moving from one line to the next.
+var additionalStemcellInputs = []string{"additional-stemcell-1", "additional-stemcell-2", "additional-stemcell-3"}
+
+// Task declares the inputs and outputs that update-stemcell expects.
+var Task = concourse.Task{
+	Inputs:         []string{"cf-deployment", "compiled-releases", "stemcell"},
+	OptionalInputs: additionalStemcellInputs,
+	Outputs:        []string{"updated-cf-deployment"},
+	Params:         []string{"COMPATIBLE_STEMCELL_VERSIONS", "MERGE_COMPILED_RELEASES"},
+}
+
 type Runner struct {
-	stemcell bosh.Stemcell
+	stemcell            bosh.Stemcell
+	additionalStemcells []bosh.Stemcell
 
 	In  Inputs
 	Out Outputs
 }
 
 type Inputs struct {
-	cfDeploymentDir     string
-	CompiledReleasesDir string
-	stemcellDir         string
+	cfDeploymentDir        string
+	CompiledReleasesDir    string
+	stemcellDir            string
+	additionalStemcellDirs []string
 }
 
 type Outputs struct {
@@ -28,39 +42,62 @@ type Outputs struct {
 }
 
 func NewRunner(buildDir string) (Runner, error) {
-	inputs, err := setupInputs(buildDir)
+	dirs, err := Task.Setup(buildDir)
 	if err != nil {
 		return Runner{}, err
 	}
 
-	outputs, err := setupOutputs(buildDir)
-	if err != nil {
-		return Runner{}, err
+	var additionalStemcellDirs []string
+	for _, input := range additionalStemcellInputs {
+		if dirs.Has(input) {
+			additionalStemcellDirs = append(additionalStemcellDirs, dirs.Dir(input))
+		}
 	}
 
-	return Runner{In: inputs, Out: outputs}, nil
+	return Runner{
+		In: Inputs{
+			cfDeploymentDir:        dirs.Dir("cf-deployment"),
+			CompiledReleasesDir:    dirs.Dir("compiled-releases"),
+			stemcellDir:            dirs.Dir("stemcell"),
+			additionalStemcellDirs: additionalStemcellDirs,
+		},
+		Out: Outputs{
+			UpdatedCFDeploymentDir: dirs.Dir("updated-cf-deployment"),
+		},
+	}, nil
 }
 
+// ReadStemcell reads the stemcell that the manifest is updated to, and any
+// additional stemcells.
 func (r *Runner) ReadStemcell() error {
-	version, err := readFile(filepath.Join(r.In.stemcellDir, "version"))
+	var err error
+	r.stemcell, err = bosh.NewStemcellFromInput(r.In.stemcellDir)
 	if err != nil {
 		return err
 	}
-	r.stemcell.Version = version
 
-	url, err := readFile(filepath.Join(r.In.stemcellDir, "url"))
-	if err != nil {
-		return err
-	}
-
-	r.stemcell.OS, err = parseOSfromURL(url)
-	if err != nil {
-		return err
+	r.additionalStemcells = nil
+	for _, dir := range r.In.additionalStemcellDirs {
+		stemcell, err := bosh.NewStemcellFromInput(dir)
+		if err != nil {
+			return err
+		}
+		r.additionalStemcells = append(r.additionalStemcells, stemcell)
 	}
 
 	return nil
 }
 
+// ValidateStemcellOS checks the stemcell inputs before any files are
+// updated.
+func (r *Runner) ValidateStemcellOS() error {
+	return bosh.ValidateStemcellOSes(r.stemcells()...)
+}
+
+func (r *Runner) stemcells() []bosh.Stemcell {
+	return append([]bosh.Stemcell{r.stemcell}, r.additionalStemcells...)
+}
+
 type UpdateFunc func([]byte, bosh.Stemcell) ([]byte, error)
 
 func (r *Runner) UpdateManifest(updateFunction UpdateFunc) error {
@@ -89,7 +126,9 @@ func (r *Runner) UpdateManifest(updateFunction UpdateFunc) error {
 //go:generate counterfeiter . StemcellUpdater
 type StemcellUpdater interface {
 	Load() error
-	Update(bosh.Stemcell) error
+	// Update is given the manifest stemcell first, followed by any
+	// additional stemcells.
+	Update(...bosh.Stemcell) error
 	Write() error
 }
 
@@ -99,7 +138,7 @@ func (r *Runner) UpdateStemcell(updaters ...StemcellUpdater) error {
 		if err != nil {
 			return err
 		}
-		err = updater.Update(r.stemcell)
+		err = updater.Update(r.stemcells()...)
 		if err != nil {
 			return err
 		}
@@ -114,6 +153,13 @@ func (r *Runner) UpdateStemcell(updaters ...StemcellUpdater) error {
 
 func (r *Runner) WriteCommitMessage(commitMessagePath string) error {
 	commitMessage := fmt.Sprintf("Update stemcell to %s \"%s\"", r.stemcell.OS, r.stemcell.Version)
+	if len(r.additionalStemcells) > 0 {
+		var stemcells []string
+		for _, stemcell := range r.stemcells() {
+			stemcells = append(stemcells, fmt.Sprintf("%s \"%s\"", stemcell.OS, stemcell.Version))
+		}
+		commitMessage = fmt.Sprintf("Update stemcells to %s", strings.Join(stemcells, ", "))
+	}
 
 	err := os.WriteFile(commitMessagePath, []byte(commitMessage), 0644)
 	if err != nil {
@@ -123,65 +169,6 @@ func (r *Runner) WriteCommitMessage(commitMessagePath string) error {
 	return nil
 }
 
-func readFile(path string) (string, error) {
-	content, err := os.ReadFile(path)
-	if os.IsNotExist(err) {
-		pathDir := filepath.Base(filepath.Dir(path))
-		return "", fmt.Errorf("missing files: '%s'", filepath.Join(pathDir, filepath.Base(path)))
-	}
-
-	return string(content), err
-}
-
-func parseOSfromURL(url string) (string, error) {
-	versionRegex := regexp.MustCompile(`(ubuntu-[a-z]+(?:-[a-z]+)*?)(?:-go_agent)?\.tgz`)
-
-	allMatches := versionRegex.FindAllStringSubmatch(url, 1)
-
-	if len(allMatches) != 1 {
-		return "", fmt.Errorf("stemcell URL does not contain an ubuntu stemcell: %s", strings.Trim(url, "\n"))
-	}
-
-	osMatch := allMatches[0][1]
-	return osMatch, nil
-}
-
-func setupInputs(buildDir string) (Inputs, error) {
-	cfDeploymentDir, err := buildSubDir(buildDir, "cf-deployment")
-	if err != nil {
-		return Inputs{}, err
-	}
-	compiledReleasesDir, err := buildSubDir(buildDir, "compiled-releases")
-	if err != nil {
-		return Inputs{}, err
-	}
-	stemcellDir, err := buildSubDir(buildDir, "stemcell")
-	if err != nil {
-		return Inputs{}, err
-	}
-
-	return Inputs{cfDeploymentDir, compiledReleasesDir, stemcellDir}, nil
-}
-
-func setupOutputs(buildDir string) (Outputs, error) {
-	updatedCFDeploymentDir, err := buildSubDir(buildDir, "updated-cf-deployment")
-	if err != nil {
-		return Outputs{}, err
-	}
-
-	return Outputs{updatedCFDeploymentDir}, nil
-}
-
-func buildSubDir(buildDir, subDir string) (string, error) {
-	dir := filepath.Join(buildDir, subDir)
-	_, err := os.Stat(dir)
-	if os.IsNotExist(err) {
-		return "", fmt.Errorf("missing sub directory '%s' in build directory '%s'", subDir, buildDir)
-	}
-
-	return dir, nil
-}
-
 func manifestPath(cfDir string) string {
 	return filepath.Join(cfDir, "cf-deployment.yml")
 }
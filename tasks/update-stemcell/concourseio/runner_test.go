@@ -28,6 +28,12 @@ var _ = Describe("Runner", func() {
 		Expect(os.RemoveAll(buildDir)).To(Succeed())
 	})
 
+	Describe("Task", func() {
+		It("matches task.yml", func() {
+			Expect(Task.Validate("../task.yml")).To(Succeed())
+		})
+	})
+
 	Describe("NewRunner", func() {
 		var (
 			actualRunner Runner
@@ -145,6 +151,27 @@ var _ = Describe("Runner", func() {
 				Expect(actualErr).To(MatchError("missing files: 'stemcell/version'"))
 			})
 		})
+
+		Context("when there are additional stemcells", func() {
+			BeforeEach(func() {
+				Expect(os.WriteFile(filepath.Join(stemcellDir, "version"), []byte("1.2"), 0777)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(stemcellDir, "url"), []byte("https://example.com/bosh-stemcell-1.2-ubuntu-jammy-go_agent.tgz"), 0777)).To(Succeed())
+
+				additionalStemcellDir := filepath.Join(buildDir, "additional-stemcell-1")
+				Expect(os.Mkdir(additionalStemcellDir, 0777)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(additionalStemcellDir, "version"), []byte("1.5"), 0777)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(additionalStemcellDir, "url"), []byte("https://example.com/bosh-stemcell-1.5-ubuntu-noble-go_agent.tgz"), 0777)).To(Succeed())
+
+				runner.In.additionalStemcellDirs = []string{additionalStemcellDir}
+			})
+
+			It("reads every stemcell", func() {
+				Expect(actualErr).ToNot(HaveOccurred())
+
+				Expect(runner.stemcell).To(Equal(bosh.Stemcell{OS: "ubuntu-jammy", Version: "1.2"}))
+				Expect(runner.additionalStemcells).To(Equal([]bosh.Stemcell{{OS: "ubuntu-noble", Version: "1.5"}}))
+			})
+		})
 	})
 
 	Describe("UpdateManifest", func() {
@@ -250,10 +277,21 @@ var _ = Describe("Runner", func() {
 			Expect(updater.LoadCallCount()).To(Equal(1), "Expected updater to call load once")
 
 			Expect(updater.UpdateCallCount()).To(Equal(1), "Expected updater to call update once")
-			Expect(updater.UpdateArgsForCall(0)).To(Equal(expectedStemcell))
+			Expect(updater.UpdateArgsForCall(0)).To(Equal([]bosh.Stemcell{expectedStemcell}))
 
 			Expect(updater.WriteCallCount()).To(Equal(1), "Expected updater to call write once")
 		})
+
+		Context("when there are additional stemcells", func() {
+			BeforeEach(func() {
+				runner.additionalStemcells = []bosh.Stemcell{{OS: "ubuntu-noble", Version: "1.5"}}
+			})
+
+			It("passes the manifest stemcell first", func() {
+				Expect(actualErr).ToNot(HaveOccurred())
+				Expect(updater.UpdateArgsForCall(0)).To(Equal([]bosh.Stemcell{expectedStemcell, {OS: "ubuntu-noble", Version: "1.5"}}))
+			})
+		})
 	})
 
 	Describe("WriteCommitMessage", func() {
@@ -284,5 +322,19 @@ var _ = Describe("Runner", func() {
 			Expect(string(actualCommitMessage)).To(Equal(fmt.Sprintf("Update stemcell to %s \"%s\"",
 				expectedStemcell.OS, expectedStemcell.Version)))
 		})
+
+		Context("when there are additional stemcells", func() {
+			BeforeEach(func() {
+				runner.additionalStemcells = []bosh.Stemcell{{OS: "ubuntu-noble", Version: "1.5"}}
+			})
+
+			It("lists every stemcell", func() {
+				Expect(actualErr).ToNot(HaveOccurred())
+
+				actualCommitMessage, err := os.ReadFile(commitMessagePath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(actualCommitMessage)).To(Equal(`Update stemcells to gundam "1.1.0", ubuntu-noble "1.5"`))
+			})
+		})
 	})
 })
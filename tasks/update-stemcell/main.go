@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/cloudfoundry/runtime-ci/task-libs/bosh"
 	"github.com/cloudfoundry/runtime-ci/tasks/update-stemcell/compiledrelease"
@@ -11,6 +12,12 @@ import (
 )
 
 func main() {
+	err := concourseio.Task.Validate("task.yml")
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
 	buildDir := os.Args[1]
 	runner, err := concourseio.NewRunner(buildDir)
 	if err != nil {
@@ -24,23 +31,37 @@ func main() {
 		os.Exit(1)
 	}
 
+	err = runner.ValidateStemcellOS()
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
 	err = runner.UpdateManifest(bosh.UpdateStemcellSection)
 	if err != nil {
 		fmt.Print(err)
 		os.Exit(1)
 	}
 
-	err = runner.UpdateStemcell(
-		compiledrelease.NewOpsfileUpdater(
-			runner.In.CompiledReleasesDir,
-			filepath.Join(runner.Out.UpdatedCFDeploymentDir, "operations", "use-compiled-releases.yml"),
-		),
-	)
+	opsfileUpdater := compiledrelease.NewOpsfileUpdater(
+		runner.In.CompiledReleasesDir,
+		filepath.Join(runner.Out.UpdatedCFDeploymentDir, "operations", "use-compiled-releases.yml"),
+	).WithCompatibleStemcellVersions(compatibleStemcellVersions()...)
+	if concourseio.Task.Param("MERGE_COMPILED_RELEASES") == "true" {
+		opsfileUpdater.WithMerge()
+	}
+
+	err = runner.UpdateStemcell(opsfileUpdater)
 	if err != nil {
 		fmt.Print(err)
 		os.Exit(1)
 	}
 
+	for _, release := range opsfileUpdater.StaleReleases() {
+		fmt.Printf("WARNING: kept compiled release %s/%s is not compiled against the new stemcell (%s)\n",
+			release.Name, release.Version, release.URL)
+	}
+
 	commitMessagePath := filepath.Join(buildDir, "commit-message.txt")
 
 	err = runner.WriteCommitMessage(commitMessagePath)
@@ -49,3 +70,11 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// compatibleStemcellVersions reads the comma or space separated
+// COMPATIBLE_STEMCELL_VERSIONS param.
+func compatibleStemcellVersions() []string {
+	return strings.FieldsFunc(concourseio.Task.Param("COMPATIBLE_STEMCELL_VERSIONS"), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
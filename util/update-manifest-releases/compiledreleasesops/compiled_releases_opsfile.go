@@ -17,11 +17,41 @@ type Release struct {
 	Name     string                    `yaml:"name"`
 	SHA1     string                    `yaml:"sha1"`
 	Stemcell common.StemcellForRelease `yaml:"stemcell,omitempty"`
-	URL      string                    `yaml:"url"`
-	Version  string                    `yaml:"version"`
+	// ExportedFrom replaces Stemcell when the tarball is valid for several
+	// stemcell versions.
+	ExportedFrom []common.StemcellForRelease `yaml:"exported_from,omitempty"`
+	URL          string                      `yaml:"url"`
+	Version      string                      `yaml:"version"`
 }
 
+type UpdateFunc func([]string, string, []byte, common.MarshalFunc, common.UnmarshalFunc) ([]byte, string, error)
+
 func UpdateCompiledReleases(releaseNames []string, buildDir string, opsFile []byte, marshalFunc common.MarshalFunc, unmarshalFunc common.UnmarshalFunc) ([]byte, string, error) {
+	return updateCompiledReleases(releaseNames, buildDir, opsFile, marshalFunc, unmarshalFunc, nil, nil)
+}
+
+// UpdateCompiledReleasesWithCompatibleStemcells is UpdateCompiledReleases for
+// tarballs that are also valid for other versions of the stemcell they were
+// compiled against. Every updated release lists its own stemcell and the
+// compatible versions in exported_from.
+func UpdateCompiledReleasesWithCompatibleStemcells(compatibleVersions []string) UpdateFunc {
+	return func(releaseNames []string, buildDir string, opsFile []byte, marshalFunc common.MarshalFunc, unmarshalFunc common.UnmarshalFunc) ([]byte, string, error) {
+		return updateCompiledReleases(releaseNames, buildDir, opsFile, marshalFunc, unmarshalFunc, compatibleVersions, nil)
+	}
+}
+
+// UpdateCompiledReleasesFromExports is UpdateCompiledReleases for releases
+// listed in an exports manifest written by export-all-compiled-release-tarballs.
+// The releases are taken from the manifest instead of the tarballs in the
+// build directory. compatibleVersions is as for
+// UpdateCompiledReleasesWithCompatibleStemcells and may be empty.
+func UpdateCompiledReleasesFromExports(exports []Export, compatibleVersions []string) UpdateFunc {
+	return func(releaseNames []string, buildDir string, opsFile []byte, marshalFunc common.MarshalFunc, unmarshalFunc common.UnmarshalFunc) ([]byte, string, error) {
+		return updateCompiledReleases(releaseNames, buildDir, opsFile, marshalFunc, unmarshalFunc, compatibleVersions, exports)
+	}
+}
+
+func updateCompiledReleases(releaseNames []string, buildDir string, opsFile []byte, marshalFunc common.MarshalFunc, unmarshalFunc common.UnmarshalFunc, compatibleVersions []string, exports []Export) ([]byte, string, error) {
 	if len(releaseNames) == 0 {
 		err := errors.New("releaseNames provided to UpdateReleases must contain at least one release name")
 		return nil, "", err
@@ -45,21 +75,27 @@ func UpdateCompiledReleases(releaseNames []string, buildDir string, opsFile []by
 
 		for i, op := range deserializedOpsFile {
 			if op.Path == matchingReleasePath {
-				newRelease, err = getCompiledReleaseForBuild(buildDir, releaseName)
+				newRelease, err = getCompiledRelease(buildDir, releaseName, exports)
 				if err != nil {
 					return nil, "", err
 				}
 				foundRelease = true
+				if len(compatibleVersions) > 0 || usesExportedFrom(op.Value) {
+					newRelease = withExportedFrom(newRelease, compatibleVersions)
+				}
 				deserializedOpsFile[i].Value = newRelease
 				commitMessage = fmt.Sprintf("Updated compiled releases with %s %s", newRelease.Name, newRelease.Version)
 			}
 		}
 
 		if !foundRelease {
-			newRelease, err = getCompiledReleaseForBuild(buildDir, releaseName)
+			newRelease, err = getCompiledRelease(buildDir, releaseName, exports)
 			if err != nil {
 				return nil, "", err
 			}
+			if len(compatibleVersions) > 0 {
+				newRelease = withExportedFrom(newRelease, compatibleVersions)
+			}
 			deserializedOpsFile = appendNewRelease(newRelease, deserializedOpsFile)
 			commitMessage = fmt.Sprintf("Updated compiled releases with %s %s", newRelease.Name, newRelease.Version)
 		}
@@ -73,6 +109,44 @@ func UpdateCompiledReleases(releaseNames []string, buildDir string, opsFile []by
 	return updatedOpsFile, commitMessage, nil
 }
 
+// usesExportedFrom reports whether an existing ops file value lists its
+// stemcells in exported_from, so that updates keep that format.
+func usesExportedFrom(value interface{}) bool {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		_, ok := v["exported_from"]
+		return ok
+	case map[string]interface{}:
+		_, ok := v["exported_from"]
+		return ok
+	}
+	return false
+}
+
+func withExportedFrom(release Release, compatibleVersions []string) Release {
+	exportedFrom := []common.StemcellForRelease{release.Stemcell}
+	for _, version := range compatibleVersions {
+		stemcell := common.StemcellForRelease{OS: release.Stemcell.OS, Version: version}
+		if !containsStemcell(exportedFrom, stemcell) {
+			exportedFrom = append(exportedFrom, stemcell)
+		}
+	}
+
+	release.ExportedFrom = exportedFrom
+	release.Stemcell = common.StemcellForRelease{}
+
+	return release
+}
+
+func containsStemcell(stemcells []common.StemcellForRelease, stemcell common.StemcellForRelease) bool {
+	for _, s := range stemcells {
+		if s == stemcell {
+			return true
+		}
+	}
+	return false
+}
+
 func appendNewRelease(newRelease Release, opsFile []opsfile.Op) []opsfile.Op {
 	newReleaseOps := opsfile.Op{
 		TypeField: "replace",
@@ -83,6 +157,34 @@ func appendNewRelease(newRelease Release, opsFile []opsfile.Op) []opsfile.Op {
 	return append(opsFile, newReleaseOps)
 }
 
+func getCompiledRelease(buildDir, releaseName string, exports []Export) (Release, error) {
+	if exports != nil {
+		return getCompiledReleaseFromExports(exports, releaseName)
+	}
+	return getCompiledReleaseForBuild(buildDir, releaseName)
+}
+
+func getCompiledReleaseFromExports(exports []Export, releaseName string) (Release, error) {
+	var matches []Export
+	for _, export := range exports {
+		if export.Release == releaseName {
+			matches = append(matches, export)
+		}
+	}
+	if len(matches) != 1 {
+		return Release{}, fmt.Errorf("expected to find exactly 1 export of %s in the exports manifest, found %d", releaseName, len(matches))
+	}
+
+	export := matches[0]
+	return Release{
+		Name:     export.Release,
+		SHA1:     "sha256:" + export.SHA256,
+		Stemcell: common.StemcellForRelease{OS: export.StemcellOS, Version: export.StemcellVersion},
+		URL:      fmt.Sprintf("%s/%s", compiledReleasesURLPrefix, export.Tarball),
+		Version:  export.Version,
+	}, nil
+}
+
 func getCompiledReleaseForBuild(buildDir, releaseName string) (Release, error) {
 	releaseTarballGlob := filepath.Join(buildDir, fmt.Sprintf("%s-compiled-release-tarball", releaseName), "*.tgz")
 
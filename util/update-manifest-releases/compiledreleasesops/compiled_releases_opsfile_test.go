@@ -85,4 +85,130 @@ var _ = Describe("UpdateCompiledReleases", func() {
 		Expect(commitMessage).To(Equal("Updated compiled releases with no-stemcell-section 0.3.0"))
 		Expect(string(updatedOpsFile)).To(Equal(desiredOpsFile))
 	})
+	Context("with compatible stemcell versions", func() {
+		It("lists the tarball's stemcell and the compatible versions in exported_from", func() {
+			originalOpsFile := `- type: replace
+  path: /releases/name=no-stemcell-section
+  value:
+    name: no-stemcell-section
+    sha1: sha256:62c6bb48e76d31b2d6348ce5120bb99773bc1fc31724be710ffd1ca8aa836538
+    stemcell:
+      os: awesome-stemcell
+      version: "0.9"
+    url: https://storage.googleapis.com/cf-deployment-compiled-releases/no-stemcell-section-0.0.0-awesome-stemcell-0.9-20180808-195254-497840039.tgz
+    version: 0.0.1
+`
+			desiredOpsFile := `- type: replace
+  path: /releases/name=no-stemcell-section
+  value:
+    name: no-stemcell-section
+    sha1: sha256:280c8373b5cc2d96119e00f10e496b54e44e4e34fae2415718ac3b90558e26e5
+    exported_from:
+    - os: awesome-stemcell
+      version: "1.0"
+    - os: awesome-stemcell
+      version: "1.1"
+    url: https://storage.googleapis.com/cf-deployment-compiled-releases/no-stemcell-section-0.3.0-awesome-stemcell-1.0-20180808-195254-497840039.tgz
+    version: 0.3.0
+`
+			update := compiledreleasesops.UpdateCompiledReleasesWithCompatibleStemcells([]string{"1.0", "1.1"})
+			updatedOpsFile, commitMessage, err := update([]string{"no-stemcell-section"}, compiledReleaseBuildDir, []byte(originalOpsFile), yaml.Marshal, yaml.Unmarshal)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commitMessage).To(Equal("Updated compiled releases with no-stemcell-section 0.3.0"))
+			Expect(string(updatedOpsFile)).To(Equal(desiredOpsFile))
+		})
+
+		It("adds new releases with exported_from", func() {
+			update := compiledreleasesops.UpdateCompiledReleasesWithCompatibleStemcells([]string{"0.9"})
+			updatedOpsFile, _, err := update([]string{"extraneous"}, compiledReleaseBuildDir, originalOpsFile, yaml.Marshal, yaml.Unmarshal)
+			Expect(err).NotTo(HaveOccurred())
+
+			var ops []struct {
+				Path  string
+				Value compiledreleasesops.Release
+			}
+			Expect(yaml.Unmarshal(updatedOpsFile, &ops)).To(Succeed())
+			Expect(ops[len(ops)-1].Path).To(Equal("/releases/name=extraneous"))
+			Expect(ops[len(ops)-1].Value.ExportedFrom).To(HaveLen(2))
+			Expect(ops[len(ops)-1].Value.Stemcell.OS).To(BeEmpty())
+		})
+	})
+
+	It("keeps exported_from when the existing release uses it", func() {
+		originalOpsFile := `- type: replace
+  path: /releases/name=no-stemcell-section
+  value:
+    name: no-stemcell-section
+    exported_from:
+    - os: awesome-stemcell
+      version: "0.9"
+    sha1: sha256:62c6bb48e76d31b2d6348ce5120bb99773bc1fc31724be710ffd1ca8aa836538
+    url: https://storage.googleapis.com/cf-deployment-compiled-releases/no-stemcell-section-0.0.0-awesome-stemcell-0.9-20180808-195254-497840039.tgz
+    version: 0.0.1
+`
+		updatedOpsFile, _, err := compiledreleasesops.UpdateCompiledReleases([]string{"no-stemcell-section"}, compiledReleaseBuildDir, []byte(originalOpsFile), yaml.Marshal, yaml.Unmarshal)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(updatedOpsFile)).To(ContainSubstring("    exported_from:\n    - os: awesome-stemcell\n      version: \"1.0\"\n    url:"))
+		Expect(string(updatedOpsFile)).NotTo(ContainSubstring("stemcell:"))
+	})
+
+	Describe("UpdateCompiledReleasesFromExports", func() {
+		var exports []compiledreleasesops.Export
+
+		BeforeEach(func() {
+			exports = []compiledreleasesops.Export{{
+				Release:         "test",
+				Version:         "0.2.0",
+				StemcellOS:      "ubuntu-jammy",
+				StemcellVersion: "1.10",
+				Tarball:         "test-0.2.0-ubuntu-jammy-1.10-20260101-120000-000000001.tgz",
+				SHA256:          "abc123",
+			}}
+		})
+
+		It("takes the release from the exports manifest instead of the tarballs", func() {
+			updatedOpsFile, commitMessage, err := compiledreleasesops.UpdateCompiledReleasesFromExports(exports, nil)([]string{"test"}, "does-not-exist", originalOpsFile, yaml.Marshal, yaml.Unmarshal)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commitMessage).To(Equal("Updated compiled releases with test 0.2.0"))
+
+			var ops []struct {
+				Path  string
+				Value compiledreleasesops.Release
+			}
+			Expect(yaml.Unmarshal(updatedOpsFile, &ops)).To(Succeed())
+
+			var updated []compiledreleasesops.Release
+			for _, op := range ops {
+				if op.Path == "/releases/name=test" {
+					updated = append(updated, op.Value)
+				}
+			}
+			Expect(updated).To(HaveLen(1))
+			Expect(updated[0].Version).To(Equal("0.2.0"))
+			Expect(updated[0].SHA1).To(Equal("sha256:abc123"))
+			Expect(updated[0].Stemcell.OS).To(Equal("ubuntu-jammy"))
+			Expect(updated[0].Stemcell.Version).To(Equal("1.10"))
+			Expect(updated[0].URL).To(Equal("https://storage.googleapis.com/cf-deployment-compiled-releases/test-0.2.0-ubuntu-jammy-1.10-20260101-120000-000000001.tgz"))
+		})
+
+		It("returns an error when the release is not in the exports manifest", func() {
+			_, _, err := compiledreleasesops.UpdateCompiledReleasesFromExports(exports, nil)([]string{"extraneous"}, compiledReleaseBuildDir, originalOpsFile, yaml.Marshal, yaml.Unmarshal)
+			Expect(err).To(MatchError("expected to find exactly 1 export of extraneous in the exports manifest, found 0"))
+		})
+
+		It("reads the exports manifest", func() {
+			dir, err := os.MkdirTemp("", "exports-")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			path := dir + "/exports.json"
+			Expect(os.WriteFile(path, []byte(`[{"release":"test","version":"0.2.0","stemcell_os":"ubuntu-jammy","stemcell_version":"1.10","tarball":"test-0.2.0-ubuntu-jammy-1.10-20260101-120000-000000001.tgz","sha256":"abc123","duration_seconds":1.5}]`), 0644)).To(Succeed())
+
+			read, err := compiledreleasesops.ReadExports(path)
+			Expect(err).NotTo(HaveOccurred())
+			exports[0].DurationSeconds = 1.5
+			Expect(read).To(Equal(exports))
+		})
+	})
 })
@@ -0,0 +1,37 @@
+package compiledreleasesops
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Export is an entry of the exports.json manifest written by
+// export-all-compiled-release-tarballs next to the tarballs it exports.
+type Export struct {
+	Release         string  `json:"release"`
+	Version         string  `json:"version"`
+	StemcellOS      string  `json:"stemcell_os"`
+	StemcellVersion string  `json:"stemcell_version"`
+	Tarball         string  `json:"tarball"`
+	SHA256          string  `json:"sha256"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// ReadExports reads an exports manifest.
+func ReadExports(path string) ([]Export, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	exports := []Export{}
+	if err := json.Unmarshal(content, &exports); err != nil {
+		return nil, fmt.Errorf("failed to parse exports manifest %s: %s", path, err)
+	}
+	if exports == nil {
+		exports = []Export{}
+	}
+
+	return exports, nil
+}
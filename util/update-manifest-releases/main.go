@@ -156,6 +156,12 @@ func main() {
 
 	var target string
 	flag.StringVar(&target, "target", "manifest", "choose whether to update releases in manifest or opsfile")
+
+	var compatibleStemcellVersions string
+	flag.StringVar(&compatibleStemcellVersions, "compatible-stemcell-versions", "", "comma or space separated stemcell versions that compiled releases are also valid for")
+
+	var exportsManifest string
+	flag.StringVar(&exportsManifest, "exports-manifest", "", "path to the exports.json written by export-all-compiled-release-tarballs, used instead of the compiled release tarballs")
 	flag.Parse()
 
 	var err error
@@ -186,6 +192,23 @@ func main() {
 			os.Exit(1)
 		}
 	case "compiledReleasesOpsfile":
+		compatibleVersions := strings.FieldsFunc(compatibleStemcellVersions, func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+
+		updateCompiledReleases := compiledreleasesops.UpdateCompiledReleases
+		if len(compatibleVersions) > 0 {
+			updateCompiledReleases = compiledreleasesops.UpdateCompiledReleasesWithCompatibleStemcells(compatibleVersions)
+		}
+		if exportsManifest != "" {
+			exports, err := compiledreleasesops.ReadExports(exportsManifest)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+			updateCompiledReleases = compiledreleasesops.UpdateCompiledReleasesFromExports(exports, compatibleVersions)
+		}
+
 		if err = update(
 			releases,
 			os.Getenv("ORIGINAL_OPS_FILE_PATH"),
@@ -194,7 +217,7 @@ func main() {
 			outputDir,
 			buildDir,
 			commitMessagePath,
-			compiledreleasesops.UpdateCompiledReleases,
+			updateCompiledReleases,
 		); err != nil {
 			fmt.Fprintln(os.Stderr, err.Error())
 			os.Exit(1)
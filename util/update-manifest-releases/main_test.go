@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/onsi/gomega/gexec"
+	"gopkg.in/yaml.v2"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -881,6 +882,29 @@ stemcells:
 			Expect(updatedOpsFile).To(MatchYAML(expectedOpsFile))
 		})
 
+		It("accepts compatible stemcell versions separated by commas and spaces", func() {
+			session, err := gexec.Start(exec.Command(pathToBinary, []string{"--build-dir", buildDir, "--input-dir", "original-compiled-releases-ops-file", "--output-dir", "updated-compiled-releases-ops-file", "--target", "compiledReleasesOpsfile", "--compatible-stemcell-versions", "2.1, 2.2"}...), GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session, 5*time.Second).Should(gexec.Exit())
+			Expect(session.ExitCode()).To(Equal(0))
+
+			updatedOpsFile, err := os.ReadFile(filepath.Join(buildDir, "updated-compiled-releases-ops-file", "updated_ops_file.yml"))
+			Expect(err).NotTo(HaveOccurred())
+
+			var ops []struct {
+				Value struct {
+					ExportedFrom []map[string]string `yaml:"exported_from"`
+				}
+			}
+			Expect(yaml.Unmarshal(updatedOpsFile, &ops)).To(Succeed())
+			Expect(ops[0].Value.ExportedFrom).To(Equal([]map[string]string{
+				{"os": "stemcell2", "version": "2.0"},
+				{"os": "stemcell2", "version": "2.1"},
+				{"os": "stemcell2", "version": "2.2"},
+			}))
+		})
+
 		It("does not overwrite the commit message if it says that there are changes", func() {
 			err := os.WriteFile(filepath.Join(buildDir, os.Getenv("COMMIT_MESSAGE_PATH")), []byte("previous commit message with changes"), 0666)
 			Expect(err).NotTo(HaveOccurred())